@@ -0,0 +1,369 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rakutentech/jwk-go/jwk"
+	"github.com/rakutentech/jwk-go/jwktypes"
+	"github.com/rakutentech/jwk-go/okp"
+)
+
+var ecCurves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+var thumbprintHashes = map[string]crypto.Hash{
+	"sha256": crypto.SHA256,
+	"sha384": crypto.SHA384,
+	"sha512": crypto.SHA512,
+}
+
+func runGenerate(env *environment, args []string) error {
+	fs := newFlagSet(env, "generate", "")
+	keyType := fs.String("type", "EC/P-256", "key type in 'kty/crv' format: EC/P-256, EC/P-384, EC/P-521, RSA/<bits>, OKP/Ed25519, OKP/X25519 or oct/<bits>")
+	kid := fs.String("kid", "", "key ID (defaults to the key thumbprint)")
+	use := fs.String("use", "", "key use: sig or enc")
+	alg := fs.String("alg", "", "key algorithm (guessed from the key use if not specified)")
+	expires := fs.Duration("expires", 0, "key lifetime, e.g. 2160h (no expiry if not specified)")
+	set := fs.Bool("set", false, "output a JWK Set instead of a single JWK")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	key, err := generateKey(*keyType)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Truncate(time.Second)
+	k := jwk.NewSpecWithID(*kid, key)
+	k.Algorithm = *alg
+	k.IssuedAt = now
+	if *expires > 0 {
+		k.ExpiresAt = now.Add(*expires)
+	}
+	err = k.Normalize(jwk.NormalizationSettings{Use: *use, RequireKeyID: true})
+	if err != nil {
+		return err
+	}
+
+	ks := jwk.KeySpecSet{Keys: []jwk.KeySpec{*k}}
+	data, err := marshalKeys(ks, !*set, false)
+	if err != nil {
+		return err
+	}
+	return writeJSON(env, data)
+}
+
+func generateKey(keyType string) (interface{}, error) {
+	kty, param, _ := strings.Cut(keyType, "/")
+	switch kty {
+	case jwktypes.EC:
+		if param == "" {
+			param = "P-256"
+		}
+		curve, ok := ecCurves[param]
+		if !ok {
+			return nil, fmt.Errorf("unsupported elliptic curve: %s", param)
+		}
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	case jwktypes.RSA:
+		bits, err := parseBits(param, 2048)
+		if err != nil {
+			return nil, err
+		}
+		if bits < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits long")
+		}
+		return rsa.GenerateKey(rand.Reader, bits)
+	case jwktypes.OKP:
+		switch param {
+		case "Ed25519", "":
+			return okp.GenerateEd25519(rand.Reader)
+		case "X25519":
+			return okp.GenerateCurve25519(rand.Reader)
+		default:
+			return nil, fmt.Errorf("unsupported OKP curve: %s", param)
+		}
+	case jwktypes.OctetKey:
+		bits, err := parseBits(param, 256)
+		if err != nil {
+			return nil, err
+		}
+		if bits <= 0 || bits%8 != 0 {
+			return nil, errors.New("symmetric key size must be a positive multiple of 8 bits")
+		}
+		key := make([]byte, bits/8)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", kty)
+	}
+}
+
+func parseBits(param string, defaultBits int) (int, error) {
+	if param == "" {
+		return defaultBits, nil
+	}
+	bits, err := strconv.Atoi(param)
+	if err != nil {
+		return 0, fmt.Errorf("invalid key size: %s", param)
+	}
+	return bits, nil
+}
+
+func runInspect(env *environment, args []string) error {
+	fs := newFlagSet(env, "inspect", "[file...]")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	ks, _, err := readKeys(env, fs.Args())
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(env.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KID\tKTY\tCRV\tBITS\tPRIVATE\tUSE\tALG\tTHUMBPRINT\tEXPIRES")
+	for _, k := range ks.Keys {
+		kty, crv, private := k.KeyType()
+		if kty == jwktypes.RSA || kty == jwktypes.OctetKey {
+			crv = "" // The "curve" is the key size, which we display separately
+		}
+		thumbprint, err := k.Thumbprint()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%t\t%s\t%s\t%s\t%s\n",
+			orDash(k.KeyID), kty, orDash(crv), k.KeySize(), private,
+			orDash(k.Use), orDash(k.Algorithm),
+			base64.RawURLEncoding.EncodeToString(thumbprint),
+			formatExpiry(k.ExpiresAt))
+	}
+	return tw.Flush()
+}
+
+func formatExpiry(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	s := t.UTC().Format(time.RFC3339)
+	if !t.After(time.Now()) {
+		s += " (expired)"
+	}
+	return s
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func runPublic(env *environment, args []string) error {
+	fs := newFlagSet(env, "public", "[file...]")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	ks, single, err := readKeys(env, fs.Args())
+	if err != nil {
+		return err
+	}
+	data, err := marshalKeys(ks, single, true)
+	if err != nil {
+		return err
+	}
+	return writeJSON(env, data)
+}
+
+func runToPEM(env *environment, args []string) error {
+	fs := newFlagSet(env, "to-pem", "[file...]")
+	public := fs.Bool("public", false, "output only public keys")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	ks, _, err := readKeys(env, fs.Args())
+	if err != nil {
+		return err
+	}
+	for _, k := range ks.Keys {
+		var data []byte
+		if *public {
+			data, err = k.MarshalPublicPEM()
+		} else {
+			data, err = k.MarshalPEM()
+		}
+		if err != nil {
+			return fmt.Errorf("key %q: %w", k.KeyID, err)
+		}
+		if _, err := env.stdout.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runFromPEM(env *environment, args []string) error {
+	fs := newFlagSet(env, "from-pem", "[file...]")
+	use := fs.String("use", "", "key use: sig or enc")
+	public := fs.Bool("public", false, "output only public keys")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	inputs, err := readInputs(env, fs.Args())
+	if err != nil {
+		return err
+	}
+	var ks jwk.KeySpecSet
+	for _, in := range inputs {
+		keys, err := jwk.ParsePEM(in.data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", in.name, err)
+		}
+		ks.Keys = append(ks.Keys, keys.Keys...)
+	}
+	for i := range ks.Keys {
+		err := ks.Keys[i].Normalize(jwk.NormalizationSettings{Use: *use, RequireKeyID: true})
+		if err != nil {
+			return err
+		}
+	}
+	data, err := marshalKeys(ks, false, *public)
+	if err != nil {
+		return err
+	}
+	return writeJSON(env, data)
+}
+
+func runThumbprint(env *environment, args []string) error {
+	fs := newFlagSet(env, "thumbprint", "[file...]")
+	hashName := fs.String("hash", "sha256", "hash function: sha256, sha384 or sha512")
+	uri := fs.Bool("uri", false, "output RFC 9278 thumbprint URIs")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	h, ok := thumbprintHashes[*hashName]
+	if !ok {
+		return fmt.Errorf("unsupported hash function: %s", *hashName)
+	}
+	ks, _, err := readKeys(env, fs.Args())
+	if err != nil {
+		return err
+	}
+	for _, k := range ks.Keys {
+		var thumbprint string
+		if *uri {
+			thumbprint, err = k.ThumbprintURI(h)
+		} else {
+			var b []byte
+			b, err = k.ThumbprintUsing(h.New())
+			thumbprint = base64.RawURLEncoding.EncodeToString(b)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(env.stdout, "%s  %s\n", thumbprint, orDash(k.KeyID))
+	}
+	return nil
+}
+
+func runMerge(env *environment, args []string) error {
+	fs := newFlagSet(env, "merge", "file...")
+	public := fs.Bool("public", false, "output only public keys")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	ks, _, err := readKeys(env, fs.Args())
+	if err != nil {
+		return err
+	}
+	merged, err := mergeKeys(ks)
+	if err != nil {
+		return err
+	}
+	data, err := marshalKeys(merged, false, *public)
+	if err != nil {
+		return err
+	}
+	return writeJSON(env, data)
+}
+
+// mergeKeys removes keys which are published more than once under the same
+// key ID and fails if different keys share the same key ID.
+func mergeKeys(ks jwk.KeySpecSet) (jwk.KeySpecSet, error) {
+	return jwk.KeySpecSet{}.Merge(ks, jwk.MergeSettings{
+		Resolve: func(existing, incoming *jwk.KeySpec) (*jwk.KeySpec, error) {
+			if !existing.SamePublicKey(incoming) {
+				return nil, fmt.Errorf("different keys share the same key ID %q", existing.KeyID)
+			}
+			return existing, nil // Same key published twice
+		},
+	})
+}
+
+func runSplit(env *environment, args []string) error {
+	fs := newFlagSet(env, "split", "[file...]")
+	dir := fs.String("dir", "", "write each key to <dir>/<kid>.json instead of writing JSON lines to stdout")
+	public := fs.Bool("public", false, "output only public keys")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	ks, _, err := readKeys(env, fs.Args())
+	if err != nil {
+		return err
+	}
+	for i, k := range ks.Keys {
+		data, err := marshalKeys(jwk.KeySpecSet{Keys: []jwk.KeySpec{k}}, true, *public)
+		if err != nil {
+			return err
+		}
+		if *dir == "" {
+			fmt.Fprintf(env.stdout, "%s\n", data)
+			continue
+		}
+		name := k.KeyID
+		if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+			name = strconv.Itoa(i)
+		}
+		path := filepath.Join(*dir, name+".json")
+		if err := writeNewFile(path, append(data, '\n')); err != nil {
+			return err
+		}
+		fmt.Fprintln(env.stderr, path)
+	}
+	return nil
+}
+
+// writeNewFile writes data to a new file, and fails if the file already
+// exists (e.g. when two keys share the same key ID)
+func writeNewFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%s already exists", path)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rakutentech/jwk-go/jwk"
+)
+
+// input is the raw content of a single input file
+type input struct {
+	name string
+	data []byte
+}
+
+// readInputs reads all the specified files, or stdin if no files are specified.
+// The file name "-" also stands for stdin.
+func readInputs(env *environment, files []string) ([]input, error) {
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	inputs := make([]input, 0, len(files))
+	for _, name := range files {
+		var data []byte
+		var err error
+		if name == "-" {
+			name = "<stdin>"
+			data, err = io.ReadAll(env.stdin)
+		} else {
+			data, err = os.ReadFile(name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, input{name, data})
+	}
+	return inputs, nil
+}
+
+// readKeys reads and parses all inputs. single is true if there was only one
+// input and it contained a single JWK.
+func readKeys(env *environment, files []string) (ks jwk.KeySpecSet, single bool, err error) {
+	inputs, err := readInputs(env, files)
+	if err != nil {
+		return ks, false, err
+	}
+	for _, in := range inputs {
+		keys, format, err := jwk.ParseKeyFile(in.data)
+		if err != nil {
+			return ks, false, fmt.Errorf("%s: %w", in.name, err)
+		}
+		ks.Keys = append(ks.Keys, keys.Keys...)
+		single = format == jwk.KeyFileJWK && len(inputs) == 1
+	}
+	return ks, single, nil
+}
+
+// writeJSON writes indented JSON to stdout
+func writeJSON(env *environment, data []byte) error {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err := buf.WriteTo(env.stdout)
+	return err
+}
+
+// marshalKeys serializes a single KeySpec or a whole KeySpecSet
+func marshalKeys(ks jwk.KeySpecSet, single bool, public bool) ([]byte, error) {
+	switch {
+	case single && public:
+		return ks.Keys[0].MarshalPublicJSON()
+	case single:
+		return ks.Keys[0].MarshalJSON()
+	case public:
+		return ks.MarshalPublicJSON()
+	default:
+		return json.Marshal(ks)
+	}
+}
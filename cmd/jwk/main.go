@@ -0,0 +1,110 @@
+// Command jwk inspects, generates and converts JSON Web Keys.
+//
+// Usage:
+//
+//	jwk <command> [flags] [file...]
+//
+// Input is read from the specified files, or from stdin if no files are
+// specified (or the file name is "-"). Each input may contain a single JWK,
+// a JWK Set or a PEM bundle.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+type command struct {
+	usage string
+	run   func(env *environment, args []string) error
+}
+
+var commands = map[string]command{
+	"generate":   {"generate a new key", runGenerate},
+	"inspect":    {"print a human-readable summary of keys", runInspect},
+	"public":     {"strip private members from keys", runPublic},
+	"to-pem":     {"convert keys to PEM", runToPEM},
+	"from-pem":   {"convert PEM keys to a JWK Set", runFromPEM},
+	"thumbprint": {"compute RFC 7638 thumbprints", runThumbprint},
+	"merge":      {"merge several JWK Sets into one", runMerge},
+	"split":      {"split a JWK Set into individual JWKs", runSplit},
+}
+
+// environment contains the standard streams used by commands
+type environment struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// errUsage is returned when the command-line could not be parsed.
+// The usage message has already been printed in this case.
+var errUsage = errors.New("usage error")
+
+func main() {
+	env := &environment{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}
+	if err := run(env, os.Args[1:]); err != nil {
+		if !errors.Is(err, errUsage) {
+			fmt.Fprintf(os.Stderr, "jwk: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(env *environment, args []string) error {
+	if len(args) == 0 {
+		printUsage(env.stderr)
+		return errUsage
+	}
+	name := args[0]
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage(env.stdout)
+		return nil
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(env.stderr, "jwk: unknown command %q\n", name)
+		printUsage(env.stderr)
+		return errUsage
+	}
+	return cmd.run(env, args[1:])
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: jwk <command> [flags] [file...]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-12s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'jwk <command> -h' for the flags of each command.")
+}
+
+// newFlagSet creates a flag set for a command which reports errors to stderr
+func newFlagSet(env *environment, name string, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(env.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(env.stderr, "Usage: jwk %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses the command-line flags, converting flag errors to errUsage
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	return nil
+}
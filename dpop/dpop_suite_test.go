@@ -0,0 +1,13 @@
+package dpop_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDpop(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Dpop Suite")
+}
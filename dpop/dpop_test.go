@@ -0,0 +1,227 @@
+package dpop
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rakutentech/jwk-go/internal/jws"
+	"github.com/rakutentech/jwk-go/internal/testutils"
+	"github.com/rakutentech/jwk-go/jwk"
+	"github.com/rakutentech/jwk-go/okp"
+)
+
+var _ = Describe("DPoP", func() {
+	ecKey := jwk.NewSpec(testutils.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader)))
+	rsaKey := jwk.NewSpec(testutils.Must(rsa.GenerateKey(rand.Reader, 2048)))
+	edKey := jwk.NewSpec(testutils.Must(okp.GenerateEd25519(rand.Reader)))
+
+	const uri = "https://resource.example.org/protectedresource"
+	req := Request{Method: "GET", URL: uri}
+
+	var verifier *Verifier
+	BeforeEach(func() {
+		verifier = &Verifier{ReplayCache: &MemoryReplayCache{}}
+	})
+
+	DescribeTable("Should create and verify proofs",
+		func(key *jwk.KeySpec, alg string) {
+			proof, err := NewProof(key, "GET", uri, ProofSettings{})
+			Expect(err).To(Succeed())
+
+			token, err := jws.Parse(proof)
+			Expect(err).To(Succeed())
+			Expect(token.Header.Typ).To(Equal("dpop+jwt"))
+			Expect(token.Header.Alg).To(Equal(alg))
+			expectedJWK, err := key.MarshalPublicJSON()
+			Expect(err).To(Succeed())
+			Expect(string(token.Header.JWK)).To(MatchJSON(expectedJWK))
+
+			p, err := verifier.Verify(proof, req)
+			Expect(err).To(Succeed())
+			Expect(p.Algorithm).To(Equal(alg))
+			Expect(p.Key.IsPublic()).To(BeTrue())
+			Expect(p.Claims.HTM).To(Equal("GET"))
+			Expect(p.Claims.HTU).To(Equal(uri))
+			Expect(p.Claims.JTI).ToNot(BeEmpty())
+			jkt, err := JKT(key)
+			Expect(err).To(Succeed())
+			Expect(p.JKT).To(Equal(jkt))
+		},
+		Entry("EC", ecKey, "ES256"),
+		Entry("RSA", rsaKey, "RS256"),
+		Entry("Ed25519", edKey, "EdDSA"),
+	)
+
+	It("Should use the algorithm of the key", func() {
+		k := rsaKey.Clone()
+		k.Algorithm = "PS256"
+		proof, err := NewProof(k, "GET", uri, ProofSettings{})
+		Expect(err).To(Succeed())
+		p, err := verifier.Verify(proof, req)
+		Expect(err).To(Succeed())
+		Expect(p.Algorithm).To(Equal("PS256"))
+	})
+
+	It("Should refuse to create proofs with public or symmetric keys", func() {
+		pub, err := ecKey.PublicOnly()
+		Expect(err).To(Succeed())
+		_, err = NewProof(pub, "GET", uri, ProofSettings{})
+		Expect(err).To(HaveOccurred())
+		_, err = NewProof(jwk.NewSpec([]byte("0123456789abcdef0123456789abcdef")), "GET", uri, ProofSettings{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should normalize htu", func() {
+		proof, err := NewProof(ecKey, "POST", "HTTPS://Server.Example.com:443/token?foo=bar#frag", ProofSettings{})
+		Expect(err).To(Succeed())
+		p, err := verifier.Verify(proof, Request{Method: "POST", URL: "https://server.example.com/token?x=y"})
+		Expect(err).To(Succeed())
+		Expect(p.Claims.HTU).To(Equal("https://server.example.com/token"))
+	})
+
+	It("Should detect replayed proofs", func() {
+		proof, err := NewProof(ecKey, "GET", uri, ProofSettings{})
+		Expect(err).To(Succeed())
+		_, err = verifier.Verify(proof, req)
+		Expect(err).To(Succeed())
+		_, err = verifier.Verify(proof, req)
+		Expect(err).To(MatchError(ErrReplayedProof))
+		Expect(err).To(MatchError(ErrInvalidProof))
+
+		// The same jti used by another key is not a replay
+		proof, err = NewProof(edKey, "GET", uri, ProofSettings{JTI: jtiOf(proof)})
+		Expect(err).To(Succeed())
+		_, err = verifier.Verify(proof, req)
+		Expect(err).To(Succeed())
+	})
+
+	It("Should check ath and nonce", func() {
+		proof, err := NewProof(ecKey, "GET", uri, ProofSettings{AccessToken: "Kz~8mXK1EalYznwH-LC-1fBAo.4Ljp~zsPE_NeO.gxU", Nonce: "n-0S6_WzA2Mj"})
+		Expect(err).To(Succeed())
+		_, err = verifier.Verify(proof, Request{Method: "GET", URL: uri, AccessToken: "other", Nonce: "n-0S6_WzA2Mj"})
+		Expect(err).To(MatchError(ContainSubstring("ath")))
+		_, err = verifier.Verify(proof, Request{Method: "GET", URL: uri, AccessToken: "Kz~8mXK1EalYznwH-LC-1fBAo.4Ljp~zsPE_NeO.gxU", Nonce: "other"})
+		Expect(err).To(MatchError(ContainSubstring("nonce")))
+		p, err := verifier.Verify(proof, Request{Method: "GET", URL: uri, AccessToken: "Kz~8mXK1EalYznwH-LC-1fBAo.4Ljp~zsPE_NeO.gxU", Nonce: "n-0S6_WzA2Mj"})
+		Expect(err).To(Succeed())
+		// Example from RFC 9449 section 7.1
+		Expect(p.Claims.ATH).To(Equal("fUHyO2r2Z3DZ53EsNrWBb0xWXoaNy59IiKCAqksmQEo"))
+	})
+
+	It("Should check the bound key thumbprint", func() {
+		proof, err := NewProof(ecKey, "GET", uri, ProofSettings{})
+		Expect(err).To(Succeed())
+		jkt, err := JKT(edKey)
+		Expect(err).To(Succeed())
+		_, err = verifier.Verify(proof, Request{Method: "GET", URL: uri, JKT: jkt})
+		Expect(err).To(MatchError(ContainSubstring("thumbprint")))
+	})
+
+	It("Should check iat", func() {
+		now := time.Now()
+		verifier.Now = func() time.Time { return now }
+		proof, err := NewProof(ecKey, "GET", uri, ProofSettings{IssuedAt: now.Add(-10 * time.Minute)})
+		Expect(err).To(Succeed())
+		_, err = verifier.Verify(proof, req)
+		Expect(err).To(MatchError(ContainSubstring("too old")))
+
+		proof, err = NewProof(ecKey, "GET", uri, ProofSettings{IssuedAt: now.Add(time.Minute)})
+		Expect(err).To(Succeed())
+		_, err = verifier.Verify(proof, req)
+		Expect(err).To(MatchError(ContainSubstring("future")))
+	})
+
+	It("Should check the request", func() {
+		proof, err := NewProof(ecKey, "GET", uri, ProofSettings{})
+		Expect(err).To(Succeed())
+		_, err = verifier.Verify(proof, Request{Method: "POST", URL: uri})
+		Expect(err).To(MatchError(ContainSubstring("htm")))
+		_, err = verifier.Verify(proof, Request{Method: "GET", URL: "https://resource.example.org/other"})
+		Expect(err).To(MatchError(ContainSubstring("htu")))
+	})
+
+	It("Should check allowed algorithms", func() {
+		verifier.AllowedAlgorithms = []string{"ES256"}
+		proof, err := NewProof(edKey, "GET", uri, ProofSettings{})
+		Expect(err).To(Succeed())
+		_, err = verifier.Verify(proof, req)
+		Expect(err).To(MatchError(ContainSubstring("not allowed")))
+	})
+
+	It("Should reject proofs which are not DPoP proofs or embed private keys", func() {
+		privateJWK, err := ecKey.MarshalJSON()
+		Expect(err).To(Succeed())
+		payload := []byte(`{"jti":"1","htm":"GET","htu":"` + uri + `","iat":` + itoa(time.Now().Unix()) + `}`)
+
+		token, err := jws.Sign("ES256", ecKey.Key, map[string]interface{}{"typ": "dpop+jwt", "jwk": json.RawMessage(privateJWK)}, payload)
+		Expect(err).To(Succeed())
+		_, err = verifier.Verify(token, req)
+		Expect(err).To(MatchError(ContainSubstring("public key")))
+
+		publicJWK, err := ecKey.MarshalPublicJSON()
+		Expect(err).To(Succeed())
+		token, err = jws.Sign("ES256", ecKey.Key, map[string]interface{}{"typ": "JWT", "jwk": json.RawMessage(publicJWK)}, payload)
+		Expect(err).To(Succeed())
+		_, err = verifier.Verify(token, req)
+		Expect(err).To(MatchError(ContainSubstring("typ")))
+
+		token, err = jws.Sign("HS256", []byte("secret"), map[string]interface{}{"typ": "dpop+jwt", "jwk": json.RawMessage(publicJWK)}, payload)
+		Expect(err).To(Succeed())
+		_, err = verifier.Verify(token, req)
+		Expect(err).To(MatchError(ContainSubstring("algorithm")))
+
+		// Signed by another key than the one in the header
+		token, err = jws.Sign("EdDSA", edKey.Key, map[string]interface{}{"typ": "dpop+jwt", "jwk": json.RawMessage(publicJWK)}, payload)
+		Expect(err).To(Succeed())
+		_, err = verifier.Verify(token, req)
+		Expect(err).To(MatchError(ErrInvalidProof))
+	})
+
+	It("Should expire replay cache entries", func() {
+		now := time.Now()
+		cache := &MemoryReplayCache{Now: func() time.Time { return now }}
+		Expect(cache.CheckAndStore("a", now.Add(time.Minute))).To(BeFalse())
+		Expect(cache.CheckAndStore("a", now.Add(time.Minute))).To(BeTrue())
+		now = now.Add(2 * time.Minute)
+		Expect(cache.CheckAndStore("b", now.Add(time.Minute))).To(BeFalse())
+		Expect(cache.Len()).To(Equal(1))
+		Expect(cache.CheckAndStore("a", now.Add(time.Minute))).To(BeFalse())
+	})
+
+	It("Should not prune the replay cache more than once per interval", func() {
+		now := time.Now()
+		cache := &MemoryReplayCache{Now: func() time.Time { return now }}
+		Expect(cache.CheckAndStore("a", now.Add(time.Second))).To(BeFalse())
+		now = now.Add(2 * time.Second)
+		Expect(cache.CheckAndStore("b", now.Add(time.Second))).To(BeFalse())
+		Expect(cache.Len()).To(Equal(2))
+		Expect(cache.CheckAndStore("a", now.Add(time.Second))).To(BeFalse())
+
+		now = now.Add(pruneInterval)
+		Expect(cache.CheckAndStore("c", now.Add(time.Second))).To(BeFalse())
+		Expect(cache.Len()).To(Equal(1))
+	})
+})
+
+func jtiOf(proof string) string {
+	payload, err := base64.RawURLEncoding.DecodeString(strings.Split(proof, ".")[1])
+	testutils.PanicOnError(err)
+	var claims Claims
+	testutils.PanicOnError(json.Unmarshal(payload, &claims))
+	return claims.JTI
+}
+
+func itoa(i int64) string {
+	b, err := json.Marshal(i)
+	testutils.PanicOnError(err)
+	return string(b)
+}
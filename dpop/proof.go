@@ -0,0 +1,145 @@
+// Package dpop implements creation and verification of OAuth 2.0
+// Demonstrating Proof of Possession (DPoP) proofs, as specified by RFC 9449.
+//
+// DPoP proofs are JWTs signed with a private key held by the client, which
+// embed the matching public key in the 'jwk' header. Access tokens are bound
+// to the key through its JWK SHA-256 thumbprint ('jkt').
+package dpop
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/rakutentech/jwk-go/internal/jws"
+	"github.com/rakutentech/jwk-go/jwk"
+)
+
+// ProofType is the 'typ' header value of DPoP proofs
+const ProofType = "dpop+jwt"
+
+// Claims contains the claims of a DPoP proof
+type Claims struct {
+	// JTI is the unique identifier of the proof
+	JTI string `json:"jti"`
+	// HTM is the HTTP method of the request
+	HTM string `json:"htm"`
+	// HTU is the HTTP URI of the request, without query and fragment
+	HTU string `json:"htu"`
+	// IAT is the creation time of the proof (seconds since the epoch)
+	IAT int64 `json:"iat"`
+	// ATH is the hash of the access token sent with the request (if any)
+	ATH string `json:"ath,omitempty"`
+	// Nonce is the server-provided nonce (if any)
+	Nonce string `json:"nonce,omitempty"`
+}
+
+// ProofSettings contains optional settings for NewProof
+type ProofSettings struct {
+	// Algorithm is the signature algorithm. If empty, KeySpec.Algorithm is
+	// used, and if that is empty too, the default algorithm for the key type.
+	Algorithm string
+
+	// AccessToken is the access token sent along with the proof. If specified,
+	// its hash is included in the 'ath' claim.
+	AccessToken string
+
+	// Nonce is a server-provided nonce to include in the 'nonce' claim
+	Nonce string
+
+	// IssuedAt is the creation time of the proof. Defaults to the current time.
+	IssuedAt time.Time
+
+	// JTI is the unique identifier of the proof. Defaults to a random value.
+	JTI string
+}
+
+// NewProof creates a DPoP proof for an HTTP request with the specified method
+// and URI, signed with the private key in key. The public key is embedded as
+// the 'jwk' header of the proof.
+func NewProof(key *jwk.KeySpec, method, uri string, settings ProofSettings) (string, error) {
+	if key.IsPublic() {
+		return "", errors.New("a private key is required for creating DPoP proofs")
+	}
+
+	alg := settings.Algorithm
+	if alg == "" {
+		alg = key.Algorithm
+	}
+	if alg == "" {
+		alg = jws.DefaultAlgorithm(key.Key)
+	}
+	if !jws.IsAsymmetric(alg) {
+		return "", errors.New("DPoP proofs require an asymmetric signature algorithm")
+	}
+
+	publicJWK, err := key.MarshalPublicJSON()
+	if err != nil {
+		return "", err
+	}
+
+	htu, err := normalizeHTU(uri)
+	if err != nil {
+		return "", err
+	}
+
+	claims := Claims{
+		JTI:   settings.JTI,
+		HTM:   method,
+		HTU:   htu,
+		Nonce: settings.Nonce,
+	}
+	if claims.JTI == "" {
+		claims.JTI, err = randomJTI()
+		if err != nil {
+			return "", err
+		}
+	}
+	if settings.IssuedAt.IsZero() {
+		claims.IAT = time.Now().Unix()
+	} else {
+		claims.IAT = settings.IssuedAt.Unix()
+	}
+	if settings.AccessToken != "" {
+		claims.ATH = AccessTokenHash(settings.AccessToken)
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	header := map[string]interface{}{
+		"typ": ProofType,
+		"jwk": json.RawMessage(publicJWK),
+	}
+	return jws.Sign(alg, key.Key, header, payload)
+}
+
+// AccessTokenHash computes the 'ath' claim value for an access token: the
+// base64url-encoded SHA-256 hash of the token.
+func AccessTokenHash(accessToken string) string {
+	h := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
+
+// JKT computes the 'jkt' value for a key: the base64url-encoded JWK SHA-256
+// thumbprint of the key, which is used for binding access tokens and
+// authorization codes to the key.
+func JKT(key *jwk.KeySpec) (string, error) {
+	thumbprint, err := key.Thumbprint()
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(thumbprint), nil
+}
+
+func randomJTI() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,75 @@
+package dpop
+
+import (
+	"sync"
+	"time"
+)
+
+// ReplayCache remembers the identifiers of DPoP proofs which have already
+// been used, in order to detect replayed proofs.
+//
+// Implementations must be safe for concurrent use. A shared implementation
+// (e.g. backed by Redis) is required when several servers accept proofs for
+// the same resource.
+type ReplayCache interface {
+	// CheckAndStore records the proof identifier id until expiresAt.
+	// It returns true if the identifier was already recorded (and has not
+	// expired yet), which means the proof is replayed.
+	CheckAndStore(id string, expiresAt time.Time) (replayed bool, err error)
+}
+
+// MemoryReplayCache is an in-memory ReplayCache.
+// The zero value is ready for use.
+//
+// Expired entries are removed at most once per minute, so that storing an
+// identifier does not scan the whole cache every time.
+type MemoryReplayCache struct {
+	mu        sync.Mutex
+	entries   map[string]time.Time
+	lastPrune time.Time
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// CheckAndStore implements ReplayCache
+func (c *MemoryReplayCache) CheckAndStore(id string, expiresAt time.Time) (bool, error) {
+	now := time.Now
+	if c.Now != nil {
+		now = c.Now
+	}
+	t := now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]time.Time)
+	}
+	if exp, ok := c.entries[id]; ok && t.Before(exp) {
+		return true, nil
+	}
+	if t.Sub(c.lastPrune) >= pruneInterval {
+		c.prune(t)
+		c.lastPrune = t
+	}
+	c.entries[id] = expiresAt
+	return false, nil
+}
+
+// Len returns the number of proof identifiers currently recorded, including
+// expired identifiers which have not been removed yet.
+func (c *MemoryReplayCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// pruneInterval is the minimum interval between two scans for expired entries
+const pruneInterval = time.Minute
+
+func (c *MemoryReplayCache) prune(t time.Time) {
+	for id, exp := range c.entries {
+		if !t.Before(exp) {
+			delete(c.entries, id)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package dpop
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rakutentech/jwk-go/internal/jws"
+	"github.com/rakutentech/jwk-go/jwk"
+)
+
+var (
+	// ErrInvalidProof is returned (wrapped) for all proofs which fail verification
+	ErrInvalidProof = errors.New("invalid DPoP proof")
+
+	// ErrReplayedProof is returned when a proof has already been used
+	ErrReplayedProof = fmt.Errorf("%w: proof has already been used", ErrInvalidProof)
+)
+
+// Request describes the HTTP request a proof is verified against
+type Request struct {
+	// Method is the HTTP method of the request
+	Method string
+	// URL is the URL of the request (query and fragment are ignored)
+	URL string
+	// AccessToken is the access token sent with the request, if any. When
+	// specified, the proof must contain a matching 'ath' claim.
+	AccessToken string
+	// Nonce is the nonce the server expects in the proof, if any
+	Nonce string
+	// JKT is the expected JWK thumbprint of the proof key, if any, e.g. the
+	// 'cnf.jkt' claim of the access token
+	JKT string
+}
+
+// Verifier verifies DPoP proofs.
+// The zero value is a usable Verifier without replay detection.
+type Verifier struct {
+	// AllowedAlgorithms is a list of allowed signature algorithms. If empty,
+	// all supported asymmetric algorithms are allowed.
+	AllowedAlgorithms []string
+
+	// MaxAge is the maximum age of a proof, based on its 'iat' claim.
+	// Defaults to 5 minutes.
+	MaxAge time.Duration
+
+	// Leeway is the allowed clock skew for proofs issued in the future.
+	// Defaults to 5 seconds.
+	Leeway time.Duration
+
+	// ReplayCache detects replayed proofs. If nil, replays are not detected.
+	ReplayCache ReplayCache
+
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Proof is a verified DPoP proof
+type Proof struct {
+	// Key is the public key of the proof
+	Key *jwk.KeySpec
+	// JKT is the JWK SHA-256 thumbprint of the key
+	JKT string
+	// Algorithm is the signature algorithm of the proof
+	Algorithm string
+	// Claims are the claims of the proof
+	Claims Claims
+}
+
+const (
+	defaultMaxAge = 5 * time.Minute
+	defaultLeeway = 5 * time.Second
+)
+
+// Verify verifies a DPoP proof against the specified request, following
+// the checks described in RFC 9449 section 4.3.
+func (v *Verifier) Verify(proof string, req Request) (*Proof, error) {
+	token, err := jws.Parse(proof)
+	if err != nil {
+		return nil, invalid("%v", err)
+	}
+	if token.Header.Typ != ProofType {
+		return nil, invalid("typ must be %s", ProofType)
+	}
+	alg := token.Header.Alg
+	if !jws.IsAsymmetric(alg) {
+		return nil, invalid("unsupported algorithm: %s", alg)
+	}
+	if len(v.AllowedAlgorithms) > 0 && !slices.Contains(v.AllowedAlgorithms, alg) {
+		return nil, invalid("algorithm not allowed: %s", alg)
+	}
+
+	if len(token.Header.JWK) == 0 {
+		return nil, invalid("missing jwk header")
+	}
+	key, err := jwk.ParseBytes(token.Header.JWK)
+	if err != nil {
+		return nil, invalid("jwk header: %v", err)
+	}
+	if !key.IsPublic() {
+		return nil, invalid("jwk header must contain a public key")
+	}
+	if err := token.Verify(key.Key); err != nil {
+		return nil, invalid("%v", err)
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(token.Payload, &claims); err != nil {
+		return nil, invalid("claims: %v", err)
+	}
+	if claims.JTI == "" || claims.HTM == "" || claims.HTU == "" || claims.IAT == 0 {
+		return nil, invalid("missing jti, htm, htu or iat claims")
+	}
+	if claims.HTM != req.Method {
+		return nil, invalid("htm does not match the request method")
+	}
+	if !matchHTU(claims.HTU, req.URL) {
+		return nil, invalid("htu does not match the request URL")
+	}
+	if req.Nonce != "" && !constantTimeEqual(claims.Nonce, req.Nonce) {
+		return nil, invalid("nonce does not match")
+	}
+	if req.AccessToken != "" && !constantTimeEqual(claims.ATH, AccessTokenHash(req.AccessToken)) {
+		return nil, invalid("ath does not match the access token")
+	}
+
+	now := v.now()
+	iat := time.Unix(claims.IAT, 0)
+	maxAge := v.MaxAge
+	if maxAge <= 0 {
+		maxAge = defaultMaxAge
+	}
+	leeway := v.Leeway
+	if leeway <= 0 {
+		leeway = defaultLeeway
+	}
+	if iat.After(now.Add(leeway)) {
+		return nil, invalid("proof issued in the future")
+	}
+	expiresAt := iat.Add(maxAge)
+	if !now.Before(expiresAt) {
+		return nil, invalid("proof is too old")
+	}
+
+	jkt, err := JKT(key)
+	if err != nil {
+		return nil, invalid("%v", err)
+	}
+	if req.JKT != "" && !constantTimeEqual(jkt, req.JKT) {
+		return nil, invalid("key does not match the bound key thumbprint")
+	}
+
+	if v.ReplayCache != nil {
+		// Scope the proof identifier to the key, so clients cannot interfere
+		// with proofs created by other clients.
+		replayed, err := v.ReplayCache.CheckAndStore(jkt+":"+claims.JTI, expiresAt.Add(leeway))
+		if err != nil {
+			return nil, err
+		}
+		if replayed {
+			return nil, ErrReplayedProof
+		}
+	}
+
+	return &Proof{
+		Key:       key,
+		JKT:       jkt,
+		Algorithm: alg,
+		Claims:    claims,
+	}, nil
+}
+
+func (v *Verifier) now() time.Time {
+	if v.Now != nil {
+		return v.Now()
+	}
+	return time.Now()
+}
+
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidProof, fmt.Sprintf(format, args...))
+}
+
+func constantTimeEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// normalizeHTU normalizes an HTTP URI for use in the 'htu' claim, removing the
+// query and fragment, as well as applying syntax-based normalization
+// (RFC 3986 section 6.2.2) and scheme-based normalization (section 6.2.3).
+func normalizeHTU(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if !u.IsAbs() || u.Host == "" {
+		return "", errors.New("htu must be an absolute HTTP URI")
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (u.Scheme == "https" && port == "443") || (u.Scheme == "http" && port == "80") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]" // IPv6 literal
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.RawQuery = ""
+	u.ForceQuery = false
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.User = nil
+	return u.String(), nil
+}
+
+func matchHTU(htu, requestURL string) bool {
+	a, err := normalizeHTU(htu)
+	if err != nil {
+		return false
+	}
+	b, err := normalizeHTU(requestURL)
+	if err != nil {
+		return false
+	}
+	return a == b
+}
@@ -0,0 +1,358 @@
+// Package hpke implements Hybrid Public Key Encryption (HPKE), as specified by
+// RFC 9180, with keys described by JWKs.
+//
+// The KEM keys are EC KeySpecs (P-256, P-384 or P-521) or X25519 OKPs
+// (okp.Curve25519), and the KEM is chosen according to their curve. The base
+// and auth modes are supported.
+package hpke
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	_ "crypto/sha256" // Register SHA-256 for HKDF-SHA256
+	_ "crypto/sha512" // Register SHA-384 and SHA-512 for HKDF-SHA384/512
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/rakutentech/jwk-go/jwk"
+)
+
+// KDF is an HPKE Key Derivation Function identifier
+type KDF uint16
+
+// Supported KDFs
+const (
+	HKDFSHA256 KDF = 0x0001
+	HKDFSHA384 KDF = 0x0002
+	HKDFSHA512 KDF = 0x0003
+)
+
+// AEAD is an HPKE Authenticated Encryption with Associated Data identifier
+type AEAD uint16
+
+// Supported AEADs
+const (
+	AES128GCM        AEAD = 0x0001
+	AES256GCM        AEAD = 0x0002
+	ChaCha20Poly1305 AEAD = 0x0003
+	// ExportOnly contexts can only be used with Export()
+	ExportOnly AEAD = 0xffff
+)
+
+const (
+	modeBase byte = 0x00
+	modeAuth byte = 0x02
+)
+
+var (
+	// ErrOpen is returned when a ciphertext cannot be decrypted
+	ErrOpen = errors.New("HPKE message authentication failed")
+
+	// ErrMessageLimitReached is returned when the sequence number of a
+	// context is exhausted
+	ErrMessageLimitReached = errors.New("HPKE message limit reached")
+)
+
+// Settings contains optional settings for NewSender() and NewRecipient()
+type Settings struct {
+	// KDF is the key derivation function of the key schedule. Defaults to
+	// HKDFSHA256.
+	KDF KDF
+
+	// AEAD is the encryption algorithm. Defaults to AES128GCM.
+	AEAD AEAD
+
+	// Info is application-supplied information bound to the context
+	Info []byte
+
+	// SenderKey enables the auth mode, which authenticates the sender: it is
+	// the private key of the sender for NewSender(), and the public key of the
+	// sender for NewRecipient(). It must use the curve of the recipient key.
+	SenderKey *jwk.KeySpec
+}
+
+func (settings *Settings) suite() (KDF, AEAD) {
+	kdf, aead := settings.KDF, settings.AEAD
+	if kdf == 0 {
+		kdf = HKDFSHA256
+	}
+	if aead == 0 {
+		aead = AES128GCM
+	}
+	return kdf, aead
+}
+
+// Sender is an HPKE context for encrypting messages to a recipient. Its
+// methods must not be called concurrently.
+type Sender struct {
+	context
+}
+
+// Recipient is an HPKE context for decrypting messages from a sender. Its
+// methods must not be called concurrently.
+type Recipient struct {
+	context
+}
+
+// NewSender sets up an HPKE context for encrypting messages to the public key
+// of recipient, and returns it together with the encapsulated key, which must
+// be sent to the recipient.
+func NewSender(recipient *jwk.KeySpec, settings Settings) (enc []byte, sender *Sender, err error) {
+	return newSender(recipient, settings, nil)
+}
+
+// newSender implements NewSender. ephemeral is only set by tests, to reproduce
+// the test vectors.
+func newSender(recipient *jwk.KeySpec, settings Settings, ephemeral *ecdh.PrivateKey) (enc []byte, sender *Sender, err error) {
+	recipientKey, err := recipient.ECDHPublicKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	kem, err := kemForCurve(recipientKey.Curve())
+	if err != nil {
+		return nil, nil, err
+	}
+	mode := modeBase
+	var senderKey *ecdh.PrivateKey
+	if settings.SenderKey != nil {
+		mode = modeAuth
+		if senderKey, err = settings.SenderKey.ECDHPrivateKey(); err != nil {
+			return nil, nil, err
+		}
+		if senderKey.Curve() != recipientKey.Curve() {
+			return nil, nil, errors.New("the sender key must use the curve of the recipient key")
+		}
+	}
+
+	sharedSecret, enc, err := kem.encap(recipientKey, senderKey, ephemeral)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, err := keySchedule(mode, kem, &settings, sharedSecret)
+	if err != nil {
+		return nil, nil, err
+	}
+	return enc, &Sender{*ctx}, nil
+}
+
+// NewRecipient sets up an HPKE context for decrypting messages sent to the
+// private key of recipient, from the encapsulated key sent by the sender.
+func NewRecipient(enc []byte, recipient *jwk.KeySpec, settings Settings) (*Recipient, error) {
+	recipientKey, err := recipient.ECDHPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	kem, err := kemForCurve(recipientKey.Curve())
+	if err != nil {
+		return nil, err
+	}
+	mode := modeBase
+	var senderKey *ecdh.PublicKey
+	if settings.SenderKey != nil {
+		mode = modeAuth
+		if senderKey, err = settings.SenderKey.ECDHPublicKey(); err != nil {
+			return nil, err
+		}
+		if senderKey.Curve() != recipientKey.Curve() {
+			return nil, errors.New("the sender key must use the curve of the recipient key")
+		}
+	}
+
+	sharedSecret, err := kem.decap(enc, recipientKey, senderKey)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err := keySchedule(mode, kem, &settings, sharedSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &Recipient{*ctx}, nil
+}
+
+// Seal encrypts a single message to recipient, and returns the encapsulated
+// key and the ciphertext.
+func Seal(recipient *jwk.KeySpec, settings Settings, aad, plaintext []byte) (enc, ciphertext []byte, err error) {
+	enc, sender, err := NewSender(recipient, settings)
+	if err != nil {
+		return nil, nil, err
+	}
+	ciphertext, err = sender.Seal(aad, plaintext)
+	if err != nil {
+		return nil, nil, err
+	}
+	return enc, ciphertext, nil
+}
+
+// Open decrypts a single message encrypted by Seal() with the private key of
+// recipient.
+func Open(recipient *jwk.KeySpec, enc []byte, settings Settings, aad, ciphertext []byte) ([]byte, error) {
+	r, err := NewRecipient(enc, recipient, settings)
+	if err != nil {
+		return nil, err
+	}
+	return r.Open(aad, ciphertext)
+}
+
+// Seal encrypts and authenticates the next message of the context, and
+// authenticates aad.
+func (s *Sender) Seal(aad, plaintext []byte) ([]byte, error) {
+	nonce, err := s.nextNonce()
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := s.aead.Seal(nil, nonce, plaintext, aad)
+	s.seq++
+	return ciphertext, nil
+}
+
+// Open decrypts the next message of the context, and checks that it and aad
+// have not been tampered with. ErrOpen is returned otherwise.
+func (r *Recipient) Open(aad, ciphertext []byte) ([]byte, error) {
+	nonce, err := r.nextNonce()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := r.aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, ErrOpen
+	}
+	r.seq++
+	return plaintext, nil
+}
+
+// context is the encryption context shared by Sender and Recipient
+// (RFC 9180 section 5.2)
+type context struct {
+	kdf            kdf
+	aead           cipher.AEAD
+	baseNonce      []byte
+	seq            uint64
+	exporterSecret []byte
+}
+
+// Export derives a secret of the specified length from the context and
+// exporterContext (RFC 9180 section 5.3).
+func (c *context) Export(exporterContext []byte, length int) ([]byte, error) {
+	if length < 0 || length > 255*c.kdf.hash.Size() {
+		return nil, fmt.Errorf("cannot export %d bytes", length)
+	}
+	return c.kdf.labeledExpand(c.exporterSecret, "sec", exporterContext, length), nil
+}
+
+func (c *context) nextNonce() ([]byte, error) {
+	if c.aead == nil {
+		return nil, errors.New("export-only HPKE contexts cannot encrypt messages")
+	}
+	if c.seq == math.MaxUint64 {
+		return nil, ErrMessageLimitReached
+	}
+	nonce := make([]byte, len(c.baseNonce))
+	copy(nonce, c.baseNonce)
+	seq := binary.BigEndian.AppendUint64(nil, c.seq)
+	for i := range seq {
+		nonce[len(nonce)-len(seq)+i] ^= seq[i]
+	}
+	return nonce, nil
+}
+
+// keySchedule creates the encryption context from the shared secret
+// (RFC 9180 section 5.1)
+func keySchedule(mode byte, kem *dhKEM, settings *Settings, sharedSecret []byte) (*context, error) {
+	defer clear(sharedSecret)
+	kdfID, aeadID := settings.suite()
+	suiteID := binary.BigEndian.AppendUint16([]byte("HPKE"), uint16(kem.id))
+	suiteID = binary.BigEndian.AppendUint16(suiteID, uint16(kdfID))
+	suiteID = binary.BigEndian.AppendUint16(suiteID, uint16(aeadID))
+
+	var hash crypto.Hash
+	switch kdfID {
+	case HKDFSHA256:
+		hash = crypto.SHA256
+	case HKDFSHA384:
+		hash = crypto.SHA384
+	case HKDFSHA512:
+		hash = crypto.SHA512
+	default:
+		return nil, fmt.Errorf("unsupported HPKE KDF: 0x%04x", uint16(kdfID))
+	}
+	kdf := kdf{hash, suiteID}
+
+	keySize := 0
+	switch aeadID {
+	case AES128GCM:
+		keySize = 16
+	case AES256GCM, ChaCha20Poly1305:
+		keySize = 32
+	case ExportOnly:
+	default:
+		return nil, fmt.Errorf("unsupported HPKE AEAD: 0x%04x", uint16(aeadID))
+	}
+
+	keyScheduleContext := []byte{mode}
+	keyScheduleContext = append(keyScheduleContext, kdf.labeledExtract(nil, "psk_id_hash", nil)...)
+	keyScheduleContext = append(keyScheduleContext, kdf.labeledExtract(nil, "info_hash", settings.Info)...)
+	secret := kdf.labeledExtract(sharedSecret, "secret", nil)
+	defer clear(secret)
+
+	ctx := &context{
+		kdf:            kdf,
+		baseNonce:      kdf.labeledExpand(secret, "base_nonce", keyScheduleContext, 12),
+		exporterSecret: kdf.labeledExpand(secret, "exp", keyScheduleContext, hash.Size()),
+	}
+	if keySize == 0 {
+		return ctx, nil
+	}
+	key := kdf.labeledExpand(secret, "key", keyScheduleContext, keySize)
+	defer clear(key)
+	var err error
+	if aeadID == ChaCha20Poly1305 {
+		ctx.aead, err = chacha20poly1305.New(key)
+	} else {
+		ctx.aead, err = newGCM(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// kdf implements the labeled HKDF functions of RFC 9180 section 4
+type kdf struct {
+	hash    crypto.Hash
+	suiteID []byte
+}
+
+func (kdf kdf) labeledExtract(salt []byte, label string, ikm []byte) []byte {
+	labeledIKM := append([]byte("HPKE-v1"), kdf.suiteID...)
+	labeledIKM = append(append(labeledIKM, label...), ikm...)
+	defer clear(labeledIKM)
+	return hkdf.Extract(kdf.hash.New, labeledIKM, salt)
+}
+
+func (kdf kdf) labeledExpand(prk []byte, label string, info []byte, length int) []byte {
+	labeledInfo := binary.BigEndian.AppendUint16(nil, uint16(length))
+	labeledInfo = append(append(labeledInfo, "HPKE-v1"...), kdf.suiteID...)
+	labeledInfo = append(append(labeledInfo, label...), info...)
+	out := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.Expand(kdf.hash.New, prk, labeledInfo), out); err != nil {
+		// The length is checked by the callers
+		panic(err)
+	}
+	return out
+}
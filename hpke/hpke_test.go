@@ -0,0 +1,232 @@
+package hpke
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/sha3"
+
+	"github.com/rakutentech/jwk-go/internal/testutils"
+	"github.com/rakutentech/jwk-go/jwk"
+	"github.com/rakutentech/jwk-go/okp"
+)
+
+func mustDecodeHex(s string) []byte {
+	return testutils.Must(hex.DecodeString(s))
+}
+
+// keySpecFromHex creates a KeySpec from a serialized KEM key pair
+func keySpecFromHex(kem KEM, privateKey, publicKey string) *jwk.KeySpec {
+	sk, pk := mustDecodeHex(privateKey), mustDecodeHex(publicKey)
+	var curve elliptic.Curve
+	switch kem {
+	case DHKEMX25519:
+		return jwk.NewSpec(okp.NewCurve25519(pk, sk))
+	case DHKEMP256:
+		curve = elliptic.P256()
+	case DHKEMP384:
+		curve = elliptic.P384()
+	case DHKEMP521:
+		curve = elliptic.P521()
+	}
+	size := (len(pk) - 1) / 2
+	return jwk.NewSpec(&ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(pk[1 : 1+size]),
+			Y:     new(big.Int).SetBytes(pk[1+size:]),
+		},
+		D: new(big.Int).SetBytes(sk),
+	})
+}
+
+// deriveKeyPair derives a key pair from the input keying material ikm, as
+// specified by RFC 9180 section 7.1.3
+func (kem *dhKEM) deriveKeyPair(ikm []byte) (*ecdh.PrivateKey, error) {
+	kdf := kdf{kem.hash, kem.suiteID()}
+	prk := kdf.labeledExtract(nil, "dkp_prk", ikm)
+	if kem.curve == ecdh.X25519() {
+		return kem.curve.NewPrivateKey(kdf.labeledExpand(prk, "sk", nil, kem.privateKeySize))
+	}
+	for counter := 0; counter < 256; counter++ {
+		candidate := kdf.labeledExpand(prk, "candidate", []byte{byte(counter)}, kem.privateKeySize)
+		candidate[0] &= kem.bitmask
+		// NewPrivateKey rejects zero and scalars not lower than the order
+		if privateKey, err := kem.curve.NewPrivateKey(candidate); err == nil {
+			return privateKey, nil
+		}
+	}
+	return nil, errors.New("cannot derive a key pair")
+}
+
+// kemOf returns the KEM of a key
+func kemOf(k *jwk.KeySpec) *dhKEM {
+	return testutils.Must(kemForCurve(testutils.Must(k.ECDHPublicKey()).Curve()))
+}
+
+// drawInput reads a length-prefixed input from r
+func drawInput(r io.Reader) []byte {
+	var length [1]byte
+	testutils.Must(r.Read(length[:]))
+	input := make([]byte, length[0])
+	testutils.Must(r.Read(input))
+	return input
+}
+
+var info = []byte("Ode on a Grecian Urn")
+
+var _ = Describe("HPKE", func() {
+	It("Should pass the RFC 9180 base mode test vectors", func() {
+		for _, vector := range baseModeVectors {
+			recipientKey := keySpecFromHex(vector.kem, vector.skRm, vector.pkRm)
+			kem := kemOf(recipientKey)
+			Expect(kem.id).To(Equal(vector.kem))
+			derived := testutils.Must(kem.deriveKeyPair(mustDecodeHex(vector.ikmR)))
+			Expect(derived.PublicKey().Bytes()).To(Equal(mustDecodeHex(vector.pkRm)))
+
+			settings := Settings{KDF: vector.kdf, AEAD: vector.aead, Info: info}
+			ephemeral := testutils.Must(kem.deriveKeyPair(mustDecodeHex(vector.ikmE)))
+			enc, sender := testutils.Must2(newSender(testutils.Must(recipientKey.PublicOnly()), settings, ephemeral))
+			Expect(hex.EncodeToString(enc)).To(Equal(vector.enc))
+			recipient := testutils.Must(NewRecipient(enc, recipientKey, settings))
+
+			if vector.aead != ExportOnly {
+				source, sink := sha3.NewShake128(), sha3.NewShake128()
+				for i := 0; i < 1000; i++ {
+					aad, plaintext := drawInput(source), drawInput(source)
+					ciphertext := testutils.Must(sender.Seal(aad, plaintext))
+					sink.Write(ciphertext)
+					Expect(recipient.Open(aad, ciphertext)).To(Equal(plaintext))
+				}
+				Expect(hex.EncodeToString(testutils.Must(io.ReadAll(io.LimitReader(sink, 16))))).
+					To(Equal(vector.encryptions))
+			} else {
+				_, err := sender.Seal(nil, nil)
+				Expect(err).To(MatchError("export-only HPKE contexts cannot encrypt messages"))
+			}
+
+			source, sink := sha3.NewShake128(), sha3.NewShake128()
+			for length := 0; length < 1000; length++ {
+				exporterContext := drawInput(source)
+				exported := testutils.Must(sender.Export(exporterContext, length))
+				sink.Write(exported)
+				Expect(recipient.Export(exporterContext, length)).To(Equal(exported))
+			}
+			Expect(hex.EncodeToString(testutils.Must(io.ReadAll(io.LimitReader(sink, 16))))).To(Equal(vector.exports))
+		}
+	})
+
+	It("Should pass the first encryption of RFC 9180 appendix A.1.1", func() {
+		vector := baseModeVectors[0]
+		recipientKey := keySpecFromHex(DHKEMX25519, vector.skRm, vector.pkRm)
+		ephemeral := testutils.Must(kemOf(recipientKey).deriveKeyPair(mustDecodeHex(vector.ikmE)))
+		enc, sender := testutils.Must2(newSender(recipientKey, Settings{Info: info}, ephemeral))
+		ciphertext := testutils.Must(sender.Seal([]byte("Count-0"), []byte("Beauty is truth, truth beauty")))
+		Expect(hex.EncodeToString(ciphertext)).To(Equal("f938558b5d72f1a23810b4be2ab4f84331acc02fc97babc5" +
+			"3a52ae8218a355a96d8770ac83d07bea87e13c512a"))
+		Expect(Open(recipientKey, enc, Settings{Info: info}, []byte("Count-0"), ciphertext)).
+			To(Equal([]byte("Beauty is truth, truth beauty")))
+	})
+
+	It("Should pass the RFC 9180 auth mode test vectors", func() {
+		aad, plaintext := []byte("Count-0"), []byte("Beauty is truth, truth beauty")
+		for _, vector := range authModeVectors {
+			recipientKey := keySpecFromHex(vector.kem, vector.skRm, vector.pkRm)
+			senderKey := keySpecFromHex(vector.kem, vector.skSm, vector.pkSm)
+			ephemeral := testutils.Must(kemOf(recipientKey).curve.NewPrivateKey(mustDecodeHex(vector.skEm)))
+			enc, sender := testutils.Must2(newSender(testutils.Must(recipientKey.PublicOnly()),
+				Settings{Info: info, SenderKey: senderKey}, ephemeral))
+			ciphertext := testutils.Must(sender.Seal(aad, plaintext))
+			Expect(hex.EncodeToString(enc)).To(Equal(vector.enc))
+			Expect(hex.EncodeToString(ciphertext)).To(Equal(vector.ciphertext))
+
+			settings := Settings{Info: info, SenderKey: testutils.Must(senderKey.PublicOnly())}
+			Expect(Open(recipientKey, enc, settings, aad, ciphertext)).To(Equal(plaintext))
+		}
+	})
+
+	DescribeTable("Should authenticate the sender in auth mode",
+		func(generate func() *jwk.KeySpec) {
+			recipientKey, senderKey, otherKey := generate(), generate(), generate()
+			enc, sender := testutils.Must2(NewSender(testutils.Must(recipientKey.PublicOnly()),
+				Settings{Info: info, SenderKey: senderKey}))
+			ciphertext := testutils.Must(sender.Seal(nil, []byte("message")))
+
+			publicSenderKey := testutils.Must(senderKey.PublicOnly())
+			recipient := testutils.Must(NewRecipient(enc, recipientKey, Settings{Info: info, SenderKey: publicSenderKey}))
+			Expect(recipient.Open(nil, ciphertext)).To(Equal([]byte("message")))
+			Expect(recipient.Export([]byte("context"), 32)).To(Equal(testutils.Must(sender.Export([]byte("context"), 32))))
+
+			for _, settings := range []Settings{
+				{Info: info},
+				{Info: info, SenderKey: testutils.Must(otherKey.PublicOnly())},
+				{Info: []byte("other info"), SenderKey: publicSenderKey},
+			} {
+				_, err := Open(recipientKey, enc, settings, nil, ciphertext)
+				Expect(err).To(Equal(ErrOpen))
+			}
+		},
+		Entry("X25519", func() *jwk.KeySpec { return jwk.NewSpec(testutils.Must(okp.GenerateCurve25519(rand.Reader))) }),
+		Entry("P-256", func() *jwk.KeySpec {
+			return jwk.NewSpec(testutils.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader)))
+		}),
+	)
+
+	It("Should open messages in order", func() {
+		recipientKey := jwk.NewSpec(testutils.Must(okp.GenerateCurve25519(rand.Reader)))
+		enc, sender := testutils.Must2(NewSender(recipientKey, Settings{AEAD: ChaCha20Poly1305}))
+		first := testutils.Must(sender.Seal([]byte("aad"), []byte("first")))
+		second := testutils.Must(sender.Seal([]byte("aad"), []byte("second")))
+		Expect(first).NotTo(Equal(second))
+
+		recipient := testutils.Must(NewRecipient(enc, recipientKey, Settings{AEAD: ChaCha20Poly1305}))
+		_, err := recipient.Open([]byte("aad"), second)
+		Expect(err).To(Equal(ErrOpen))
+		_, err = recipient.Open(nil, first)
+		Expect(err).To(Equal(ErrOpen))
+		Expect(recipient.Open([]byte("aad"), first)).To(Equal([]byte("first")))
+		Expect(recipient.Open([]byte("aad"), second)).To(Equal([]byte("second")))
+
+		recipient.seq = math.MaxUint64
+		_, err = recipient.Open([]byte("aad"), second)
+		Expect(err).To(Equal(ErrMessageLimitReached))
+	})
+
+	It("Should reject invalid keys and settings", func() {
+		x25519 := jwk.NewSpec(testutils.Must(okp.GenerateCurve25519(rand.Reader)))
+		p256 := jwk.NewSpec(testutils.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader)))
+
+		_, _, err := NewSender(x25519, Settings{SenderKey: p256})
+		Expect(err).To(MatchError("the sender key must use the curve of the recipient key"))
+		_, err = NewRecipient(make([]byte, 32), x25519, Settings{SenderKey: p256})
+		Expect(err).To(MatchError("the sender key must use the curve of the recipient key"))
+		_, _, err = NewSender(x25519, Settings{SenderKey: testutils.Must(x25519.PublicOnly())})
+		Expect(err).To(MatchError("a private key is required for ECDH"))
+		_, _, err = NewSender(jwk.NewSpec(testutils.Must(okp.GenerateEd25519(rand.Reader))), Settings{})
+		Expect(err).To(MatchError("curve Ed25519 does not support ECDH"))
+		_, _, err = NewSender(x25519, Settings{KDF: 0x0010})
+		Expect(err).To(MatchError("unsupported HPKE KDF: 0x0010"))
+		_, _, err = NewSender(x25519, Settings{AEAD: 0x0004})
+		Expect(err).To(MatchError("unsupported HPKE AEAD: 0x0004"))
+
+		// Low-order point and point not on the curve
+		_, err = NewRecipient(make([]byte, 32), x25519, Settings{})
+		Expect(err).To(HaveOccurred())
+		_, err = NewRecipient(bytes.Repeat([]byte{4}, 65), p256, Settings{})
+		Expect(err).To(MatchError(ContainSubstring("invalid encapsulated key")))
+
+		_, sender := testutils.Must2(NewSender(x25519, Settings{}))
+		_, err = sender.Export(nil, 255*32+1)
+		Expect(err).To(MatchError("cannot export 8161 bytes"))
+	})
+})
@@ -0,0 +1,110 @@
+package hpke
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+)
+
+// KEM is an HPKE Key Encapsulation Mechanism identifier
+type KEM uint16
+
+// Supported KEMs. The KEM is determined by the curve of the recipient key.
+const (
+	DHKEMP256   KEM = 0x0010 // DHKEM(P-256, HKDF-SHA256)
+	DHKEMP384   KEM = 0x0011 // DHKEM(P-384, HKDF-SHA384)
+	DHKEMP521   KEM = 0x0012 // DHKEM(P-521, HKDF-SHA512)
+	DHKEMX25519 KEM = 0x0020 // DHKEM(X25519, HKDF-SHA256)
+)
+
+// dhKEM contains the parameters of a DHKEM (RFC 9180 section 7.1)
+type dhKEM struct {
+	id    KEM
+	curve ecdh.Curve
+	hash  crypto.Hash
+	// secretSize is Nsecret, privateKeySize is Nsk
+	secretSize, privateKeySize int
+	// bitmask is applied to the first byte of candidate NIST private keys
+	bitmask byte
+}
+
+var dhKEMs = []dhKEM{
+	{DHKEMP256, ecdh.P256(), crypto.SHA256, 32, 32, 0xff},
+	{DHKEMP384, ecdh.P384(), crypto.SHA384, 48, 48, 0xff},
+	{DHKEMP521, ecdh.P521(), crypto.SHA512, 64, 66, 0x01},
+	{DHKEMX25519, ecdh.X25519(), crypto.SHA256, 32, 32, 0},
+}
+
+func kemForCurve(curve ecdh.Curve) (*dhKEM, error) {
+	for i := range dhKEMs {
+		if dhKEMs[i].curve == curve {
+			return &dhKEMs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported curve for HPKE: %v", curve)
+}
+
+func (kem *dhKEM) suiteID() []byte {
+	return binary.BigEndian.AppendUint16([]byte("KEM"), uint16(kem.id))
+}
+
+// encap generates the shared secret and its encapsulation for the recipient
+// key. If the sender key is not nil, the encapsulation is authenticated
+// (AuthEncap). The ephemeral key is generated if it is nil, which is always
+// the case outside of tests.
+func (kem *dhKEM) encap(recipient *ecdh.PublicKey, sender, ephemeral *ecdh.PrivateKey) (sharedSecret, enc []byte, err error) {
+	if ephemeral == nil {
+		if ephemeral, err = kem.curve.GenerateKey(rand.Reader); err != nil {
+			return nil, nil, err
+		}
+	}
+	dh, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, nil, err
+	}
+	enc = ephemeral.PublicKey().Bytes()
+	kemContext := append(enc[:len(enc):len(enc)], recipient.Bytes()...)
+	if sender != nil {
+		staticDH, err := sender.ECDH(recipient)
+		if err != nil {
+			return nil, nil, err
+		}
+		dh = append(dh, staticDH...)
+		kemContext = append(kemContext, sender.PublicKey().Bytes()...)
+	}
+	return kem.extractAndExpand(dh, kemContext), enc, nil
+}
+
+// decap recovers the shared secret from its encapsulation with the recipient
+// key. If the sender key is not nil, the encapsulation must have been
+// authenticated with the matching private key (AuthDecap).
+func (kem *dhKEM) decap(enc []byte, recipient *ecdh.PrivateKey, sender *ecdh.PublicKey) ([]byte, error) {
+	ephemeral, err := kem.curve.NewPublicKey(enc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encapsulated key: %w", err)
+	}
+	dh, err := recipient.ECDH(ephemeral)
+	if err != nil {
+		return nil, err
+	}
+	kemContext := append(enc[:len(enc):len(enc)], recipient.PublicKey().Bytes()...)
+	if sender != nil {
+		staticDH, err := recipient.ECDH(sender)
+		if err != nil {
+			return nil, err
+		}
+		dh = append(dh, staticDH...)
+		kemContext = append(kemContext, sender.Bytes()...)
+	}
+	return kem.extractAndExpand(dh, kemContext), nil
+}
+
+func (kem *dhKEM) extractAndExpand(dh, kemContext []byte) []byte {
+	defer clear(dh)
+	kdf := kdf{kem.hash, kem.suiteID()}
+	prk := kdf.labeledExtract(nil, "eae_prk", dh)
+	defer clear(prk)
+	return kdf.labeledExpand(prk, "shared_secret", kemContext, kem.secretSize)
+}
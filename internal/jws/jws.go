@@ -0,0 +1,332 @@
+// Package jws implements the subset of JSON Web Signatures (RFC 7515) used
+// inside this module: compact serialization with a single signature.
+//
+// Keys are the same key types used by jwk.KeySpec: *rsa.PrivateKey,
+// *rsa.PublicKey, *ecdsa.PrivateKey, *ecdsa.PublicKey, okp.Ed25519 and
+// []byte (for HMAC).
+package jws
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256" // Register SHA-256 for RS256, PS256, ES256 and HS256
+	_ "crypto/sha512" // Register SHA-384 and SHA-512 for the other algorithms
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/rakutentech/jwk-go/okp"
+)
+
+var (
+	// ErrInvalidSignature is returned when a signature does not verify
+	ErrInvalidSignature = errors.New("invalid signature")
+
+	// ErrMalformed is returned when a token is not a valid compact JWS
+	ErrMalformed = errors.New("malformed compact JWS")
+)
+
+type algorithm struct {
+	hash   crypto.Hash
+	family string
+	curve  string // Expected curve for ECDSA algorithms
+}
+
+const (
+	familyRSA   = "RS"
+	familyPSS   = "PS"
+	familyEC    = "ES"
+	familyEdDSA = "EdDSA"
+	familyHMAC  = "HS"
+)
+
+var algorithms = map[string]algorithm{
+	"RS256": {crypto.SHA256, familyRSA, ""},
+	"RS384": {crypto.SHA384, familyRSA, ""},
+	"RS512": {crypto.SHA512, familyRSA, ""},
+	"PS256": {crypto.SHA256, familyPSS, ""},
+	"PS384": {crypto.SHA384, familyPSS, ""},
+	"PS512": {crypto.SHA512, familyPSS, ""},
+	"ES256": {crypto.SHA256, familyEC, "P-256"},
+	"ES384": {crypto.SHA384, familyEC, "P-384"},
+	"ES512": {crypto.SHA512, familyEC, "P-521"},
+	"EdDSA": {0, familyEdDSA, ""},
+	"HS256": {crypto.SHA256, familyHMAC, ""},
+	"HS384": {crypto.SHA384, familyHMAC, ""},
+	"HS512": {crypto.SHA512, familyHMAC, ""},
+}
+
+// IsSupported returns true if alg is a supported signature algorithm.
+func IsSupported(alg string) bool {
+	_, ok := algorithms[alg]
+	return ok
+}
+
+// IsAsymmetric returns true if alg is a supported asymmetric (public key)
+// signature algorithm.
+func IsAsymmetric(alg string) bool {
+	a, ok := algorithms[alg]
+	return ok && a.family != familyHMAC
+}
+
+// DefaultAlgorithm returns the default signature algorithm for the key, or
+// an empty string if the key cannot be used for signatures.
+func DefaultAlgorithm(key interface{}) string {
+	switch k := key.(type) {
+	case *rsa.PrivateKey, *rsa.PublicKey:
+		return "RS256"
+	case *ecdsa.PrivateKey:
+		return DefaultAlgorithm(&k.PublicKey)
+	case *ecdsa.PublicKey:
+		for name, a := range algorithms {
+			if a.family == familyEC && a.curve == k.Curve.Params().Name {
+				return name
+			}
+		}
+		return ""
+	case okp.Ed25519:
+		return "EdDSA"
+	case []byte:
+		return "HS256"
+	default:
+		return ""
+	}
+}
+
+// Sign creates a compact JWS over payload. The 'alg' member of the header is
+// always set to alg.
+func Sign(alg string, key interface{}, header map[string]interface{}, payload []byte) (string, error) {
+	a, ok := algorithms[alg]
+	if !ok {
+		return "", fmt.Errorf("unsupported signature algorithm: %s", alg)
+	}
+
+	h := make(map[string]interface{}, len(header)+1)
+	for name, value := range header {
+		h[name] = value
+	}
+	h["alg"] = alg
+	headerJSON, err := json.Marshal(h)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(base64.RawURLEncoding.EncodeToString(headerJSON))
+	sb.WriteByte('.')
+	sb.WriteString(base64.RawURLEncoding.EncodeToString(payload))
+	signingInput := sb.String()
+
+	sig, err := a.sign(key, []byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	sb.WriteByte('.')
+	sb.WriteString(base64.RawURLEncoding.EncodeToString(sig))
+	return sb.String(), nil
+}
+
+// Token is a parsed compact JWS which has not been verified yet.
+type Token struct {
+	// Header is the decoded JOSE header
+	Header Header
+	// RawHeader is the JSON of the JOSE header
+	RawHeader []byte
+	// Payload is the decoded payload
+	Payload []byte
+
+	signingInput []byte
+	signature    []byte
+}
+
+// Header contains the JOSE header members used inside this module.
+// Other members are available in Token.RawHeader.
+type Header struct {
+	Alg  string          `json:"alg"`
+	Kid  string          `json:"kid,omitempty"`
+	Typ  string          `json:"typ,omitempty"`
+	Cty  string          `json:"cty,omitempty"`
+	JWK  json.RawMessage `json:"jwk,omitempty"`
+	Crit []string        `json:"crit,omitempty"`
+}
+
+// Parse parses a compact JWS without verifying its signature.
+// Tokens with critical header parameters ('crit') are rejected, since none
+// are supported.
+func Parse(token string) (*Token, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrMalformed, err)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: payload: %v", ErrMalformed, err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrMalformed, err)
+	}
+	t := &Token{
+		RawHeader:    rawHeader,
+		Payload:      payload,
+		signingInput: []byte(token[:len(parts[0])+1+len(parts[1])]),
+		signature:    signature,
+	}
+	if err := json.Unmarshal(rawHeader, &t.Header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrMalformed, err)
+	}
+	if t.Header.Alg == "" {
+		return nil, fmt.Errorf("%w: missing alg", ErrMalformed)
+	}
+	if len(t.Header.Crit) > 0 {
+		return nil, fmt.Errorf("unsupported critical header parameters: %v", t.Header.Crit)
+	}
+	return t, nil
+}
+
+// Verify verifies the signature of the token with key, using the algorithm
+// specified in the header. The caller is responsible for checking that the
+// algorithm is acceptable.
+func (t *Token) Verify(key interface{}) error {
+	a, ok := algorithms[t.Header.Alg]
+	if !ok {
+		return fmt.Errorf("unsupported signature algorithm: %s", t.Header.Alg)
+	}
+	return a.verify(key, t.signingInput, t.signature)
+}
+
+func (a algorithm) digest(data []byte) []byte {
+	h := a.hash.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func (a algorithm) sign(keyInterface interface{}, data []byte) ([]byte, error) {
+	switch a.family {
+	case familyRSA, familyPSS:
+		key, ok := keyInterface.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("RSA private key required")
+		}
+		if a.family == familyPSS {
+			opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}
+			return rsa.SignPSS(rand.Reader, key, a.hash, a.digest(data), opts)
+		}
+		return rsa.SignPKCS1v15(rand.Reader, key, a.hash, a.digest(data))
+	case familyEC:
+		key, ok := keyInterface.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("EC private key required")
+		}
+		if key.Curve.Params().Name != a.curve {
+			return nil, fmt.Errorf("curve %s required", a.curve)
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, key, a.digest(data))
+		if err != nil {
+			return nil, err
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		sig := make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+		return sig, nil
+	case familyEdDSA:
+		key, ok := keyInterface.(okp.Ed25519)
+		if !ok || key.PrivateKey() == nil {
+			return nil, errors.New("Ed25519 private key required")
+		}
+		return ed25519.Sign(ed25519.NewKeyFromSeed(key.PrivateKey()), data), nil
+	case familyHMAC:
+		key, ok := keyInterface.([]byte)
+		if !ok {
+			return nil, errors.New("symmetric key required")
+		}
+		mac := hmac.New(a.hash.New, key)
+		mac.Write(data)
+		return mac.Sum(nil), nil
+	default:
+		return nil, errors.New("unsupported algorithm family")
+	}
+}
+
+func (a algorithm) verify(keyInterface interface{}, data, sig []byte) error {
+	switch a.family {
+	case familyRSA, familyPSS:
+		var key *rsa.PublicKey
+		switch k := keyInterface.(type) {
+		case *rsa.PublicKey:
+			key = k
+		case *rsa.PrivateKey:
+			key = &k.PublicKey
+		default:
+			return errors.New("RSA key required")
+		}
+		var err error
+		if a.family == familyPSS {
+			opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}
+			err = rsa.VerifyPSS(key, a.hash, a.digest(data), sig, opts)
+		} else {
+			err = rsa.VerifyPKCS1v15(key, a.hash, a.digest(data), sig)
+		}
+		if err != nil {
+			return ErrInvalidSignature
+		}
+		return nil
+	case familyEC:
+		var key *ecdsa.PublicKey
+		switch k := keyInterface.(type) {
+		case *ecdsa.PublicKey:
+			key = k
+		case *ecdsa.PrivateKey:
+			key = &k.PublicKey
+		default:
+			return errors.New("EC key required")
+		}
+		if key.Curve.Params().Name != a.curve {
+			return fmt.Errorf("curve %s required", a.curve)
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return ErrInvalidSignature
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(key, a.digest(data), r, s) {
+			return ErrInvalidSignature
+		}
+		return nil
+	case familyEdDSA:
+		key, ok := keyInterface.(okp.Ed25519)
+		if !ok || len(key.PublicKey()) != ed25519.PublicKeySize {
+			return errors.New("Ed25519 key required")
+		}
+		if !ed25519.Verify(key.PublicKey(), data, sig) {
+			return ErrInvalidSignature
+		}
+		return nil
+	case familyHMAC:
+		key, ok := keyInterface.([]byte)
+		if !ok {
+			return errors.New("symmetric key required")
+		}
+		mac := hmac.New(a.hash.New, key)
+		mac.Write(data)
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return ErrInvalidSignature
+		}
+		return nil
+	default:
+		return errors.New("unsupported algorithm family")
+	}
+}
@@ -0,0 +1,88 @@
+package jws
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rakutentech/jwk-go/internal/testutils"
+	"github.com/rakutentech/jwk-go/okp"
+)
+
+var _ = Describe("JWS", func() {
+	rsaKey := testutils.Must(rsa.GenerateKey(rand.Reader, 2048))
+	p256Key := testutils.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader))
+	p521Key := testutils.Must(ecdsa.GenerateKey(elliptic.P521(), rand.Reader))
+	ed25519Key := testutils.Must(okp.GenerateEd25519(rand.Reader))
+	hmacKey := []byte("0123456789abcdef0123456789abcdef")
+
+	DescribeTable("Should sign and verify",
+		func(alg string, key interface{}, publicKey interface{}) {
+			token, err := Sign(alg, key, map[string]interface{}{"typ": "test"}, []byte("payload"))
+			Expect(err).To(Succeed())
+
+			t, err := Parse(token)
+			Expect(err).To(Succeed())
+			Expect(t.Header.Alg).To(Equal(alg))
+			Expect(t.Header.Typ).To(Equal("test"))
+			Expect(t.Payload).To(Equal([]byte("payload")))
+			Expect(t.Verify(publicKey)).To(Succeed())
+
+			// Tamper with the payload
+			parts := strings.Split(token, ".")
+			parts[1] = base64.RawURLEncoding.EncodeToString([]byte("tampered"))
+			t, err = Parse(strings.Join(parts, "."))
+			Expect(err).To(Succeed())
+			Expect(t.Verify(publicKey)).To(MatchError(ErrInvalidSignature))
+		},
+		Entry("RS256", "RS256", rsaKey, &rsaKey.PublicKey),
+		Entry("PS384", "PS384", rsaKey, &rsaKey.PublicKey),
+		Entry("ES256", "ES256", p256Key, &p256Key.PublicKey),
+		Entry("ES512", "ES512", p521Key, &p521Key.PublicKey),
+		Entry("EdDSA", "EdDSA", ed25519Key, okp.NewEd25519(ed25519Key.PublicKey(), nil)),
+		Entry("HS256", "HS256", hmacKey, hmacKey),
+	)
+
+	It("Should reject mismatching keys and algorithms", func() {
+		_, err := Sign("ES384", p256Key, nil, []byte("payload"))
+		Expect(err).To(HaveOccurred())
+		_, err = Sign("RS256", p256Key, nil, []byte("payload"))
+		Expect(err).To(HaveOccurred())
+		_, err = Sign("none", p256Key, nil, []byte("payload"))
+		Expect(err).To(HaveOccurred())
+
+		token, err := Sign("ES256", p256Key, nil, []byte("payload"))
+		Expect(err).To(Succeed())
+		t, err := Parse(token)
+		Expect(err).To(Succeed())
+		Expect(t.Verify(&rsaKey.PublicKey)).ToNot(Succeed())
+		Expect(t.Verify(&p521Key.PublicKey)).ToNot(Succeed())
+	})
+
+	It("Should choose default algorithms", func() {
+		Expect(DefaultAlgorithm(rsaKey)).To(Equal("RS256"))
+		Expect(DefaultAlgorithm(p256Key)).To(Equal("ES256"))
+		Expect(DefaultAlgorithm(&p521Key.PublicKey)).To(Equal("ES512"))
+		Expect(DefaultAlgorithm(ed25519Key)).To(Equal("EdDSA"))
+		Expect(DefaultAlgorithm(hmacKey)).To(Equal("HS256"))
+		Expect(DefaultAlgorithm("foo")).To(BeEmpty())
+	})
+
+	DescribeTable("Should reject malformed tokens",
+		func(token string) {
+			_, err := Parse(token)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("too few parts", "a.b"),
+		Entry("bad base64", "!!!.e30.e30"),
+		Entry("bad header", base64.RawURLEncoding.EncodeToString([]byte("{"))+".e30.e30"),
+		Entry("missing alg", "e30.e30.e30"),
+		Entry("critical headers", base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256","crit":["foo"]}`))+".e30.e30"),
+	)
+})
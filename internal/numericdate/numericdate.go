@@ -0,0 +1,26 @@
+// Package numericdate converts between time.Time and the NumericDate values
+// (seconds since the epoch) used by JWKs and JWTs, where 0 means that the time
+// is not set.
+package numericdate
+
+import "time"
+
+// FromTime converts t to a NumericDate.
+// Negative values (especially for the zero time value) are not valid and are
+// converted to 0, which means the time is not set.
+func FromTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return max(t.Unix(), 0)
+}
+
+// ToTime converts a NumericDate to time.Time.
+// Only positive values are valid: the zero value for time.Time is returned
+// otherwise, which means the time is not set.
+func ToTime(sec int64) time.Time {
+	if sec > 0 {
+		return time.Unix(sec, 0)
+	}
+	return time.Time{}
+}
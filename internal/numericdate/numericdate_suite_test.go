@@ -0,0 +1,13 @@
+package numericdate_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestNumericdate(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Numericdate Suite")
+}
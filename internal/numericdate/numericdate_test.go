@@ -0,0 +1,23 @@
+package numericdate
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NumericDate", func() {
+	It("Should convert times", func() {
+		t := time.Unix(1700000000, 0)
+		Expect(FromTime(t)).To(BeEquivalentTo(1700000000))
+		Expect(ToTime(1700000000).Equal(t)).To(BeTrue())
+	})
+
+	It("Should treat zero and negative values as unset", func() {
+		Expect(FromTime(time.Time{})).To(BeZero())
+		Expect(FromTime(time.Unix(-1, 0))).To(BeZero())
+		Expect(ToTime(0).IsZero()).To(BeTrue())
+		Expect(ToTime(-1).IsZero()).To(BeTrue())
+	})
+})
@@ -0,0 +1,13 @@
+package testutils
+
+// Must returns v and panics if err is not nil
+func Must[T any](v T, err error) T {
+	PanicOnError(err)
+	return v
+}
+
+// Must2 returns v1 and v2 and panics if err is not nil
+func Must2[T1, T2 any](v1 T1, v2 T2, err error) (T1, T2) {
+	PanicOnError(err)
+	return v1, v2
+}
@@ -0,0 +1,251 @@
+package jwk
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/rakutentech/jwk-go/internal/numericdate"
+	"github.com/rakutentech/jwk-go/jwktypes"
+	"github.com/rakutentech/jwk-go/okp"
+)
+
+// AppendJSON appends the JSON serialization of the KeySpec to dst and returns
+// the extended buffer. The output is identical to MarshalJSON, but the key
+// material is written directly into dst without intermediate allocations, so
+// reusing a buffer with enough capacity does not allocate.
+//
+// Since dst contains private key material when marshaling private keys, the
+// caller is responsible for wiping it after use.
+func (k *KeySpec) AppendJSON(dst []byte) ([]byte, error) {
+	return k.appendJSON(dst, false)
+}
+
+// AppendPublicJSON appends the JSON serialization of the public fields of the
+// KeySpec to dst and returns the extended buffer. The output is identical to
+// MarshalPublicJSON.
+func (k *KeySpec) AppendPublicJSON(dst []byte) ([]byte, error) {
+	return k.appendJSON(dst, true)
+}
+
+// AppendJSON appends the KeySpecSet serialized as JWKS to dst and returns the
+// extended buffer. See KeySpec.AppendJSON.
+//
+// dst is grown at most once, so private key material is never left behind in
+// discarded buffers.
+//
+// Unlike json.Marshal, an empty set is always serialized as {"keys":[]}.
+func (ks KeySpecSet) AppendJSON(dst []byte) ([]byte, error) {
+	return ks.appendJSON(dst, false)
+}
+
+// AppendPublicJSON appends the public fields of the keys inside the KeySpecSet
+// serialized as JWKS to dst and returns the extended buffer. The output is
+// identical to MarshalPublicJSON.
+func (ks KeySpecSet) AppendPublicJSON(dst []byte) ([]byte, error) {
+	return ks.appendJSON(dst, true)
+}
+
+func (ks KeySpecSet) appendJSON(dst []byte, publicOnly bool) ([]byte, error) {
+	size, err := ks.maxJSONLen(publicOnly)
+	if err != nil {
+		return nil, err
+	}
+	dst = append(slices.Grow(dst, size), `{"keys":[`...)
+	for i := range ks.Keys {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst, err = ks.Keys[i].appendJSON(dst, publicOnly)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return append(dst, "]}"...), nil
+}
+
+// maxJSONLen returns an upper bound of the length of the KeySpecSet serialized
+// as JWKS. It fails if any of the keys cannot be serialized.
+func (ks KeySpecSet) maxJSONLen(publicOnly bool) (int, error) {
+	n := len(`{"keys":[]}`)
+	for i := range ks.Keys {
+		kty, crv, err := keyTypeAndCurve(ks.Keys[i].Key, publicOnly)
+		if err != nil {
+			return 0, err
+		}
+		n += len(",") + ks.Keys[i].maxJSONLen(kty, crv, publicOnly)
+	}
+	return n, nil
+}
+
+// maxJSONLen returns an upper bound of the length of the serialized KeySpec
+func (k *KeySpec) maxJSONLen(kty, crv string, publicOnly bool) int {
+	n := len("{}") + stringFieldLen(k.KeyID) + stringFieldLen(kty) + stringFieldLen(k.Use) +
+		stringFieldLen(k.Algorithm) + stringFieldLen(crv) + 3*intFieldLen
+	switch key := k.Key.(type) {
+	case []byte:
+		n += bytesFieldLen(len(key))
+	case OctetKey:
+		n += bytesFieldLen(len(key))
+	case *rsa.PublicKey:
+		n += bytesFieldLen(bigIntSize(key.N)) + bytesFieldLen(8)
+	case *rsa.PrivateKey:
+		n += bytesFieldLen(bigIntSize(key.N)) + bytesFieldLen(8)
+		if !publicOnly {
+			// dp, dq and qi are smaller than the primes
+			primeSize := max(bigIntSize(key.Primes[0]), bigIntSize(key.Primes[1]))
+			n += bytesFieldLen(bigIntSize(key.D)) + 5*bytesFieldLen(primeSize)
+		}
+	case *ecdsa.PublicKey:
+		n += 2 * bytesFieldLen(curveByteSize(key.Params()))
+	case *ecdsa.PrivateKey:
+		n += 3 * bytesFieldLen(curveByteSize(key.Params()))
+	case okp.CurveOctetKeyPair:
+		n += bytesFieldLen(len(key.PublicKey())) + bytesFieldLen(len(key.PrivateKey()))
+	}
+	return n
+}
+
+func (k *KeySpec) appendJSON(dst []byte, publicOnly bool) ([]byte, error) {
+	kty, crv, err := keyTypeAndCurve(k.Key, publicOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make room for the whole key at once, so private key material is never
+	// left behind in discarded buffers when the buffer grows.
+	dst = slices.Grow(dst, k.maxJSONLen(kty, crv, publicOnly))
+	m := orderedJsonMarshaller{buffer: append(dst, '{')}
+	m.marshalString("kid", k.KeyID)
+	m.marshalString("kty", kty)
+	m.marshalString("use", k.Use)
+	m.marshalString("alg", k.Algorithm)
+	m.marshalString("crv", crv)
+	m.marshalInt("exp", numericdate.FromTime(k.ExpiresAt))
+	m.marshalInt("nbf", numericdate.FromTime(k.NotBefore))
+	m.marshalInt("iat", numericdate.FromTime(k.IssuedAt))
+
+	switch key := k.Key.(type) {
+	case []byte:
+		m.marshalRawBytes("k", key)
+	case OctetKey:
+		m.marshalRawBytes("k", key)
+	case *rsa.PublicKey:
+		m.appendRSAPublic(key)
+	case *rsa.PrivateKey:
+		m.appendRSAPublic(&key.PublicKey)
+		if !publicOnly {
+			m.appendRSAPrivate(key)
+		}
+	case *ecdsa.PublicKey:
+		m.appendECPublic(key)
+	case *ecdsa.PrivateKey:
+		m.appendECPublic(&key.PublicKey)
+		if !publicOnly {
+			m.marshalBigInt("d", key.D, curveByteSize(key.Params()))
+		}
+	case okp.CurveOctetKeyPair:
+		m.marshalRawBytes("x", key.PublicKey())
+		if !publicOnly {
+			m.marshalRawBytes("d", key.PrivateKey())
+		}
+	}
+	return m.finalize(), nil
+}
+
+// keyTypeAndCurve validates key for marshaling and returns its 'kty' and
+// 'crv' members. The checks match the ones done by ToJWK, so that appending
+// never fails halfway through a key.
+func keyTypeAndCurve(key interface{}, publicOnly bool) (kty string, crv string, err error) {
+	switch key := key.(type) {
+	case []byte, OctetKey:
+		if publicOnly {
+			return "", "", errors.New("key type does not support extracting the public key")
+		}
+		return jwktypes.OctetKey, "", nil
+	case *rsa.PublicKey:
+		return jwktypes.RSA, "", checkRSAPublic(key)
+	case *rsa.PrivateKey:
+		if err := checkRSAPublic(&key.PublicKey); err != nil {
+			return "", "", err
+		}
+		if publicOnly {
+			return jwktypes.RSA, "", nil
+		}
+		if key.D == nil {
+			return "", "", errors.New("invalid RSA private key: missing field d")
+		}
+		if len(key.Primes) != 2 {
+			return "", "", errors.New("invalid RSA private key: must have exactly 2 primes")
+		}
+		return jwktypes.RSA, "", nil
+	case *ecdsa.PublicKey:
+		return checkECPublic(key)
+	case *ecdsa.PrivateKey:
+		kty, crv, err := checkECPublic(&key.PublicKey)
+		if err != nil || publicOnly {
+			return kty, crv, err
+		}
+		if key.D == nil {
+			return "", "", errors.New("invalid EC private key")
+		}
+		if bigIntSize(key.D) > curveByteSize(key.Params()) {
+			return "", "", fmt.Errorf("invalid field d byte size for curve %s", crv)
+		}
+		return kty, crv, nil
+	case okp.CurveOctetKeyPair:
+		return jwktypes.OKP, key.Curve(), nil
+	default:
+		return "", "", errors.New("unsupported key type (cannot convert to JWK)")
+	}
+}
+
+func checkRSAPublic(public *rsa.PublicKey) error {
+	if public.N == nil || public.N.Sign() == 0 {
+		return errors.New("invalid RSA public key")
+	}
+	return nil
+}
+
+func checkECPublic(public *ecdsa.PublicKey) (kty string, crv string, err error) {
+	params := public.Params()
+	if public.X == nil || public.Y == nil || params == nil {
+		return "", "", errors.New("invalid EC public key")
+	}
+	byteSize := curveByteSize(params)
+	if bigIntSize(public.X) > byteSize || bigIntSize(public.Y) > byteSize {
+		return "", "", fmt.Errorf("invalid field x/y byte size for curve %s", params.Name)
+	}
+	return jwktypes.EC, params.Name, nil
+}
+
+func (m *orderedJsonMarshaller) appendRSAPublic(public *rsa.PublicKey) {
+	m.marshalBigInt("n", public.N, 0)
+	var e [8]byte
+	binary.BigEndian.PutUint64(e[:], uint64(public.E))
+	m.marshalRawBytes("e", bytes.TrimLeft(e[:], "\x00"))
+}
+
+func (m *orderedJsonMarshaller) appendRSAPrivate(private *rsa.PrivateKey) {
+	// JWK RSA representations should have the precomputed values 'dp', 'dq' and 'qi'
+	if private.Precomputed.Dp == nil {
+		private.Precompute()
+	}
+	m.marshalBigInt("d", private.D, 0)
+	m.marshalBigInt("p", private.Primes[0], 0)
+	m.marshalBigInt("q", private.Primes[1], 0)
+	m.marshalBigInt("dp", private.Precomputed.Dp, 0)
+	m.marshalBigInt("dq", private.Precomputed.Dq, 0)
+	m.marshalBigInt("qi", private.Precomputed.Qinv, 0)
+}
+
+func (m *orderedJsonMarshaller) appendECPublic(public *ecdsa.PublicKey) {
+	// Coordinates are padded to the curve size, see RFC 7518 # 6.2.1.2
+	byteSize := curveByteSize(public.Params())
+	m.marshalBigInt("x", public.X, byteSize)
+	m.marshalBigInt("y", public.Y, byteSize)
+}
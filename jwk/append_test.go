@@ -0,0 +1,138 @@
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rakutentech/jwk-go/internal/testutils"
+	"github.com/rakutentech/jwk-go/okp"
+)
+
+var _ = Describe("AppendJSON", func() {
+	now := time.Unix(1700000000, 0)
+	keys := []KeySpec{
+		{
+			Key:       testutils.Must(rsa.GenerateKey(rand.Reader, 2048)),
+			KeyID:     "rsa",
+			Algorithm: "RS256",
+			Use:       "sig",
+			IssuedAt:  now,
+			ExpiresAt: now.Add(time.Hour),
+		},
+		{Key: testutils.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader)), KeyID: "p256"},
+		{Key: testutils.Must(ecdsa.GenerateKey(elliptic.P521(), rand.Reader)), NotBefore: now},
+		{Key: testutils.Must(okp.GenerateEd25519(rand.Reader)), KeyID: "ed25519"},
+		{Key: testutils.Must(okp.GenerateCurve25519(rand.Reader)), KeyID: "x25519", Use: "enc"},
+		{Key: testutils.Must(okp.GenerateEd25519(rand.Reader)), KeyID: "<a&b> \x01\xff\"\\\n\t/é"},
+	}
+	symmetricKeys := []KeySpec{
+		{Key: randomBytes(32), KeyID: "oct"},
+		{Key: OctetKey(randomBytes(16)), Algorithm: "A128KW"},
+	}
+
+	It("Should give the same output as MarshalJSON", func() {
+		for _, k := range append(keys, symmetricKeys...) {
+			Expect(string(testutils.Must(k.AppendJSON(nil)))).To(Equal(string(testutils.Must(k.MarshalJSON()))))
+		}
+		for _, k := range keys {
+			Expect(string(testutils.Must(k.AppendPublicJSON(nil)))).
+				To(Equal(string(testutils.Must(k.MarshalPublicJSON()))))
+		}
+
+		ks := KeySpecSet{Keys: append(keys, symmetricKeys...)}
+		Expect(string(testutils.Must(ks.AppendJSON(nil)))).To(Equal(string(testutils.Must(json.Marshal(ks)))))
+		ks = KeySpecSet{Keys: keys}
+		Expect(string(testutils.Must(ks.AppendPublicJSON(nil)))).
+			To(Equal(string(testutils.Must(ks.MarshalPublicJSON()))))
+	})
+
+	It("Should append to the buffer", func() {
+		dst := []byte("prefix:")
+		ks := KeySpecSet{Keys: keys[1:2]}
+		out := testutils.Must(ks.AppendPublicJSON(dst))
+		Expect(string(out)).To(Equal("prefix:" + string(testutils.Must(ks.MarshalPublicJSON()))))
+
+		Expect(string(testutils.Must(KeySpecSet{}.AppendJSON(nil)))).To(Equal(`{"keys":[]}`))
+	})
+
+	It("Should fail on invalid keys", func() {
+		_, err := symmetricKeys[0].AppendPublicJSON(nil)
+		Expect(err).To(MatchError("key type does not support extracting the public key"))
+		_, err = KeySpecSet{Keys: symmetricKeys}.AppendPublicJSON(nil)
+		Expect(err).To(HaveOccurred())
+		_, err = NewSpec("not a key").AppendJSON(nil)
+		Expect(err).To(MatchError("unsupported key type (cannot convert to JWK)"))
+		_, err = NewSpec(&rsa.PrivateKey{PublicKey: rsa.PublicKey{}}).AppendJSON(nil)
+		Expect(err).To(MatchError("invalid RSA public key"))
+	})
+
+	It("Should grow the buffer once", func() {
+		ks := KeySpecSet{Keys: append(keys, symmetricKeys...)}
+		Expect(len(testutils.Must(ks.AppendJSON(nil)))).To(BeNumerically("<=", testutils.Must(ks.maxJSONLen(false))))
+		ks = KeySpecSet{Keys: keys}
+		Expect(len(testutils.Must(ks.AppendPublicJSON(nil)))).To(BeNumerically("<=", testutils.Must(ks.maxJSONLen(true))))
+
+		k := KeySpec{Key: randomBytes(32), KeyID: strings.Repeat("\x01", 100), Use: "<>&", Algorithm: "\u2028"}
+		Expect(len(testutils.Must(k.AppendJSON(nil)))).To(BeNumerically("<=", k.maxJSONLen("oct", "", false)))
+	})
+
+	It("Should not allocate when the buffer is large enough", func() {
+		ks := KeySpecSet{Keys: append(keys, symmetricKeys...)}
+		buf := testutils.Must(ks.AppendJSON(nil))
+		allocs := testing.AllocsPerRun(10, func() {
+			buf = testutils.Must(ks.AppendJSON(buf[:0]))
+		})
+		Expect(allocs).To(BeZero())
+	})
+})
+
+func BenchmarkKeySetAppendPublicJSON(b *testing.B) {
+	for _, size := range []int{1, 100, 10000} {
+		ks := benchmarkKeySet(size)
+		b.Run(fmt.Sprintf("keys=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			var buf []byte
+			for i := 0; i < b.N; i++ {
+				buf = testutils.Must(ks.AppendPublicJSON(buf[:0]))
+			}
+			b.SetBytes(int64(len(buf)))
+		})
+	}
+}
+
+func BenchmarkKeySetMarshalPublicJSON(b *testing.B) {
+	for _, size := range []int{1, 100, 10000} {
+		ks := benchmarkKeySet(size)
+		b.Run(fmt.Sprintf("keys=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				testutils.Must(ks.MarshalPublicJSON())
+			}
+		})
+	}
+}
+
+// benchmarkKeySet returns a set of size EC and Ed25519 keys
+func benchmarkKeySet(size int) KeySpecSet {
+	ks := KeySpecSet{Keys: make([]KeySpec, size)}
+	for i := range ks.Keys {
+		k := KeySpec{KeyID: fmt.Sprint("key-", i), Use: "sig"}
+		if i%2 == 0 {
+			k.Key, k.Algorithm = testutils.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader)), "ES256"
+		} else {
+			k.Key, k.Algorithm = testutils.Must(okp.GenerateEd25519(rand.Reader)), "EdDSA"
+		}
+		ks.Keys[i] = k
+	}
+	return ks
+}
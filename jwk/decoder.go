@@ -0,0 +1,160 @@
+package jwk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+)
+
+// KeyError describes an invalid key inside a JWKS.
+type KeyError struct {
+	// Index is the position of the key in the 'keys' array
+	Index int
+	// KeyID is the 'kid' of the key, if it could be read
+	KeyID string
+	// Err is the reason why the key is invalid
+	Err error
+}
+
+func (e *KeyError) Error() string {
+	if e.KeyID != "" {
+		return fmt.Sprintf("key %d (kid %q): %v", e.Index, e.KeyID, e.Err)
+	}
+	return fmt.Sprintf("key %d: %v", e.Index, e.Err)
+}
+
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+// Decoder reads the keys of a JWKS from a stream one by one, without loading
+// the whole set in memory.
+type Decoder struct {
+	// SkipInvalid makes Keys skip invalid keys instead of yielding their
+	// errors. The errors of skipped keys are returned by Skipped.
+	SkipInvalid bool
+
+	dec     *json.Decoder
+	skipped []*KeyError
+}
+
+// NewDecoder creates a Decoder reading a JWKS from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{dec: json.NewDecoder(r)}
+}
+
+// Keys returns a sequence of the keys in the JWKS, in order. The sequence can
+// only be iterated once.
+//
+// An invalid key yields a *KeyError, after which iteration can continue with
+// the next key (unless SkipInvalid is set, in which case the key is skipped
+// silently). Other errors, such as malformed JSON, are yielded last.
+func (d *Decoder) Keys() iter.Seq2[KeySpec, error] {
+	return func(yield func(KeySpec, error) bool) {
+		if err := d.decode(yield); err != nil {
+			yield(KeySpec{}, err)
+		}
+	}
+}
+
+// Decode reads all the keys in the JWKS. When SkipInvalid is not set, it
+// stops on the first invalid key.
+func (d *Decoder) Decode() (KeySpecSet, error) {
+	var ks KeySpecSet
+	for k, err := range d.Keys() {
+		if err != nil {
+			return KeySpecSet{}, err
+		}
+		ks.Keys = append(ks.Keys, k)
+	}
+	return ks, nil
+}
+
+// Skipped returns the errors of the keys skipped with SkipInvalid.
+func (d *Decoder) Skipped() []*KeyError {
+	return d.skipped
+}
+
+// decode reads the JWKS object and yields its keys. It returns an error if the
+// JWKS is malformed.
+func (d *Decoder) decode(yield func(KeySpec, error) bool) error {
+	if err := d.expectDelim('{'); err != nil {
+		return err
+	}
+	found := false
+	for d.dec.More() {
+		token, err := d.dec.Token()
+		if err != nil {
+			return err
+		}
+		if name, _ := token.(string); name != "keys" || found {
+			// Skip other members
+			if err := d.dec.Decode(&json.RawMessage{}); err != nil {
+				return err
+			}
+			continue
+		}
+		found = true
+		if err := d.expectDelim('['); err != nil {
+			return fmt.Errorf("keys: %w", err)
+		}
+		for i := 0; d.dec.More(); i++ {
+			k, err := d.decodeKey(i)
+			if err != nil {
+				var keyErr *KeyError
+				if !errors.As(err, &keyErr) {
+					return err
+				}
+				if d.SkipInvalid {
+					d.skipped = append(d.skipped, keyErr)
+					continue
+				}
+			}
+			if !yield(k, err) {
+				return nil
+			}
+		}
+		if err := d.expectDelim(']'); err != nil {
+			return err
+		}
+	}
+	if err := d.expectDelim('}'); err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("missing 'keys' member in JWKS")
+	}
+	return nil
+}
+
+// decodeKey decodes the next key of the 'keys' array. Keys which are valid
+// JSON but not valid JWKs return a *KeyError.
+func (d *Decoder) decodeKey(index int) (KeySpec, error) {
+	var raw json.RawMessage
+	if err := d.dec.Decode(&raw); err != nil {
+		return KeySpec{}, err
+	}
+	defer clear(raw)
+	var k KeySpec
+	if err := k.UnmarshalJSON(raw); err != nil {
+		var header struct {
+			Kid string `json:"kid"`
+		}
+		_ = json.Unmarshal(raw, &header)
+		return KeySpec{}, &KeyError{Index: index, KeyID: header.Kid, Err: err}
+	}
+	return k, nil
+}
+
+func (d *Decoder) expectDelim(delim json.Delim) error {
+	token, err := d.dec.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("malformed JWKS: expected %q but got %v", delim, token)
+	}
+	return nil
+}
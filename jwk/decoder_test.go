@@ -0,0 +1,123 @@
+package jwk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rakutentech/jwk-go/internal/testutils"
+)
+
+var _ = Describe("Decoder", func() {
+	const invalidKeys = `{
+		"other": {"keys": "ignored"},
+		"keys": [
+			{"kty": "oct", "kid": "a", "k": "AQID"},
+			{"kty": "unknown", "kid": "b"},
+			42,
+			{"kty": "oct", "kid": "c", "k": "BAUG"}
+		],
+		"after": [1, 2]
+	}`
+
+	It("Should decode the same keys as json.Unmarshal", func() {
+		var expected KeySpecSet
+		Expect(json.Unmarshal([]byte(keys), &expected)).To(Succeed())
+
+		ks, err := NewDecoder(strings.NewReader(keys)).Decode()
+		Expect(err).To(Succeed())
+		Expect(ks.Diff(expected).IsEmpty()).To(BeTrue())
+	})
+
+	It("Should yield errors for invalid keys and continue", func() {
+		var kids []string
+		var keyErrs []*KeyError
+		for k, err := range NewDecoder(strings.NewReader(invalidKeys)).Keys() {
+			var keyErr *KeyError
+			if errors.As(err, &keyErr) {
+				keyErrs = append(keyErrs, keyErr)
+				continue
+			}
+			Expect(err).To(Succeed())
+			kids = append(kids, k.KeyID)
+		}
+		Expect(kids).To(Equal([]string{"a", "c"}))
+		Expect(keyErrs).To(HaveLen(2))
+		Expect(keyErrs[0].Index).To(Equal(1))
+		Expect(keyErrs[0].KeyID).To(Equal("b"))
+		Expect(keyErrs[0].Error()).To(ContainSubstring(`key 1 (kid "b"): unknown key type`))
+		Expect(keyErrs[1].Index).To(Equal(2))
+	})
+
+	It("Should skip invalid keys", func() {
+		d := NewDecoder(strings.NewReader(invalidKeys))
+		_, err := d.Decode()
+		Expect(err).To(HaveOccurred())
+
+		d = NewDecoder(strings.NewReader(invalidKeys))
+		d.SkipInvalid = true
+		ks, err := d.Decode()
+		Expect(err).To(Succeed())
+		Expect(keyIDs(ks)).To(Equal([]string{"a", "c"}))
+		Expect(d.Skipped()).To(HaveLen(2))
+	})
+
+	It("Should stop when the consumer stops", func() {
+		count := 0
+		for range NewDecoder(strings.NewReader(invalidKeys)).Keys() {
+			count++
+			break
+		}
+		Expect(count).To(Equal(1))
+	})
+
+	It("Should fail on malformed JWKS", func() {
+		for _, data := range []string{
+			``, `[]`, `{}`, `{"keys": {}}`, `{"keys": [{"kty": "oct", "k": "AQID"}`, `{"keys": [{]}`,
+		} {
+			d := NewDecoder(strings.NewReader(data))
+			d.SkipInvalid = true
+			_, err := d.Decode()
+			Expect(err).To(HaveOccurred(), data)
+			var keyErr *KeyError
+			Expect(errors.As(err, &keyErr)).To(BeFalse(), data)
+		}
+	})
+
+	It("Should stream large key sets", func() {
+		const count = 5000
+		r, w := io.Pipe()
+		go func() {
+			var buf bytes.Buffer
+			buf.WriteString(`{"keys":[`)
+			for i := 0; i < count; i++ {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				k := &KeySpec{Key: randomBytes(32), KeyID: fmt.Sprint(i)}
+				buf.Write(testutils.Must(k.MarshalJSON()))
+				if buf.Len() > 4096 {
+					_, _ = w.Write(buf.Bytes())
+					buf.Reset()
+				}
+			}
+			buf.WriteString(`]}`)
+			_, _ = w.Write(buf.Bytes())
+			_ = w.Close()
+		}()
+
+		i := 0
+		for k, err := range NewDecoder(r).Keys() {
+			Expect(err).To(Succeed())
+			Expect(k.KeyID).To(Equal(fmt.Sprint(i)))
+			i++
+		}
+		Expect(i).To(Equal(count))
+	})
+})
@@ -0,0 +1,158 @@
+package jwk
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// PassphraseKDF is a key derivation function for passphrases
+type PassphraseKDF string
+
+const (
+	// Argon2id is the Argon2id memory-hard function (RFC 9106)
+	Argon2id PassphraseKDF = "argon2id"
+	// PBKDF2 is PBKDF2 with HMAC-SHA-256 (RFC 8018)
+	PBKDF2 PassphraseKDF = "pbkdf2"
+)
+
+// Default passphrase derivation parameters: the second recommended option of
+// RFC 9106 for Argon2id, and the OWASP recommendation for PBKDF2-HMAC-SHA256.
+const (
+	DefaultArgon2idTime     = 3
+	DefaultArgon2idMemory   = 64 * 1024
+	DefaultArgon2idThreads  = 4
+	DefaultPBKDF2Iterations = 600000
+)
+
+// PassphraseSettings contains settings for DeriveOctetFromPassphrase()
+type PassphraseSettings struct {
+	// KDF is the key derivation function. Defaults to Argon2id.
+	KDF PassphraseKDF
+
+	// Iterations is the number of PBKDF2 iterations, or the number of passes
+	// over the memory for Argon2id. Defaults to DefaultPBKDF2Iterations or
+	// DefaultArgon2idTime.
+	Iterations uint32
+
+	// Memory is the Argon2id memory size in KiB. Defaults to
+	// DefaultArgon2idMemory.
+	Memory uint32
+
+	// Threads is the Argon2id degree of parallelism. Defaults to
+	// DefaultArgon2idThreads.
+	Threads uint8
+}
+
+// DeriveOctet derives a symmetric key for alg from the symmetric master key
+// with HKDF-SHA256 (RFC 5869). Different info strings (e.g. tenant IDs) give
+// independent keys; salt is optional.
+//
+// The derived KeySpec is normalized: 'alg' and 'use' are set, and the Key ID
+// is derived from the key, so deriving the same key twice gives the same
+// Key ID. It is a separate HKDF output rather than the thumbprint, which is a
+// plain hash of the secret.
+func DeriveOctet(master *KeySpec, info, salt []byte, alg string) (*KeySpec, error) {
+	secret, ok := master.OctetKey()
+	if !ok {
+		return nil, errors.New("master key must be a symmetric key")
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("master key is empty")
+	}
+	return deriveOctetKeySpec(alg, func(key OctetKey) error {
+		_, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), key)
+		return err
+	})
+}
+
+// DeriveOctetFromPassphrase derives a symmetric key for alg from a passphrase
+// with Argon2id or PBKDF2, as specified by settings. The salt should be
+// random and at least 16 bytes long; it must be stored to derive the same key
+// again.
+//
+// The derived KeySpec is normalized like the ones returned by DeriveOctet().
+func DeriveOctetFromPassphrase(passphrase, salt []byte, alg string, settings PassphraseSettings) (*KeySpec, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("passphrase is empty")
+	}
+	if len(salt) == 0 {
+		return nil, errors.New("a salt is required for passphrase key derivation")
+	}
+	return deriveOctetKeySpec(alg, func(key OctetKey) error {
+		var derived []byte
+		switch settings.KDF {
+		case Argon2id, "":
+			time, memory, threads := settings.Iterations, settings.Memory, settings.Threads
+			if time == 0 {
+				time = DefaultArgon2idTime
+			}
+			if memory == 0 {
+				memory = DefaultArgon2idMemory
+			}
+			if threads == 0 {
+				threads = DefaultArgon2idThreads
+			}
+			derived = argon2.IDKey(passphrase, salt, time, memory, threads, uint32(len(key)))
+		case PBKDF2:
+			iterations := settings.Iterations
+			if iterations == 0 {
+				iterations = DefaultPBKDF2Iterations
+			}
+			derived = pbkdf2.Key(passphrase, salt, int(iterations), len(key), sha256.New)
+		default:
+			return fmt.Errorf("unsupported passphrase key derivation function: %s", settings.KDF)
+		}
+		copy(key, derived)
+		clear(derived)
+		return nil
+	})
+}
+
+// deriveOctetKeySpec creates a normalized KeySpec for alg with a key filled
+// by derive
+func deriveOctetKeySpec(alg string, derive func(key OctetKey) error) (*KeySpec, error) {
+	size, err := OctetKeySize(alg)
+	if err != nil {
+		return nil, err
+	}
+	key := make(OctetKey, size)
+	if err := derive(key); err != nil {
+		key.Destroy()
+		return nil, err
+	}
+	use := "enc"
+	if isHMACAlgorithm(alg) {
+		use = "sig"
+	}
+	keyID, err := derivedKeyID(key)
+	if err != nil {
+		key.Destroy()
+		return nil, err
+	}
+	k := &KeySpec{Key: key, Algorithm: alg, KeyID: keyID}
+	if err := k.Normalize(NormalizationSettings{Use: use}); err != nil {
+		key.Destroy()
+		return nil, err
+	}
+	return k, nil
+}
+
+// derivedKeyIDSize is the size of the HKDF output used as Key ID
+const derivedKeyIDSize = 16
+
+// derivedKeyID derives the Key ID of a derived key with HKDF-SHA256 and the
+// info "kid"
+func derivedKeyID(key OctetKey) (string, error) {
+	keyID := make([]byte, derivedKeyIDSize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, nil, []byte("kid")), keyID); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(keyID), nil
+}
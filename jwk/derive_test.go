@@ -0,0 +1,108 @@
+package jwk
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/argon2"
+
+	"github.com/rakutentech/jwk-go/internal/testutils"
+)
+
+func mustDecodeHex(s string) []byte {
+	return testutils.Must(hex.DecodeString(s))
+}
+
+var _ = Describe("DeriveOctet", func() {
+	// RFC 5869 test case 1
+	master := NewSpec(bytes.Repeat([]byte{0x0b}, 22))
+	salt := mustDecodeHex("000102030405060708090a0b0c")
+	info := mustDecodeHex("f0f1f2f3f4f5f6f7f8f9")
+
+	It("Should derive keys with HKDF-SHA256", func() {
+		k := testutils.Must(DeriveOctet(master, info, salt, "HS256"))
+		Expect(k.Key).To(Equal(OctetKey(mustDecodeHex(
+			"3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf"))))
+		Expect(k.Algorithm).To(Equal("HS256"))
+		Expect(k.Use).To(Equal("sig"))
+		Expect(k.KeyID).To(HaveLen(22))
+		thumbprint := testutils.Must(k.Thumbprint())
+		Expect(k.KeyID).NotTo(Equal(base64.RawURLEncoding.EncodeToString(thumbprint)))
+
+		again := testutils.Must(DeriveOctet(NewSpec(OctetKey(bytes.Repeat([]byte{0x0b}, 22))), info, salt, "HS256"))
+		Expect(again.Equal(k)).To(BeTrue())
+		Expect(again.KeyID).To(Equal(k.KeyID))
+	})
+
+	It("Should derive independent keys with the right size", func() {
+		tenantA := testutils.Must(DeriveOctet(master, []byte("tenant-a"), nil, "A128KW"))
+		tenantB := testutils.Must(DeriveOctet(master, []byte("tenant-b"), nil, "A128KW"))
+		Expect(tenantA.Key).To(HaveLen(16))
+		Expect(tenantA.Use).To(Equal("enc"))
+		Expect(tenantA.KeyID).NotTo(Equal(tenantB.KeyID))
+
+		Expect(testutils.Must(DeriveOctet(master, info, salt, "HS512")).Key).To(HaveLen(64))
+	})
+
+	It("Should reject invalid master keys and algorithms", func() {
+		_, err := DeriveOctet(NewSpec(OctetKey{}), info, salt, "HS256")
+		Expect(err).To(MatchError("master key is empty"))
+		_, err = DeriveOctet(NewSpec(testutils.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader))), info, salt, "HS256")
+		Expect(err).To(MatchError("master key must be a symmetric key"))
+		_, err = DeriveOctet(master, info, salt, "ES256")
+		Expect(err).To(MatchError("unsupported symmetric algorithm: ES256"))
+	})
+})
+
+var _ = Describe("DeriveOctetFromPassphrase", func() {
+	salt := []byte("0123456789abcdef")
+	fastArgon2id := PassphraseSettings{Iterations: 1, Memory: 64, Threads: 1}
+
+	It("Should derive keys with PBKDF2-HMAC-SHA256", func() {
+		// RFC 7914 section 11
+		k := testutils.Must(DeriveOctetFromPassphrase([]byte("passwd"), []byte("salt"), "HS512",
+			PassphraseSettings{KDF: PBKDF2, Iterations: 1}))
+		Expect(k.Key).To(Equal(OctetKey(mustDecodeHex(
+			"55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+				"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"))))
+		Expect(k.Algorithm).To(Equal("HS512"))
+		Expect(k.KeyID).NotTo(BeEmpty())
+	})
+
+	It("Should derive keys with Argon2id", func() {
+		k := testutils.Must(DeriveOctetFromPassphrase([]byte("passphrase"), salt, "A256GCM", fastArgon2id))
+		Expect(k.Key).To(Equal(OctetKey(argon2.IDKey([]byte("passphrase"), salt, 1, 64, 1, 32))))
+		Expect(k.Use).To(Equal("enc"))
+
+		again := testutils.Must(DeriveOctetFromPassphrase([]byte("passphrase"), salt, "A256GCM", fastArgon2id))
+		Expect(again.KeyID).To(Equal(k.KeyID))
+
+		other := testutils.Must(DeriveOctetFromPassphrase([]byte("passphrase"), salt, "A256GCM",
+			PassphraseSettings{Iterations: 2, Memory: 64, Threads: 1}))
+		Expect(other.Equal(k)).To(BeFalse())
+	})
+
+	It("Should use Argon2id by default", func() {
+		k := testutils.Must(DeriveOctetFromPassphrase([]byte("passphrase"), salt, "HS256", PassphraseSettings{}))
+		Expect(k.Key).To(Equal(OctetKey(argon2.IDKey([]byte("passphrase"), salt,
+			DefaultArgon2idTime, DefaultArgon2idMemory, DefaultArgon2idThreads, 32))))
+		Expect(k.Key).NotTo(Equal(OctetKey(argon2.IDKey([]byte("passphrase"), salt, 1, 64, 1, 32))))
+	})
+
+	It("Should reject invalid input", func() {
+		_, err := DeriveOctetFromPassphrase(nil, salt, "HS256", fastArgon2id)
+		Expect(err).To(MatchError("passphrase is empty"))
+		_, err = DeriveOctetFromPassphrase([]byte("passphrase"), nil, "HS256", fastArgon2id)
+		Expect(err).To(MatchError("a salt is required for passphrase key derivation"))
+		_, err = DeriveOctetFromPassphrase([]byte("passphrase"), salt, "HS256", PassphraseSettings{KDF: "scrypt"})
+		Expect(err).To(MatchError("unsupported passphrase key derivation function: scrypt"))
+		_, err = DeriveOctetFromPassphrase([]byte("passphrase"), salt, "RS256", fastArgon2id)
+		Expect(err).To(MatchError("unsupported symmetric algorithm: RS256"))
+	})
+})
@@ -0,0 +1,84 @@
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"math/big"
+)
+
+// destroyer is implemented by key types which can zeroize their private
+// components, such as okp.OctetKeyPairBase and OctetKey.
+type destroyer interface {
+	Destroy()
+}
+
+// Destroy overwrites the private components of the key in the KeySpec with
+// zeros and removes the key from the KeySpec. Public keys are only removed.
+//
+// Zeroization is best-effort: copies made by the Go runtime (e.g. when a
+// slice grows) or by the standard library (e.g. precomputed values cached
+// inside crypto/rsa) cannot be reached. The key must not be used afterwards,
+// including through other KeySpecs which share it.
+func (k *KeySpec) Destroy() {
+	destroyKey(k.Key)
+	k.Key = nil
+}
+
+// Destroy calls KeySpec.Destroy on all the KeySpecs in the KeySpecSet.
+func (ks KeySpecSet) Destroy() {
+	for i := range ks.Keys {
+		ks.Keys[i].Destroy()
+	}
+}
+
+func destroyKey(keyInterface interface{}) {
+	switch key := keyInterface.(type) {
+	case []byte:
+		clear(key)
+	case *rsa.PrivateKey:
+		destroyBigInt(key.D)
+		for _, prime := range key.Primes {
+			destroyBigInt(prime)
+		}
+		destroyBigInt(key.Precomputed.Dp)
+		destroyBigInt(key.Precomputed.Dq)
+		destroyBigInt(key.Precomputed.Qinv)
+		for _, crt := range key.Precomputed.CRTValues {
+			destroyBigInt(crt.Exp)
+			destroyBigInt(crt.Coeff)
+			destroyBigInt(crt.R)
+		}
+	case *ecdsa.PrivateKey:
+		destroyBigInt(key.D)
+	case destroyer:
+		key.Destroy()
+	}
+}
+
+// destroyBigInt overwrites the memory of a big.Int with zeros.
+func destroyBigInt(b *big.Int) {
+	if b == nil {
+		return
+	}
+	clear(b.Bits())
+	b.SetInt64(0)
+}
+
+// destroyBytes overwrites the data of a keyBytes with zeros.
+func destroyBytes(kbs ...*keyBytes) {
+	for _, kb := range kbs {
+		if kb != nil {
+			clear(kb.data)
+		}
+	}
+}
+
+// destroyPrivateCopies zeroizes the private fields of a JWK which were copied
+// when converting from or to key. Keys which share their memory with the JWK
+// (symmetric keys and OKPs) are left intact.
+func (jwk *JWK) destroyPrivateCopies(key interface{}) {
+	switch key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey:
+		destroyBytes(jwk.D, jwk.P, jwk.Q, jwk.Dp, jwk.Dq, jwk.Qi)
+	}
+}
@@ -0,0 +1,112 @@
+package jwk
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rakutentech/jwk-go/internal/testutils"
+	"github.com/rakutentech/jwk-go/okp"
+)
+
+var _ = Describe("Destroy", func() {
+	It("Should zeroize RSA private keys", func() {
+		key := testutils.Must(rsa.GenerateKey(rand.Reader, 2048))
+		k := NewSpec(key)
+		k.Destroy()
+		Expect(k.Key).To(BeNil())
+		Expect(key.D.Sign()).To(BeZero())
+		for _, prime := range key.Primes {
+			Expect(prime.Sign()).To(BeZero())
+		}
+		Expect(key.Precomputed.Dp.Sign()).To(BeZero())
+		Expect(key.Precomputed.Dq.Sign()).To(BeZero())
+		Expect(key.Precomputed.Qinv.Sign()).To(BeZero())
+	})
+
+	It("Should zeroize EC private keys", func() {
+		key := testutils.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader))
+		words := key.D.Bits()
+		k := NewSpec(key)
+		k.Destroy()
+		Expect(key.D.Sign()).To(BeZero())
+		for _, w := range words {
+			Expect(w).To(BeZero())
+		}
+	})
+
+	It("Should zeroize OKP private keys", func() {
+		for _, key := range []okp.CurveOctetKeyPair{
+			testutils.Must(okp.GenerateEd25519(rand.Reader)),
+			testutils.Must(okp.GenerateCurve25519(rand.Reader)),
+		} {
+			public := bytes.Clone(key.PublicKey())
+			k := NewSpec(key)
+			k.Destroy()
+			Expect(key.PrivateKey()).To(Equal(make([]byte, 32)))
+			Expect(key.PublicKey()).To(Equal(public))
+		}
+	})
+
+	It("Should zeroize symmetric keys", func() {
+		key := randomBytes(32)
+		octetKey := OctetKey(randomBytes(32))
+		ks := KeySpecSet{Keys: []KeySpec{{Key: key}, {Key: octetKey}}}
+		ks.Destroy()
+		Expect(key).To(Equal(make([]byte, 32)))
+		Expect(octetKey).To(Equal(OctetKey(make([]byte, 32))))
+		Expect(ks.Keys[0].Key).To(BeNil())
+		Expect(ks.Keys[1].Key).To(BeNil())
+	})
+
+	It("Should leave public keys intact", func() {
+		key := testutils.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader))
+		x := new(bytes.Buffer)
+		x.Write(key.X.Bytes())
+		k := NewSpec(&key.PublicKey)
+		k.Destroy()
+		Expect(k.Key).To(BeNil())
+		Expect(key.X.Bytes()).To(Equal(x.Bytes()))
+	})
+
+	It("Should not destroy keys when marshaling or parsing", func() {
+		for _, key := range []interface{}{
+			testutils.Must(rsa.GenerateKey(rand.Reader, 2048)),
+			testutils.Must(ecdsa.GenerateKey(elliptic.P521(), rand.Reader)),
+			testutils.Must(okp.GenerateEd25519(rand.Reader)),
+			randomBytes(32),
+		} {
+			k := NewSpecWithID("foo", key)
+			b1, err := k.MarshalJSON()
+			Expect(err).To(Succeed())
+			b2, err := k.MarshalJSON()
+			Expect(err).To(Succeed())
+			Expect(b1).To(Equal(b2))
+
+			var parsed KeySpec
+			Expect(json.Unmarshal(b1, &parsed)).To(Succeed())
+			b3, err := parsed.MarshalJSON()
+			Expect(err).To(Succeed())
+			Expect(b3).To(Equal(b1))
+		}
+	})
+
+	It("Should decode escaped base64 values", func() {
+		var kb keyBytes
+		Expect(kb.UnmarshalJSON([]byte(`"AQAB\/"`))).ToNot(Succeed()) // '/' is not base64url
+		Expect(kb.UnmarshalJSON([]byte(`"AQAB"`))).To(Succeed())
+		Expect(kb.data).To(Equal([]byte{1, 0, 1}))
+		Expect(kb.UnmarshalJSON([]byte(`"\u0041Q\u0041B"`))).To(Succeed())
+		Expect(kb.data).To(Equal([]byte{1, 0, 1}))
+		Expect(kb.UnmarshalJSON([]byte(`"AQ\u00e9B"`))).ToNot(Succeed())
+		Expect(kb.UnmarshalJSON([]byte(`"AQ\u00"`))).ToNot(Succeed())
+		Expect(kb.UnmarshalJSON([]byte(`"AQ\nB"`))).ToNot(Succeed())
+		Expect(kb.UnmarshalJSON([]byte(`123`))).ToNot(Succeed())
+	})
+})
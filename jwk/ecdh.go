@@ -0,0 +1,185 @@
+package jwk
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/rakutentech/jwk-go/okp"
+)
+
+// ECDH performs an Elliptic Curve Diffie-Hellman key agreement between the
+// private key in the KeySpec and the public key of peer, and returns the raw
+// shared secret Z. Both keys must be EC keys (P-256, P-384 or P-521) or X25519
+// OKPs on the same curve. Invalid and low-order peer public keys are rejected.
+//
+// Z should not be used as a key directly; see DeriveECDHESKey() and
+// ConcatKDF().
+func (k *KeySpec) ECDH(peer *KeySpec) ([]byte, error) {
+	privateKey, err := ecdhPrivateKey(k.Key)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := ecdhPublicKey(peer.Key)
+	if err != nil {
+		return nil, err
+	}
+	if privateKey.Curve() != publicKey.Curve() {
+		_, curve, _ := k.KeyType()
+		_, peerCurve, _ := peer.KeyType()
+		return nil, fmt.Errorf("curve mismatch: cannot perform ECDH between %s and %s keys", curve, peerCurve)
+	}
+	z, err := privateKey.ECDH(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("ECDH failed: %w", err)
+	}
+	return z, nil
+}
+
+// DeriveECDHESKey performs ECDH with peer and derives a key with the Concat
+// KDF, as specified for the ECDH-ES algorithms of RFC 7518 section 4.6.
+//
+// For "ECDH-ES" (direct key agreement) the derived key is the content
+// encryption key for enc. For "ECDH-ES+A128KW", "ECDH-ES+A192KW" and
+// "ECDH-ES+A256KW" it is the AES key wrapping key, and enc is ignored.
+// apu and apv are the decoded 'apu' and 'apv' header parameters, if any.
+func (k *KeySpec) DeriveECDHESKey(peer *KeySpec, alg, enc string, apu, apv []byte) ([]byte, error) {
+	algID, keyAlg := enc, enc
+	switch wrapAlg, ok := ecdhKeyWrapAlgorithms[alg]; {
+	case ok:
+		algID, keyAlg = alg, wrapAlg
+	case alg != DefaultECKeyAlg:
+		return nil, fmt.Errorf("unsupported ECDH algorithm: %s", alg)
+	case !isContentEncryptionAlgorithm(enc):
+		return nil, fmt.Errorf("unsupported content encryption algorithm: %s", enc)
+	}
+	keySize, err := OctetKeySize(keyAlg)
+	if err != nil {
+		return nil, err
+	}
+	z, err := k.ECDH(peer)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(z)
+	return ConcatKDF(z, algID, keySize, apu, apv), nil
+}
+
+// ecdhKeyWrapAlgorithms maps the ECDH-ES algorithms with key wrapping to the
+// AES Key Wrap algorithm of the derived key
+var ecdhKeyWrapAlgorithms = map[string]string{
+	"ECDH-ES+A128KW": "A128KW",
+	"ECDH-ES+A192KW": "A192KW",
+	"ECDH-ES+A256KW": "A256KW",
+}
+
+// isContentEncryptionAlgorithm returns true for the 'enc' values defined by
+// RFC 7518 section 5.1
+func isContentEncryptionAlgorithm(enc string) bool {
+	switch enc {
+	case "A128CBC-HS256", "A192CBC-HS384", "A256CBC-HS512", "A128GCM", "A192GCM", "A256GCM":
+		return true
+	}
+	return false
+}
+
+// ConcatKDF derives a key of keySize bytes from the shared secret z with the
+// Concat KDF of NIST SP 800-56A using SHA-256, with the OtherInfo fields
+// defined in RFC 7518 section 4.6.2: algID is the 'enc' value for ECDH-ES and
+// the 'alg' value otherwise, and apu and apv are the decoded 'apu' and 'apv'
+// header parameters.
+func ConcatKDF(z []byte, algID string, keySize int, apu, apv []byte) []byte {
+	otherInfo := appendLengthPrefixed(nil, []byte(algID))
+	otherInfo = appendLengthPrefixed(otherInfo, apu)
+	otherInfo = appendLengthPrefixed(otherInfo, apv)
+	otherInfo = binary.BigEndian.AppendUint32(otherInfo, uint32(keySize)*8)
+
+	key := make([]byte, 0, keySize+sha256.Size)
+	h := sha256.New()
+	var counter [4]byte
+	for round := uint32(1); len(key) < keySize; round++ {
+		h.Reset()
+		binary.BigEndian.PutUint32(counter[:], round)
+		h.Write(counter[:])
+		h.Write(z)
+		h.Write(otherInfo)
+		key = h.Sum(key)
+	}
+	clear(key[keySize:])
+	return key[:keySize:keySize]
+}
+
+// ECDHPrivateKey returns the private key in the KeySpec (an EC key or an
+// X25519 OKP) as a crypto/ecdh key, for use in other ECDH-based protocols.
+func (k *KeySpec) ECDHPrivateKey() (*ecdh.PrivateKey, error) {
+	return ecdhPrivateKey(k.Key)
+}
+
+// ECDHPublicKey returns the public key in the KeySpec (an EC key or an X25519
+// OKP) as a crypto/ecdh key, for use in other ECDH-based protocols.
+func (k *KeySpec) ECDHPublicKey() (*ecdh.PublicKey, error) {
+	return ecdhPublicKey(k.Key)
+}
+
+func appendLengthPrefixed(dst, data []byte) []byte {
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(data)))
+	return append(dst, data...)
+}
+
+func ecdhPrivateKey(key interface{}) (*ecdh.PrivateKey, error) {
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		return key.ECDH()
+	case *ecdsa.PublicKey:
+		return nil, errors.New("a private key is required for ECDH")
+	case okp.CurveOctetKeyPair:
+		curve, err := okpECDHCurve(key)
+		if err != nil {
+			return nil, err
+		}
+		if key.PrivateKey() == nil {
+			return nil, errors.New("a private key is required for ECDH")
+		}
+		return curve.NewPrivateKey(key.PrivateKey())
+	default:
+		return nil, fmt.Errorf("unsupported key type for ECDH: %T", key)
+	}
+}
+
+func ecdhPublicKey(key interface{}) (*ecdh.PublicKey, error) {
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		return key.PublicKey.ECDH()
+	case *ecdsa.PublicKey:
+		return key.ECDH()
+	case okp.CurveOctetKeyPair:
+		if key.PublicKey() == nil && key.PrivateKey() != nil {
+			privateKey, err := ecdhPrivateKey(key)
+			if err != nil {
+				return nil, err
+			}
+			return privateKey.PublicKey(), nil
+		}
+		curve, err := okpECDHCurve(key)
+		if err != nil {
+			return nil, err
+		}
+		return curve.NewPublicKey(key.PublicKey())
+	default:
+		return nil, fmt.Errorf("unsupported key type for ECDH: %T", key)
+	}
+}
+
+func okpECDHCurve(key okp.CurveOctetKeyPair) (ecdh.Curve, error) {
+	switch key.Curve() {
+	case "X25519":
+		return ecdh.X25519(), nil
+	case "X448":
+		return nil, errors.New("ECDH with X448 is not supported yet")
+	default:
+		return nil, fmt.Errorf("curve %s does not support ECDH", key.Curve())
+	}
+}
@@ -0,0 +1,117 @@
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rakutentech/jwk-go/internal/testutils"
+	"github.com/rakutentech/jwk-go/okp"
+)
+
+var _ = Describe("ECDH", func() {
+	// RFC 7518 appendix C
+	alice := MustParseBytes([]byte(`{"kty":"EC","crv":"P-256",
+		"x":"gI0GAILBdu7T53akrFmMyGcsF3n5dO7MmwNBHKW5SV0",
+		"y":"SLW_xSffzlPWrHEVI30DHM_4egVwt3NQqeUD7nMFpps",
+		"d":"0_NxaRPUMQoAJt50Gz8YiTr8gRTwyEaCumd-MToTmIo"}`))
+	bob := MustParseBytes([]byte(`{"kty":"EC","crv":"P-256",
+		"x":"weNJy2HscCSM6AEDTDg04biOvhFhyyWvOHQfeF_PxMQ",
+		"y":"e8lnCO-AlStT-NJVX-crhB7QRYhiix03illJOVAOyck",
+		"d":"VEmDZpDXXK8p8N0Cndsxs924q6nS1RXFASRl6BfUqdw"}`))
+	bobPublic := testutils.Must(bob.PublicOnly())
+
+	// RFC 7748 section 6.1
+	aliceX25519 := NewSpec(okp.NewCurve25519(
+		mustDecodeHex("8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a"),
+		mustDecodeHex("77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a")))
+	bobX25519 := NewSpec(okp.NewCurve25519(
+		mustDecodeHex("de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f"),
+		mustDecodeHex("5dab087e624a8a4b79e17f8b83800ee66f3bb1292618b6fd1c2f8b27ff88e0eb")))
+
+	It("Should derive ECDH-ES keys with the Concat KDF", func() {
+		key := testutils.Must(alice.DeriveECDHESKey(bobPublic, "ECDH-ES", "A128GCM", []byte("Alice"), []byte("Bob")))
+		Expect(base64.RawURLEncoding.EncodeToString(key)).To(Equal("VqqN6vgjbSBcIijNcacQGg"))
+		Expect(bob.DeriveECDHESKey(alice, "ECDH-ES", "A128GCM", []byte("Alice"), []byte("Bob"))).To(Equal(key))
+
+		wrapKey := testutils.Must(alice.DeriveECDHESKey(bobPublic, "ECDH-ES+A256KW", "A128GCM", nil, nil))
+		Expect(wrapKey).To(HaveLen(32))
+		Expect(alice.DeriveECDHESKey(bobPublic, "ECDH-ES+A256KW", "A256GCM", nil, nil)).To(Equal(wrapKey))
+		Expect(alice.DeriveECDHESKey(bobPublic, "ECDH-ES", "A256CBC-HS512", nil, nil)).To(HaveLen(64))
+
+		for _, alg := range []string{"ECDH-ES+A256GCM", "ECDH-ES+A128GCMKW", "ECDH-ES+HS256", "A128KW"} {
+			_, err := alice.DeriveECDHESKey(bobPublic, alg, "A128GCM", nil, nil)
+			Expect(err).To(MatchError("unsupported ECDH algorithm: "+alg), alg)
+		}
+		for _, enc := range []string{"HS257", "HS256", "A128KW", "A128GCMKW", ""} {
+			_, err := alice.DeriveECDHESKey(bobPublic, "ECDH-ES", enc, nil, nil)
+			Expect(err).To(MatchError("unsupported content encryption algorithm: "+enc), enc)
+		}
+	})
+
+	It("Should agree on shared secrets", func() {
+		for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+			a := NewSpec(testutils.Must(ecdsa.GenerateKey(curve, rand.Reader)))
+			b := NewSpec(testutils.Must(ecdsa.GenerateKey(curve, rand.Reader)))
+			z := testutils.Must(a.ECDH(testutils.Must(b.PublicOnly())))
+			Expect(z).To(HaveLen((curve.Params().BitSize + 7) / 8))
+			Expect(b.ECDH(a)).To(Equal(z))
+		}
+
+		z := testutils.Must(aliceX25519.ECDH(testutils.Must(bobX25519.PublicOnly())))
+		Expect(z).To(Equal(mustDecodeHex("4a5d9d5ba4ce2de1728e3bf480350f25e07e21c947d19e3376f09b3c1e161742")))
+		Expect(bobX25519.ECDH(aliceX25519)).To(Equal(z))
+
+		generated := NewSpec(testutils.Must(okp.GenerateCurve25519(rand.Reader)))
+		Expect(generated.ECDH(bobX25519)).To(Equal(testutils.Must(bobX25519.ECDH(generated))))
+	})
+
+	It("Should reject mismatching curves", func() {
+		p384 := NewSpec(testutils.Must(ecdsa.GenerateKey(elliptic.P384(), rand.Reader)))
+		_, err := alice.ECDH(p384)
+		Expect(err).To(MatchError("curve mismatch: cannot perform ECDH between P-256 and P-384 keys"))
+		_, err = aliceX25519.ECDH(bob)
+		Expect(err).To(MatchError("curve mismatch: cannot perform ECDH between X25519 and P-256 keys"))
+	})
+
+	It("Should reject low-order points", func() {
+		for _, point := range [][]byte{
+			make([]byte, 32),
+			mustDecodeHex("0100000000000000000000000000000000000000000000000000000000000000"),
+			mustDecodeHex("e0eb7a7c3b41b8ae1656e3faf19fc46ada098deb9c32b1fd866205165f49b800"),
+		} {
+			_, err := aliceX25519.ECDH(NewSpec(okp.NewCurve25519(point, nil)))
+			Expect(err).To(MatchError(ContainSubstring("ECDH failed")))
+		}
+	})
+
+	It("Should reject unsupported keys", func() {
+		_, err := bobPublic.ECDH(alice)
+		Expect(err).To(MatchError("a private key is required for ECDH"))
+		_, err = NewSpec(okp.NewCurve25519(bobX25519.Key.(okp.Curve25519).PublicKey(), nil)).ECDH(aliceX25519)
+		Expect(err).To(MatchError("a private key is required for ECDH"))
+		_, err = NewSpec(okp.NewCurve448(make([]byte, 56), make([]byte, 56))).ECDH(aliceX25519)
+		Expect(err).To(MatchError("ECDH with X448 is not supported yet"))
+		_, err = NewSpec(testutils.Must(okp.GenerateEd25519(rand.Reader))).ECDH(aliceX25519)
+		Expect(err).To(MatchError("curve Ed25519 does not support ECDH"))
+		_, err = NewSpec(randomBytes(32)).ECDH(alice)
+		Expect(err).To(MatchError("unsupported key type for ECDH: []uint8"))
+		_, err = alice.ECDH(NewSpec(randomBytes(32)))
+		Expect(err).To(MatchError("unsupported key type for ECDH: []uint8"))
+	})
+})
+
+var _ = Describe("ConcatKDF", func() {
+	It("Should derive keys of any size", func() {
+		z := randomBytes(32)
+		for _, size := range []int{16, 32, 33, 64, 100} {
+			Expect(ConcatKDF(z, "A128GCM", size, nil, nil)).To(HaveLen(size))
+		}
+		Expect(ConcatKDF(z, "A128GCM", 16, nil, nil)).NotTo(Equal(ConcatKDF(z, "A128GCM", 16, []byte("a"), nil)))
+		Expect(ConcatKDF(z, "A128GCM", 16, nil, nil)).NotTo(Equal(ConcatKDF(z, "A128KW", 16, nil, nil)))
+	})
+})
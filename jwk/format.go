@@ -0,0 +1,251 @@
+package jwk
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rakutentech/jwk-go/jwktypes"
+)
+
+// The formatting methods below use value receivers (unlike most KeySpec
+// methods), so that both KeySpec values and pointers are redacted when they
+// are printed or logged.
+
+// String returns a human-readable description of the KeySpec, which contains
+// its metadata and thumbprint, but never any private key material.
+func (k KeySpec) String() string {
+	return k.describe(false)
+}
+
+// GoString returns a Go-syntax like description of the KeySpec, with all
+// private key material redacted.
+func (k KeySpec) GoString() string {
+	kty, crv, private := k.KeyType()
+	var sb strings.Builder
+	sb.WriteString("jwk.KeySpec{Key: <")
+	sb.WriteString(kty)
+	if crv != "" && crv != strconv.Itoa(k.KeySize()) {
+		sb.WriteString(" " + crv)
+	}
+	if private {
+		sb.WriteString(" private key REDACTED>")
+	} else {
+		sb.WriteString(" public key>")
+	}
+	fmt.Fprintf(&sb, ", KeyID: %q, Algorithm: %q, Use: %q", k.KeyID, k.Algorithm, k.Use)
+	for _, t := range k.times() {
+		fmt.Fprintf(&sb, ", %s: %s", t.goName, t.value.Format(time.RFC3339))
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// Format implements fmt.Formatter. All verbs print the same description as
+// String(), except for %#v (GoString()), %q (quoted String()) and %+v which
+// adds the validity window of the key.
+func (k KeySpec) Format(f fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && f.Flag('#'):
+		fmt.Fprint(f, k.GoString())
+	case verb == 'v' && f.Flag('+'):
+		fmt.Fprint(f, k.describe(true))
+	case verb == 'q':
+		fmt.Fprintf(f, "%q", k.String())
+	default:
+		// Never fall back to the default formatting, whatever the verb is.
+		fmt.Fprint(f, k.String())
+	}
+}
+
+// LogValue implements slog.LogValuer, logging the metadata and thumbprint of
+// the key, but never any private key material.
+func (k KeySpec) LogValue() slog.Value {
+	kty, crv, private := k.KeyType()
+	attrs := make([]slog.Attr, 0, 11)
+	attrs = appendStringAttr(attrs, "kid", k.KeyID)
+	attrs = appendStringAttr(attrs, "kty", kty)
+	attrs = appendStringAttr(attrs, "crv", k.curveName(crv))
+	attrs = append(attrs, slog.Int("size", k.KeySize()))
+	attrs = appendStringAttr(attrs, "use", k.Use)
+	attrs = appendStringAttr(attrs, "alg", k.Algorithm)
+	attrs = appendStringAttr(attrs, "thumbprint", k.thumbprintString())
+	attrs = append(attrs, slog.Bool("private", private))
+	for _, t := range k.times() {
+		attrs = append(attrs, slog.Time(t.name, t.value))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// String describes the symmetric key, redacting the key material
+func (k OctetKey) String() string {
+	return "OctetKey{size=" + strconv.Itoa(len(k)*8) + " k=REDACTED}"
+}
+
+// GoString describes the symmetric key, redacting the key material
+func (k OctetKey) GoString() string {
+	return "jwk.OctetKey{<" + strconv.Itoa(len(k)) + " bytes REDACTED>}"
+}
+
+// Format implements fmt.Formatter, redacting the key material
+func (k OctetKey) Format(f fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && f.Flag('#'):
+		fmt.Fprint(f, k.GoString())
+	case verb == 'q':
+		fmt.Fprintf(f, "%q", k.String())
+	default:
+		// Never fall back to the default formatting of the key bytes,
+		// whatever the verb is.
+		fmt.Fprint(f, k.String())
+	}
+}
+
+// LogValue implements slog.LogValuer, logging the size of the key but never
+// the key material.
+func (k OctetKey) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int("size", len(k)*8), slog.String("k", "REDACTED"))
+}
+
+// String returns a human-readable description of all the KeySpecs in the
+// KeySpecSet, without any private key material.
+func (ks KeySpecSet) String() string {
+	return ks.describe(func(k KeySpec) string { return k.String() })
+}
+
+// GoString returns a Go-syntax like description of the KeySpecSet, with all
+// private key material redacted.
+func (ks KeySpecSet) GoString() string {
+	parts := make([]string, len(ks.Keys))
+	for i, k := range ks.Keys {
+		parts[i] = k.GoString()
+	}
+	return "jwk.KeySpecSet{Keys: []jwk.KeySpec{" + strings.Join(parts, ", ") + "}}"
+}
+
+// Format implements fmt.Formatter, applying KeySpec.Format to every KeySpec.
+func (ks KeySpecSet) Format(f fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && f.Flag('#'):
+		fmt.Fprint(f, ks.GoString())
+	case verb == 'v' && f.Flag('+'):
+		fmt.Fprint(f, ks.describe(func(k KeySpec) string { return k.describe(true) }))
+	case verb == 'q':
+		fmt.Fprintf(f, "%q", ks.String())
+	default:
+		fmt.Fprint(f, ks.String())
+	}
+}
+
+// LogValue implements slog.LogValuer, logging every KeySpec with
+// KeySpec.LogValue.
+func (ks KeySpecSet) LogValue() slog.Value {
+	keys := make([]slog.Attr, len(ks.Keys))
+	for i, k := range ks.Keys {
+		keys[i] = slog.Attr{Key: strconv.Itoa(i), Value: k.LogValue()}
+	}
+	return slog.GroupValue(
+		slog.Int("count", len(ks.Keys)),
+		slog.Attr{Key: "keys", Value: slog.GroupValue(keys...)},
+	)
+}
+
+func (ks KeySpecSet) describe(describeKey func(k KeySpec) string) string {
+	parts := make([]string, len(ks.Keys))
+	for i, k := range ks.Keys {
+		parts[i] = describeKey(k)
+	}
+	return "KeySpecSet[" + strings.Join(parts, ", ") + "]"
+}
+
+func (k *KeySpec) describe(withTimes bool) string {
+	kty, crv, private := k.KeyType()
+	var sb strings.Builder
+	sb.WriteString("KeySpec{")
+	field := func(name, value string) {
+		if value == "" {
+			return
+		}
+		if sb.Len() > len("KeySpec{") {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(name)
+		sb.WriteByte('=')
+		sb.WriteString(value)
+	}
+	if k.KeyID != "" {
+		field("kid", strconv.Quote(k.KeyID))
+	}
+	field("kty", kty)
+	field("crv", k.curveName(crv))
+	if size := k.KeySize(); size > 0 {
+		field("size", strconv.Itoa(size))
+	}
+	field("use", k.Use)
+	field("alg", k.Algorithm)
+	field("thumbprint", k.thumbprintString())
+	if private {
+		field("private", "REDACTED")
+	}
+	if withTimes {
+		for _, t := range k.times() {
+			field(t.name, t.value.Format(time.RFC3339))
+		}
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// curveName returns the curve name returned by KeyType, unless it is just
+// the key size (as is the case for RSA keys).
+func (k *KeySpec) curveName(crv string) string {
+	if crv == strconv.Itoa(k.KeySize()) {
+		return ""
+	}
+	return crv
+}
+
+// thumbprintString returns the encoded thumbprint of asymmetric keys. The
+// thumbprint of a symmetric key is a hash of the secret, which would allow
+// checking guesses offline, so it is never printed.
+func (k *KeySpec) thumbprintString() string {
+	if k.IsKeyType(jwktypes.OctetKey) {
+		return ""
+	}
+	thumbprint, err := k.Thumbprint()
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(thumbprint)
+}
+
+type namedTime struct {
+	name   string
+	goName string
+	value  time.Time
+}
+
+// times returns the validity window fields which are set
+func (k *KeySpec) times() []namedTime {
+	var times []namedTime
+	for _, t := range []namedTime{
+		{"nbf", "NotBefore", k.NotBefore},
+		{"iat", "IssuedAt", k.IssuedAt},
+		{"exp", "ExpiresAt", k.ExpiresAt},
+	} {
+		if !t.value.IsZero() {
+			times = append(times, t)
+		}
+	}
+	return times
+}
+
+func appendStringAttr(attrs []slog.Attr, key, value string) []slog.Attr {
+	if value == "" {
+		return attrs
+	}
+	return append(attrs, slog.String(key, value))
+}
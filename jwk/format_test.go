@@ -0,0 +1,111 @@
+package jwk
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rakutentech/jwk-go/internal/testutils"
+	"github.com/rakutentech/jwk-go/okp"
+)
+
+var _ = Describe("Formatting", func() {
+	rsaKey := testutils.Must(rsa.GenerateKey(rand.Reader, 2048))
+	ecKey := testutils.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader))
+	secret := []byte("this is a very secret HMAC key!!")
+
+	keys := KeySpecSet{Keys: []KeySpec{
+		{Key: rsaKey, KeyID: "my-rsa", Use: "sig", Algorithm: "RS256"},
+		{Key: ecKey, KeyID: "my-ec", Use: "sig", Algorithm: "ES256", ExpiresAt: time.Unix(1700000000, 0)},
+		{Key: Ed25519Example, KeyID: "my-ed25519"},
+		{Key: X25519Example, KeyID: "my-x25519"},
+		{Key: secret, KeyID: "my-hmac"},
+		{Key: OctetKey(secret), KeyID: "my-octet-key"},
+	}}
+
+	// secrets contains the encodings of private key material we must never print
+	secrets := []string{
+		Ed25519d,
+		X25519d,
+		string(secret),
+		fmt.Sprintf("%v", secret),
+		fmt.Sprintf("%x", secret),
+		base64.RawURLEncoding.EncodeToString(secret),
+		rsaKey.D.String(),
+		rsaKey.Primes[0].String(),
+		ecKey.D.String(),
+		fmt.Sprintf("%x", ecKey.D),
+		fmt.Sprintf("%v", testutils.MustDecodeBase64URL(Ed25519d)),
+	}
+
+	expectRedacted := func(s string) {
+		for _, secret := range secrets {
+			Expect(s).ToNot(ContainSubstring(secret))
+		}
+	}
+
+	It("Should redact private keys with all verbs", func() {
+		for _, format := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%X", "%d"} {
+			for _, k := range keys.Keys {
+				expectRedacted(fmt.Sprintf(format, k))
+				expectRedacted(fmt.Sprintf(format, &k))
+				if keyPair, ok := k.Key.(okp.CurveOctetKeyPair); ok {
+					expectRedacted(fmt.Sprintf(format, keyPair))
+				}
+				if octetKey, ok := k.Key.(OctetKey); ok {
+					expectRedacted(fmt.Sprintf(format, octetKey))
+				}
+			}
+			expectRedacted(fmt.Sprintf(format, keys))
+			expectRedacted(fmt.Sprintf(format, &keys))
+		}
+	})
+
+	It("Should redact private keys in slog output", func() {
+		var buf bytes.Buffer
+		for _, h := range []slog.Handler{slog.NewTextHandler(&buf, nil), slog.NewJSONHandler(&buf, nil)} {
+			logger := slog.New(h)
+			logger.Info("keys", "set", keys, "key", keys.Keys[0], "ptr", &keys.Keys[1], "okp", Ed25519Example,
+				"oct", keys.Keys[5].Key)
+		}
+		expectRedacted(buf.String())
+		Expect(buf.String()).To(ContainSubstring("my-rsa"))
+		Expect(buf.String()).To(ContainSubstring(`"kid":"my-ec"`))
+	})
+
+	It("Should describe keys", func() {
+		thumbprint := testutils.Must(keys.Keys[1].Thumbprint())
+		Expect(keys.Keys[1].String()).To(Equal(
+			`KeySpec{kid="my-ec" kty=EC crv=P-256 size=256 use=sig alg=ES256 thumbprint=` +
+				base64.RawURLEncoding.EncodeToString(thumbprint) + ` private=REDACTED}`))
+		Expect(fmt.Sprintf("%+v", keys.Keys[1])).To(ContainSubstring("exp=" + time.Unix(1700000000, 0).Format(time.RFC3339)))
+		Expect(keys.Keys[0].String()).To(ContainSubstring("kty=RSA size=2048 use=sig"))
+		Expect(keys.Keys[2].String()).To(ContainSubstring("kty=OKP crv=Ed25519 size=256"))
+		Expect(keys.Keys[4].String()).To(Equal(`KeySpec{kid="my-hmac" kty=oct size=256 private=REDACTED}`))
+		Expect(keys.Keys[5].String()).NotTo(ContainSubstring("thumbprint"))
+		Expect(fmt.Sprint(keys.Keys[5].Key)).To(Equal("OctetKey{size=256 k=REDACTED}"))
+		Expect(fmt.Sprintf("%#v", keys.Keys[5].Key)).To(Equal("jwk.OctetKey{<32 bytes REDACTED>}"))
+		Expect(fmt.Sprintf("%#v", keys.Keys[1])).To(HavePrefix(`jwk.KeySpec{Key: <EC P-256 private key REDACTED>, KeyID: "my-ec"`))
+		Expect(fmt.Sprint(Ed25519Example)).To(Equal("Ed25519{x=" + Ed25519x + " d=REDACTED}"))
+
+		pub := testutils.Must(keys.Keys[0].PublicOnly())
+		Expect(pub.String()).ToNot(ContainSubstring("private"))
+		Expect(pub.GoString()).To(ContainSubstring("<RSA public key>"))
+	})
+
+	It("Should not be fooled by big.Int formatting", func() {
+		// Sanity check: the default formatting of these keys leaks
+		Expect(fmt.Sprintf("%v", *ecKey)).To(ContainSubstring(ecKey.D.String()))
+		Expect(new(big.Int).Set(ecKey.D).String()).To(Equal(ecKey.D.String()))
+	})
+})
@@ -0,0 +1,691 @@
+package jwk
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/rakutentech/jwk-go/jwktypes"
+)
+
+// maxNestingDepth is the maximum nesting depth of JSON values, the same as
+// encoding/json
+const maxNestingDepth = 10000
+
+var errUnexpectedEnd = errors.New("malformed JWK: unexpected end of JSON input")
+
+// jsonUnmarshaller is the counterpart of orderedJsonMarshaller: a reflection
+// free JSON decoder for JWKs. It gives the same results as decoding a JWK with
+// encoding/json: member names are matched case-insensitively, the last
+// duplicate member wins, null only clears key material and invalid UTF-8 is
+// replaced with U+FFFD.
+//
+// Key material is decoded directly from the input once the whole JWK was
+// scanned, so that all the byte members share a single buffer.
+type jsonUnmarshaller struct {
+	data []byte
+	off  int
+
+	pending  [16]byteMember
+	npending int
+}
+
+// byteMember is a base64url encoded member waiting to be decoded
+type byteMember struct {
+	value **keyBytes
+	raw   []byte // nil for null
+}
+
+// jwkMember identifies the field of JWK a member is decoded into
+type jwkMember int
+
+const (
+	memberUnknown jwkMember = iota
+	memberKid
+	memberKty
+	memberAlg
+	memberCrv
+	memberUse
+	memberX
+	memberY
+	memberN
+	memberE
+	memberD
+	memberP
+	memberQ
+	memberDp
+	memberDq
+	memberQi
+	memberK
+	memberExp
+	memberNbf
+	memberIat
+)
+
+func (u *jsonUnmarshaller) unmarshalJWK(jwk *JWK) error {
+	if err := u.scanJWK(jwk); err != nil {
+		return err
+	}
+	return u.decodePending()
+}
+
+func (u *jsonUnmarshaller) scanJWK(jwk *JWK) error {
+	u.skipSpace()
+	if u.consumeLiteral("null") {
+		// Like encoding/json, null leaves the JWK unchanged
+		return u.expectEnd()
+	}
+	if err := u.expect('{'); err != nil {
+		return err
+	}
+	u.skipSpace()
+	if u.peek() == '}' {
+		u.off++
+		return u.expectEnd()
+	}
+	for {
+		u.skipSpace()
+		if u.peek() != '"' {
+			return u.syntaxError("looking for beginning of object key string")
+		}
+		name, escaped, err := u.scanString()
+		if err != nil {
+			return err
+		}
+		var buf [32]byte
+		if escaped {
+			name = unquoteJSONString(buf[:0], name)
+		}
+		member := lookupMember(name)
+
+		u.skipSpace()
+		if err := u.expect(':'); err != nil {
+			return err
+		}
+		u.skipSpace()
+		if err := u.unmarshalMember(jwk, member, name); err != nil {
+			return err
+		}
+
+		u.skipSpace()
+		switch u.peek() {
+		case ',':
+			u.off++
+		case '}':
+			u.off++
+			return u.expectEnd()
+		default:
+			return u.syntaxError("after object key:value pair")
+		}
+	}
+}
+
+func (u *jsonUnmarshaller) unmarshalMember(jwk *JWK, member jwkMember, name []byte) error {
+	switch member {
+	case memberKid:
+		return u.unmarshalString(&jwk.Kid, name)
+	case memberKty:
+		return u.unmarshalString(&jwk.Kty, name)
+	case memberAlg:
+		return u.unmarshalString(&jwk.Alg, name)
+	case memberCrv:
+		return u.unmarshalString(&jwk.Crv, name)
+	case memberUse:
+		return u.unmarshalString(&jwk.Use, name)
+	case memberX:
+		return u.unmarshalBytes(&jwk.X)
+	case memberY:
+		return u.unmarshalBytes(&jwk.Y)
+	case memberN:
+		return u.unmarshalBytes(&jwk.N)
+	case memberE:
+		return u.unmarshalBytes(&jwk.E)
+	case memberD:
+		return u.unmarshalBytes(&jwk.D)
+	case memberP:
+		return u.unmarshalBytes(&jwk.P)
+	case memberQ:
+		return u.unmarshalBytes(&jwk.Q)
+	case memberDp:
+		return u.unmarshalBytes(&jwk.Dp)
+	case memberDq:
+		return u.unmarshalBytes(&jwk.Dq)
+	case memberQi:
+		return u.unmarshalBytes(&jwk.Qi)
+	case memberK:
+		return u.unmarshalBytes(&jwk.K)
+	case memberExp:
+		return u.unmarshalInt(&jwk.Exp, name)
+	case memberNbf:
+		return u.unmarshalInt(&jwk.Nbf, name)
+	case memberIat:
+		return u.unmarshalInt(&jwk.Iat, name)
+	default:
+		return u.skipValue(1)
+	}
+}
+
+func (u *jsonUnmarshaller) unmarshalString(value *string, name []byte) error {
+	if u.consumeLiteral("null") {
+		return nil
+	}
+	if u.peek() != '"' {
+		return u.typeError(name, "a string")
+	}
+	s, escaped, err := u.scanString()
+	if err != nil {
+		return err
+	}
+	if escaped {
+		*value = string(unquoteJSONString(nil, s))
+	} else {
+		*value = internString(s)
+	}
+	return nil
+}
+
+func (u *jsonUnmarshaller) unmarshalInt(value *int64, name []byte) error {
+	if u.consumeLiteral("null") {
+		return nil
+	}
+	c := u.peek()
+	if c != '-' && (c < '0' || c > '9') {
+		return u.typeError(name, "an integer")
+	}
+	start := u.off
+	if err := u.scanNumber(); err != nil {
+		return err
+	}
+	n, ok := parseInt64(u.data[start:u.off])
+	if !ok {
+		return u.typeError(name, "an integer")
+	}
+	*value = n
+	return nil
+}
+
+func (u *jsonUnmarshaller) unmarshalBytes(value **keyBytes) error {
+	member := byteMember{value: value}
+	if !u.consumeLiteral("null") {
+		start := u.off
+		if err := u.skipValue(1); err != nil {
+			return err
+		}
+		member.raw = u.data[start:u.off]
+	}
+	if u.npending == len(u.pending) {
+		// Only possible with duplicate members
+		if err := u.decodePending(); err != nil {
+			return err
+		}
+	}
+	u.pending[u.npending] = member
+	u.npending++
+	return nil
+}
+
+// decodePending decodes the pending byte members in order, with the same
+// semantics as keyBytes.UnmarshalJSON
+func (u *jsonUnmarshaller) decodePending() error {
+	pending := u.pending[:u.npending]
+	u.npending = 0
+	size, count := 0, 0
+	for _, m := range pending {
+		if m.raw != nil {
+			size += base64.RawURLEncoding.DecodedLen(len(m.raw))
+			count++
+		}
+	}
+	if count == 0 {
+		for _, m := range pending {
+			*m.value = nil
+		}
+		return nil
+	}
+
+	buf := make([]byte, size)
+	values := make([]keyBytes, count)
+	for _, m := range pending {
+		if m.raw == nil {
+			*m.value = nil
+			continue
+		}
+		if *m.value == nil {
+			*m.value = &values[0]
+			values = values[1:]
+		}
+		n, err := (*m.value).unmarshalJSONInto(m.raw, buf)
+		if err != nil {
+			return err
+		}
+		buf = buf[n:]
+	}
+	return nil
+}
+
+// lookupMember finds the JWK member matching the unquoted name, using the
+// same case-insensitive matching as encoding/json.
+func lookupMember(name []byte) jwkMember {
+	if member := memberByName(name); member != memberUnknown {
+		return member
+	}
+	var buf [32]byte
+	return memberByName(foldName(buf[:0], name))
+}
+
+func memberByName(name []byte) jwkMember {
+	switch string(name) {
+	case "kid", "KID":
+		return memberKid
+	case "kty", "KTY":
+		return memberKty
+	case "alg", "ALG":
+		return memberAlg
+	case "crv", "CRV":
+		return memberCrv
+	case "use", "USE":
+		return memberUse
+	case "x", "X":
+		return memberX
+	case "y", "Y":
+		return memberY
+	case "n", "N":
+		return memberN
+	case "e", "E":
+		return memberE
+	case "d", "D":
+		return memberD
+	case "p", "P":
+		return memberP
+	case "q", "Q":
+		return memberQ
+	case "dp", "DP":
+		return memberDp
+	case "dq", "DQ":
+		return memberDq
+	case "qi", "QI":
+		return memberQi
+	case "k", "K":
+		return memberK
+	case "exp", "EXP":
+		return memberExp
+	case "nbf", "NBF":
+		return memberNbf
+	case "iat", "IAT":
+		return memberIat
+	default:
+		return memberUnknown
+	}
+}
+
+// foldName appends the case folded name to dst, exactly like encoding/json
+// folds member names: ASCII letters are converted to upper case and other
+// runes to the smallest rune of their fold set.
+func foldName(dst []byte, name []byte) []byte {
+	for i := 0; i < len(name); {
+		if c := name[i]; c < utf8.RuneSelf {
+			if 'a' <= c && c <= 'z' {
+				c -= 'a' - 'A'
+			}
+			dst = append(dst, c)
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(name[i:])
+		for {
+			folded := unicode.SimpleFold(r)
+			if folded <= r {
+				r = folded
+				break
+			}
+			r = folded
+		}
+		dst = utf8.AppendRune(dst, r)
+		i += size
+	}
+	return dst
+}
+
+// internString returns common member values without allocating
+func internString(b []byte) string {
+	switch string(b) {
+	case jwktypes.EC:
+		return jwktypes.EC
+	case jwktypes.RSA:
+		return jwktypes.RSA
+	case jwktypes.OKP:
+		return jwktypes.OKP
+	case jwktypes.OctetKey:
+		return jwktypes.OctetKey
+	case "sig":
+		return "sig"
+	case "enc":
+		return "enc"
+	case "P-256":
+		return "P-256"
+	case "P-384":
+		return "P-384"
+	case "P-521":
+		return "P-521"
+	case "Ed25519":
+		return "Ed25519"
+	case "X25519":
+		return "X25519"
+	case "EdDSA":
+		return "EdDSA"
+	case "ES256":
+		return "ES256"
+	case "RS256":
+		return "RS256"
+	case "PS256":
+		return "PS256"
+	}
+	return string(b)
+}
+
+// parseInt64 parses a JSON number as an int64, like strconv.ParseInt.
+// Numbers with a fraction or exponent and numbers which overflow are rejected.
+func parseInt64(b []byte) (int64, bool) {
+	negative := len(b) > 0 && b[0] == '-'
+	if negative {
+		b = b[1:]
+	}
+	if len(b) == 0 || len(b) > 19 {
+		return 0, false
+	}
+	var n uint64
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + uint64(c-'0')
+	}
+	switch {
+	case negative && n <= 1<<63:
+		return -int64(n), true
+	case !negative && n < 1<<63:
+		return int64(n), true
+	default:
+		return 0, false
+	}
+}
+
+func (u *jsonUnmarshaller) peek() byte {
+	if u.off < len(u.data) {
+		return u.data[u.off]
+	}
+	return 0
+}
+
+func (u *jsonUnmarshaller) skipSpace() {
+	for u.off < len(u.data) {
+		switch u.data[u.off] {
+		case ' ', '\t', '\n', '\r':
+			u.off++
+		default:
+			return
+		}
+	}
+}
+
+func (u *jsonUnmarshaller) expect(c byte) error {
+	if u.peek() != c {
+		return u.syntaxError(fmt.Sprintf("expected %q", c))
+	}
+	u.off++
+	return nil
+}
+
+func (u *jsonUnmarshaller) expectEnd() error {
+	u.skipSpace()
+	if u.off < len(u.data) {
+		return u.syntaxError("after top-level value")
+	}
+	return nil
+}
+
+func (u *jsonUnmarshaller) consumeLiteral(literal string) bool {
+	if len(u.data)-u.off >= len(literal) && string(u.data[u.off:u.off+len(literal)]) == literal {
+		u.off += len(literal)
+		return true
+	}
+	return false
+}
+
+// skipValue skips a JSON value nested at the given depth, validating it.
+func (u *jsonUnmarshaller) skipValue(depth int) error {
+	switch c := u.peek(); {
+	case c == '"':
+		_, _, err := u.scanString()
+		return err
+	case c == '-' || ('0' <= c && c <= '9'):
+		return u.scanNumber()
+	case c == '{' || c == '[':
+		if depth >= maxNestingDepth {
+			return u.syntaxError("exceeded max depth")
+		}
+		return u.skipContainer(depth + 1)
+	case u.consumeLiteral("true"), u.consumeLiteral("false"), u.consumeLiteral("null"):
+		return nil
+	default:
+		return u.syntaxError("looking for beginning of value")
+	}
+}
+
+func (u *jsonUnmarshaller) skipContainer(depth int) error {
+	isObject := u.data[u.off] == '{'
+	closing := byte(']')
+	if isObject {
+		closing = '}'
+	}
+	u.off++
+	u.skipSpace()
+	if u.peek() == closing {
+		u.off++
+		return nil
+	}
+	for {
+		u.skipSpace()
+		if isObject {
+			if u.peek() != '"' {
+				return u.syntaxError("looking for beginning of object key string")
+			}
+			if _, _, err := u.scanString(); err != nil {
+				return err
+			}
+			u.skipSpace()
+			if err := u.expect(':'); err != nil {
+				return err
+			}
+			u.skipSpace()
+		}
+		if err := u.skipValue(depth); err != nil {
+			return err
+		}
+		u.skipSpace()
+		switch u.peek() {
+		case ',':
+			u.off++
+		case closing:
+			u.off++
+			return nil
+		default:
+			return u.syntaxError("after value")
+		}
+	}
+}
+
+// scanString scans a JSON string and returns its contents without the
+// quotes. escaped is true if the contents need to be unquoted with
+// unquoteJSONString, because they contain escape sequences or invalid UTF-8.
+func (u *jsonUnmarshaller) scanString() (s []byte, escaped bool, err error) {
+	u.off++ // Opening quote
+	start := u.off
+	nonASCII := false
+	for u.off < len(u.data) {
+		c := u.data[u.off]
+		switch {
+		case c == '"':
+			s = u.data[start:u.off]
+			u.off++
+			if nonASCII && !escaped {
+				escaped = !utf8.Valid(s)
+			}
+			return s, escaped, nil
+		case c == '\\':
+			escaped = true
+			u.off++
+			switch u.peek() {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+				u.off++
+			case 'u':
+				u.off++
+				for i := 0; i < 4; i++ {
+					if !isHexDigit(u.peek()) {
+						return nil, false, u.syntaxError("in \\u hexadecimal character escape")
+					}
+					u.off++
+				}
+			default:
+				return nil, false, u.syntaxError("in string escape code")
+			}
+		case c < 0x20:
+			return nil, false, u.syntaxError("in string literal")
+		default:
+			nonASCII = nonASCII || c >= utf8.RuneSelf
+			u.off++
+		}
+	}
+	return nil, false, errUnexpectedEnd
+}
+
+// scanNumber scans a JSON number
+func (u *jsonUnmarshaller) scanNumber() error {
+	if u.peek() == '-' {
+		u.off++
+	}
+	switch c := u.peek(); {
+	case c == '0':
+		u.off++
+	case '1' <= c && c <= '9':
+		u.skipDigits()
+	default:
+		return u.syntaxError("in numeric literal")
+	}
+	if u.peek() == '.' {
+		u.off++
+		if !isDigit(u.peek()) {
+			return u.syntaxError("after decimal point in numeric literal")
+		}
+		u.skipDigits()
+	}
+	if c := u.peek(); c == 'e' || c == 'E' {
+		u.off++
+		if c := u.peek(); c == '+' || c == '-' {
+			u.off++
+		}
+		if !isDigit(u.peek()) {
+			return u.syntaxError("in exponent of numeric literal")
+		}
+		u.skipDigits()
+	}
+	return nil
+}
+
+func (u *jsonUnmarshaller) skipDigits() {
+	for isDigit(u.peek()) {
+		u.off++
+	}
+}
+
+func (u *jsonUnmarshaller) syntaxError(context string) error {
+	if u.off >= len(u.data) {
+		return errUnexpectedEnd
+	}
+	return fmt.Errorf("malformed JWK: invalid character %q %s at offset %d", u.data[u.off], context, u.off)
+}
+
+func (u *jsonUnmarshaller) typeError(name []byte, expected string) error {
+	return fmt.Errorf("malformed JWK: member %q must be %s", string(name), expected)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+// unquoteJSONString appends the unquoted contents of a valid JSON string to
+// dst. Like encoding/json, invalid UTF-8 and invalid surrogate pairs are
+// replaced with U+FFFD.
+func unquoteJSONString(dst []byte, s []byte) []byte {
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\':
+			i++
+			switch s[i] {
+			case 'b':
+				dst = append(dst, '\b')
+			case 'f':
+				dst = append(dst, '\f')
+			case 'n':
+				dst = append(dst, '\n')
+			case 'r':
+				dst = append(dst, '\r')
+			case 't':
+				dst = append(dst, '\t')
+			case 'u':
+				r := decodeHex4(s[i+1:])
+				i += 4
+				if utf16.IsSurrogate(r) {
+					r2 := rune(-1)
+					if i+6 < len(s) && s[i+1] == '\\' && s[i+2] == 'u' {
+						r2 = decodeHex4(s[i+3:])
+					}
+					if decoded := utf16.DecodeRune(r, r2); decoded != unicode.ReplacementChar {
+						r = decoded
+						i += 6
+					} else {
+						r = unicode.ReplacementChar
+					}
+				}
+				dst = utf8.AppendRune(dst, r)
+			default: // '"', '\\' and '/'
+				dst = append(dst, s[i])
+			}
+			i++
+		case c < utf8.RuneSelf:
+			dst = append(dst, c)
+			i++
+		default:
+			r, size := utf8.DecodeRune(s[i:])
+			dst = utf8.AppendRune(dst, r)
+			i += size
+		}
+	}
+	return dst
+}
+
+// decodeHex4 decodes the 4 hexadecimal digits at the beginning of b, which
+// were already validated by scanString.
+func decodeHex4(b []byte) rune {
+	var r rune
+	for _, c := range b[:4] {
+		switch {
+		case isDigit(c):
+			c -= '0'
+		case 'a' <= c && c <= 'f':
+			c = c - 'a' + 10
+		default:
+			c = c - 'A' + 10
+		}
+		r = r<<4 | rune(c)
+	}
+	return r
+}
@@ -0,0 +1,156 @@
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rakutentech/jwk-go/internal/testutils"
+	"github.com/rakutentech/jwk-go/okp"
+)
+
+// reflectJWK has the same fields as JWK, but is decoded by encoding/json
+// with reflection
+type reflectJWK JWK
+
+// compareWithReflection decodes data with both jsonUnmarshaller and
+// encoding/json and returns a description of the difference, if any.
+func compareWithReflection(data []byte) string {
+	var expected, actual JWK
+	expectedErr := json.Unmarshal(data, (*reflectJWK)(&expected))
+	err := actual.UnmarshalJSON(data)
+	switch {
+	case (err == nil) != (expectedErr == nil):
+		return "got error " + errString(err) + ", but encoding/json returned " + errString(expectedErr)
+	case err == nil && !reflect.DeepEqual(actual, expected):
+		return "decoded a different JWK than encoding/json"
+	default:
+		return ""
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return "<nil>"
+	}
+	return err.Error()
+}
+
+var jsonUnmarshallerSeeds = []string{
+	`{"kty":"oct","kid":"a","k":"AQID","exp":1700000000,"nbf":0,"iat":-1}`,
+	`{"KID":"upper","Kty":"oct","K":"AQID","DP":"AQID"}`,
+	`{"Kid":"kelvin sign","ſig":"long s"}`,
+	`{"kid":"escaped \"name\"","x":"AQID"}`,
+	`{"kid":"a","kid":"b","x":"AQID","x":"","y":"AQID","y":null,"y":"BAUG"}`,
+	`{"kid":null,"exp":null,"x":null,"alg":"RS256"}`,
+	`{` + strings.Repeat(`"x":"AQID","y":null,"Y":"AQ",`, 10) + `"x":""}`,
+	"{\"kid\":\"\xff\xfe invalid \xed\xa0\x80 utf-8\"}",
+	`{"kid":"\ud800 \udc00 😀 \ud83dA   \/\b\f\n\r\t"}`,
+	`{"unknown":{"nested":[1,-2.5e+3,true,false,null,"s",{},[]]},"kty":"EC"}`,
+	` { "kid" : "spaces" , "exp" : -9223372036854775808 } `,
+	`null`,
+	`{}`,
+	``,
+	`[]`,
+	`"kid"`,
+	`{"exp":1.5}`,
+	`{"exp":1e3}`,
+	`{"exp":"1"}`,
+	`{"exp":9223372036854775808}`,
+	`{"kid":1}`,
+	`{"kid":{}}`,
+	`{"x":1}`,
+	`{"x":"!!"}`,
+	`{"x":"\n"}`,
+	`{"a":1,}`,
+	`{"a":01}`,
+	`{"a":-}`,
+	`{"a":1.}`,
+	`{"a":tru}`,
+	`{"a":"\x}`,
+	`{"a":"\u12"}`,
+	"{\"a\":\"\x01\"}",
+	`{} x`,
+	`{"a" 1}`,
+	`{"kid":"a"`,
+	`{"a":[1 2]}`,
+	`{"a":{"b"}}`,
+}
+
+var _ = Describe("jsonUnmarshaller", func() {
+	It("Should decode like encoding/json", func() {
+		for _, data := range jsonUnmarshallerSeeds {
+			Expect(compareWithReflection([]byte(data))).To(BeEmpty(), data)
+		}
+	})
+
+	It("Should decode generated keys like encoding/json", func() {
+		for _, key := range []interface{}{
+			testutils.Must(rsa.GenerateKey(rand.Reader, 2048)),
+			testutils.Must(ecdsa.GenerateKey(elliptic.P384(), rand.Reader)),
+			testutils.Must(okp.GenerateEd25519(rand.Reader)),
+			randomBytes(32),
+		} {
+			data := testutils.Must(NewSpecWithID("kid", key).MarshalJSON())
+			Expect(compareWithReflection(data)).To(BeEmpty(), string(data))
+		}
+	})
+
+	It("Should limit the nesting depth", func() {
+		nested := func(depth int) []byte {
+			return []byte(`{"a":` + strings.Repeat("[", depth-1) + strings.Repeat("]", depth-1) + `}`)
+		}
+		var jwk JWK
+		Expect(jwk.UnmarshalJSON(nested(maxNestingDepth))).To(Succeed())
+		Expect(jwk.UnmarshalJSON(nested(maxNestingDepth + 1))).To(MatchError(ContainSubstring("exceeded max depth")))
+		Expect(compareWithReflection(nested(maxNestingDepth + 1))).To(BeEmpty())
+	})
+
+	It("Should report errors", func() {
+		var jwk JWK
+		Expect(jwk.UnmarshalJSON([]byte(`{"kid":1}`))).To(MatchError(`malformed JWK: member "kid" must be a string`))
+		Expect(jwk.UnmarshalJSON([]byte(`{"exp":1.5}`))).To(MatchError(`malformed JWK: member "exp" must be an integer`))
+		Expect(jwk.UnmarshalJSON([]byte(`{"kid":"a"`))).To(MatchError("malformed JWK: unexpected end of JSON input"))
+		Expect(jwk.UnmarshalJSON([]byte(`{"a":01}`))).
+			To(MatchError("malformed JWK: invalid character '1' after object key:value pair at offset 6"))
+	})
+})
+
+func FuzzJWKUnmarshalJSON(f *testing.F) {
+	for _, data := range jsonUnmarshallerSeeds {
+		f.Add([]byte(data))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if diff := compareWithReflection(data); diff != "" {
+			t.Errorf("%q: %s", data, diff)
+		}
+	})
+}
+
+func BenchmarkJWKUnmarshalJSON(b *testing.B) {
+	key := NewSpecWithID("kid", testutils.Must(rsa.GenerateKey(rand.Reader, 2048)))
+	data := testutils.Must(key.MarshalJSON())
+
+	b.Run("tokenizer", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var jwk JWK
+			_ = jwk.UnmarshalJSON(data)
+		}
+	})
+	b.Run("reflection", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var jwk JWK
+			_ = json.Unmarshal(data, (*reflectJWK)(&jwk))
+		}
+	})
+}
@@ -27,33 +27,25 @@ type JWK struct {
 
 	// Non-standard (but absolutely necessary!) fields
 	Exp int64 `json:"exp"`
+	Nbf int64 `json:"nbf"`
+	Iat int64 `json:"iat"`
 }
 
 func (jwk *JWK) MarshalJSON() ([]byte, error) {
 	m := newOrderedJsonMarshaller(128)
 
-	var err error
-	err = m.marshalString("kid", jwk.Kid)
-	if err != nil {
-		return nil, err
-	}
-	err = m.marshalString("kty", jwk.Kty)
-	if err != nil {
-		return nil, err
-	}
-	err = m.marshalString("use", jwk.Use)
-	if err != nil {
-		return nil, err
-	}
-	err = m.marshalString("alg", jwk.Alg)
-	if err != nil {
-		return nil, err
-	}
-	err = m.marshalString("crv", jwk.Crv)
-	if err != nil {
-		return nil, err
-	}
+	m.marshalString("kid", jwk.Kid)
+	m.marshalString("kty", jwk.Kty)
+	m.marshalString("use", jwk.Use)
+	m.marshalString("alg", jwk.Alg)
+	m.marshalString("crv", jwk.Crv)
 	m.marshalInt("exp", jwk.Exp)
+	m.marshalInt("nbf", jwk.Nbf)
+	m.marshalInt("iat", jwk.Iat)
+
+	// Make room for all the key fields at once, so private key material is
+	// never left behind in discarded buffers when the buffer grows.
+	m.grow(bytesFieldsLen(jwk.X, jwk.Y, jwk.N, jwk.E, jwk.D, jwk.P, jwk.Q, jwk.K, jwk.Dp, jwk.Dq, jwk.Qi))
 	m.marshalBytes("x", jwk.X)
 	m.marshalBytes("y", jwk.Y)
 	m.marshalBytes("n", jwk.N)
@@ -70,3 +62,10 @@ func (jwk *JWK) MarshalJSON() ([]byte, error) {
 
 	return data, nil
 }
+
+// UnmarshalJSON decodes a JWK without reflection. The result is the same as
+// decoding with encoding/json, but with much fewer allocations.
+func (jwk *JWK) UnmarshalJSON(data []byte) error {
+	u := jsonUnmarshaller{data: data}
+	return u.unmarshalJWK(jwk)
+}
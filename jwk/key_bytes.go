@@ -1,10 +1,14 @@
 package jwk
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"math/big"
+	"strconv"
+	"unicode/utf8"
 )
 
 // keyBytes represents a slice of bytes that can be serialized to url-safe base64.
@@ -50,26 +54,95 @@ func (kb *keyBytes) MarshalJSON() ([]byte, error) {
 }
 
 func (kb *keyBytes) UnmarshalJSON(data []byte) error {
-	var b64urlStr string
-	err := json.Unmarshal(data, &b64urlStr)
-	if err != nil {
-		return err
+	_, err := kb.unmarshalJSONInto(data, nil)
+	return err
+}
+
+// unmarshalJSONInto is like UnmarshalJSON, but decodes into the beginning of
+// buf if it is large enough. It returns the number of bytes used from buf.
+func (kb *keyBytes) unmarshalJSONInto(data []byte, buf []byte) (int, error) {
+	// Decode directly from the JSON input: decoding into a string first would
+	// leave an immutable copy of (possibly private) key material behind.
+	if len(data) < 2 || data[0] != '"' || data[len(data)-1] != '"' {
+		var b64urlStr string
+		return 0, json.Unmarshal(data, &b64urlStr) // Produces the right error
+	}
+	encoded := data[1 : len(data)-1]
+	if bytes.IndexByte(encoded, '\\') >= 0 {
+		unescaped, err := unescapeBase64JSON(encoded)
+		if err != nil {
+			return 0, err
+		}
+		defer clear(unescaped)
+		encoded = unescaped
 	}
 
 	// Reset keyBytes to empty if string is empty
-	if b64urlStr == "" && len(kb.data) != 0 {
+	if len(encoded) == 0 && len(kb.data) != 0 {
 		kb.data = nil
-		return nil
+		return 0, nil
 	}
 
-	decoded, err := base64.RawURLEncoding.DecodeString(b64urlStr)
+	size := base64.RawURLEncoding.DecodedLen(len(encoded))
+	var decoded []byte
+	used := 0
+	if buf != nil && size <= len(buf) {
+		decoded = buf[:size:size]
+		used = size
+	} else {
+		decoded = make([]byte, size)
+	}
+	n, err := base64.RawURLEncoding.Decode(decoded, encoded)
 	if err != nil {
-		return err
+		clear(decoded)
+		return used, err
 	}
 
-	kb.data = decoded
+	kb.data = decoded[:n]
 
-	return nil
+	return used, nil
+}
+
+var errInvalidBase64Escape = errors.New("invalid escape sequence in base64 data")
+
+// unescapeBase64JSON removes JSON escape sequences from the contents of a
+// JSON string containing base64 data. Since base64 only uses ASCII
+// characters, escape sequences for non-ASCII characters are rejected.
+func unescapeBase64JSON(encoded []byte) ([]byte, error) {
+	unescaped := make([]byte, 0, len(encoded))
+	for i := 0; i < len(encoded); i++ {
+		c := encoded[i]
+		if c != '\\' {
+			unescaped = append(unescaped, c)
+			continue
+		}
+		i++
+		if i >= len(encoded) {
+			clear(unescaped)
+			return nil, errInvalidBase64Escape
+		}
+		switch encoded[i] {
+		case '"', '\\', '/':
+			unescaped = append(unescaped, encoded[i])
+		case 'u':
+			if i+4 >= len(encoded) {
+				clear(unescaped)
+				return nil, errInvalidBase64Escape
+			}
+			r, err := strconv.ParseUint(string(encoded[i+1:i+5]), 16, 16)
+			if err != nil || r >= utf8.RuneSelf {
+				clear(unescaped)
+				return nil, errInvalidBase64Escape
+			}
+			unescaped = append(unescaped, byte(r))
+			i += 4
+		default:
+			// Other escape sequences (\b, \n, ...) are never valid base64
+			clear(unescaped)
+			return nil, errInvalidBase64Escape
+		}
+	}
+	return unescaped, nil
 }
 
 func (kb *keyBytes) toBigInt() *big.Int {
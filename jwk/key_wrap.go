@@ -0,0 +1,189 @@
+package jwk
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrKeyUnwrapFailed is returned when a wrapped key fails the integrity check,
+// because it was wrapped with a different key or has been tampered with.
+var ErrKeyUnwrapFailed = errors.New("key unwrap failed")
+
+// keyWrapIV is the default initial value of RFC 3394 section 2.2.3.1
+var keyWrapIV = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// WrappedKey is a content encryption key wrapped with a key encryption key
+type WrappedKey struct {
+	// Algorithm is the key wrapping algorithm (A128KW, A192KW, A256KW,
+	// A128GCMKW, A192GCMKW or A256GCMKW)
+	Algorithm string
+
+	// EncryptedKey is the wrapped key
+	EncryptedKey []byte
+
+	// IV and Tag are the initialization vector and authentication tag of the
+	// AES-GCM key wrap algorithms ('iv' and 'tag' JWE header parameters).
+	// They are empty for AES Key Wrap.
+	IV, Tag []byte
+}
+
+// WrapKey wraps the content encryption key cek with the symmetric key in the
+// KeySpec, using AES Key Wrap (RFC 3394) or AES-GCM key wrap as specified in
+// RFC 7518 sections 4.4 and 4.7. If alg is empty, KeySpec.Algorithm is used.
+// rand is used to generate the IV of the AES-GCM algorithms.
+func (k *KeySpec) WrapKey(alg string, cek []byte, rand io.Reader) (*WrappedKey, error) {
+	kek, alg, err := k.keyEncryptionKey(alg)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(alg, "GCMKW") {
+		encryptedKey, err := AESKeyWrap(kek, cek)
+		if err != nil {
+			return nil, err
+		}
+		return &WrappedKey{Algorithm: alg, EncryptedKey: encryptedKey}, nil
+	}
+
+	gcm, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand, iv); err != nil {
+		return nil, err
+	}
+	sealed := gcm.Seal(nil, iv, cek, nil)
+	encryptedKey, tag := sealed[:len(cek)], sealed[len(cek):]
+	return &WrappedKey{Algorithm: alg, EncryptedKey: encryptedKey, IV: iv, Tag: tag}, nil
+}
+
+// UnwrapKey unwraps a key wrapped by WrapKey() and returns the content
+// encryption key. ErrKeyUnwrapFailed is returned if the integrity check fails.
+func (k *KeySpec) UnwrapKey(wrapped *WrappedKey) ([]byte, error) {
+	kek, alg, err := k.keyEncryptionKey(wrapped.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(alg, "GCMKW") {
+		return AESKeyUnwrap(kek, wrapped.EncryptedKey)
+	}
+
+	gcm, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped.IV) != gcm.NonceSize() || len(wrapped.Tag) != gcm.Overhead() {
+		return nil, fmt.Errorf("%s requires a %d-byte IV and a %d-byte tag", alg, gcm.NonceSize(), gcm.Overhead())
+	}
+	sealed := make([]byte, 0, len(wrapped.EncryptedKey)+len(wrapped.Tag))
+	sealed = append(append(sealed, wrapped.EncryptedKey...), wrapped.Tag...)
+	cek, err := gcm.Open(sealed[:0], wrapped.IV, sealed, nil)
+	if err != nil {
+		return nil, ErrKeyUnwrapFailed
+	}
+	return cek, nil
+}
+
+// keyEncryptionKey returns the symmetric key of the KeySpec and the key
+// wrapping algorithm, after checking that they match
+func (k *KeySpec) keyEncryptionKey(alg string) (OctetKey, string, error) {
+	kek, ok := k.OctetKey()
+	if !ok {
+		return nil, "", errors.New("key wrapping requires a symmetric key")
+	}
+	if alg == "" {
+		alg = k.Algorithm
+	} else if k.Algorithm != "" && k.Algorithm != alg {
+		return nil, "", fmt.Errorf("key algorithm %s cannot be used for %s", k.Algorithm, alg)
+	}
+	if k.Use != "" && k.Use != "enc" {
+		return nil, "", fmt.Errorf("key with use '%s' cannot be used for key wrapping", k.Use)
+	}
+	if !strings.HasSuffix(alg, "KW") {
+		return nil, "", fmt.Errorf("unsupported key wrapping algorithm: %s", alg)
+	}
+	size, err := OctetKeySize(alg)
+	if err != nil {
+		return nil, "", fmt.Errorf("unsupported key wrapping algorithm: %s", alg)
+	}
+	if len(kek) != size {
+		return nil, "", fmt.Errorf("%s requires a %d-bit key, got %d bits", alg, size*8, len(kek)*8)
+	}
+	return kek, alg, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// AESKeyWrap wraps key with the AES key encryption key kek, as specified by
+// RFC 3394. The key must be a multiple of 8 bytes long, and at least 16 bytes.
+func AESKeyWrap(kek, key []byte) ([]byte, error) {
+	if len(key) < 16 || len(key)%8 != 0 {
+		return nil, fmt.Errorf("cannot wrap a %d-byte key: AES Key Wrap requires a multiple of 8 bytes, at least 16", len(key))
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(key) / 8
+	wrapped := make([]byte, 8+len(key))
+	copy(wrapped, keyWrapIV)
+	copy(wrapped[8:], key)
+	var b [aes.BlockSize]byte
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(b[:8], wrapped[:8])
+			copy(b[8:], wrapped[i*8:])
+			block.Encrypt(b[:], b[:])
+			binary.BigEndian.PutUint64(wrapped[:8], binary.BigEndian.Uint64(b[:8])^uint64(n*j+i))
+			copy(wrapped[i*8:], b[8:])
+		}
+	}
+	clear(b[:])
+	return wrapped, nil
+}
+
+// AESKeyUnwrap unwraps a key wrapped by AESKeyWrap() with the AES key
+// encryption key kek. ErrKeyUnwrapFailed is returned if the integrity check
+// fails.
+func AESKeyUnwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, fmt.Errorf("invalid wrapped key length: %d bytes", len(wrapped))
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(wrapped)/8 - 1
+	key := make([]byte, len(wrapped))
+	copy(key, wrapped)
+	var b [aes.BlockSize]byte
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			binary.BigEndian.PutUint64(b[:8], binary.BigEndian.Uint64(key[:8])^uint64(n*j+i))
+			copy(b[8:], key[i*8:])
+			block.Decrypt(b[:], b[:])
+			copy(key[:8], b[:8])
+			copy(key[i*8:], b[8:])
+		}
+	}
+	clear(b[:])
+	if subtle.ConstantTimeCompare(key[:8], keyWrapIV) != 1 {
+		clear(key)
+		return nil, ErrKeyUnwrapFailed
+	}
+	return key[8:], nil
+}
@@ -0,0 +1,106 @@
+package jwk
+
+import (
+	"crypto/rand"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rakutentech/jwk-go/internal/testutils"
+)
+
+var _ = Describe("AES Key Wrap", func() {
+	kek := mustDecodeHex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	key := mustDecodeHex("00112233445566778899aabbccddeeff000102030405060708090a0b0c0d0e0f")
+
+	DescribeTable("Should pass the RFC 3394 test vectors",
+		func(kekSize, keySize int, expected string) {
+			wrapped := testutils.Must(AESKeyWrap(kek[:kekSize], key[:keySize]))
+			Expect(wrapped).To(Equal(mustDecodeHex(expected)))
+			Expect(AESKeyUnwrap(kek[:kekSize], wrapped)).To(Equal(key[:keySize]))
+		},
+		Entry("4.1 128-bit KEK, 128-bit key", 16, 16, "1fa68b0a8112b447aef34bd8fb5a7b829d3e862371d2cfe5"),
+		Entry("4.2 192-bit KEK, 128-bit key", 24, 16, "96778b25ae6ca435f92b5b97c050aed2468ab8a17ad84e5d"),
+		Entry("4.3 256-bit KEK, 128-bit key", 32, 16, "64e8c3f9ce0f5ba263e9777905818a2a93c8191e7d6e8ae7"),
+		Entry("4.4 192-bit KEK, 192-bit key", 24, 24,
+			"031d33264e15d33268f24ec260743edce1c6c7ddee725a936ba814915c6762d2"),
+		Entry("4.5 256-bit KEK, 192-bit key", 32, 24,
+			"a8f9bc1612c68b3ff6e6f4fbe30e71e4769c8b80a32cb8958cd5d17d6b254da1"),
+		Entry("4.6 256-bit KEK, 256-bit key", 32, 32,
+			"28c9f404c4b810f4cbccb35cfb87f8263f5786e2d80ed326cbc7f0e71a99f43bfb988b9b7a02dd21"),
+	)
+
+	It("Should detect tampering", func() {
+		wrapped := testutils.Must(AESKeyWrap(kek[:16], key))
+		wrapped[len(wrapped)-1] ^= 1
+		_, err := AESKeyUnwrap(kek[:16], wrapped)
+		Expect(err).To(Equal(ErrKeyUnwrapFailed))
+		_, err = AESKeyUnwrap(kek[16:], testutils.Must(AESKeyWrap(kek[:16], key)))
+		Expect(err).To(Equal(ErrKeyUnwrapFailed))
+	})
+
+	It("Should reject invalid lengths", func() {
+		_, err := AESKeyWrap(kek[:16], key[:8])
+		Expect(err).To(MatchError("cannot wrap a 8-byte key: AES Key Wrap requires a multiple of 8 bytes, at least 16"))
+		_, err = AESKeyWrap(kek[:16], key[:20])
+		Expect(err).To(HaveOccurred())
+		_, err = AESKeyUnwrap(kek[:16], key[:16])
+		Expect(err).To(MatchError("invalid wrapped key length: 16 bytes"))
+		_, err = AESKeyWrap(kek[:10], key)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("WrapKey", func() {
+	cek := testutils.Must(GenerateOctetKey("A256GCM", rand.Reader))
+
+	DescribeTable("Should wrap and unwrap keys",
+		func(alg string) {
+			kek := testutils.Must(GenerateOctetKeySpec(alg, rand.Reader))
+			wrapped := testutils.Must(kek.WrapKey("", cek, rand.Reader))
+			Expect(wrapped.Algorithm).To(Equal(alg))
+			Expect(wrapped.EncryptedKey).NotTo(ContainSubstring(string(cek)))
+			Expect(kek.UnwrapKey(wrapped)).To(Equal([]byte(cek)))
+
+			other := testutils.Must(GenerateOctetKeySpec(alg, rand.Reader))
+			_, err := other.UnwrapKey(wrapped)
+			Expect(errors.Is(err, ErrKeyUnwrapFailed)).To(BeTrue())
+		},
+		Entry("A128KW", "A128KW"),
+		Entry("A192KW", "A192KW"),
+		Entry("A256KW", "A256KW"),
+		Entry("A128GCMKW", "A128GCMKW"),
+		Entry("A192GCMKW", "A192GCMKW"),
+		Entry("A256GCMKW", "A256GCMKW"),
+	)
+
+	It("Should set the IV and tag for AES-GCM", func() {
+		kek := NewSpec(randomBytes(32))
+		wrapped := testutils.Must(kek.WrapKey("A256GCMKW", cek, rand.Reader))
+		Expect(wrapped.EncryptedKey).To(HaveLen(len(cek)))
+		Expect(wrapped.IV).To(HaveLen(12))
+		Expect(wrapped.Tag).To(HaveLen(16))
+
+		wrapped.Tag[0] ^= 1
+		_, err := kek.UnwrapKey(wrapped)
+		Expect(err).To(Equal(ErrKeyUnwrapFailed))
+		_, err = kek.UnwrapKey(&WrappedKey{Algorithm: "A256GCMKW", EncryptedKey: wrapped.EncryptedKey})
+		Expect(err).To(MatchError("A256GCMKW requires a 12-byte IV and a 16-byte tag"))
+	})
+
+	It("Should reject keys which do not match the algorithm", func() {
+		_, err := NewSpec(randomBytes(16)).WrapKey("A256KW", cek, rand.Reader)
+		Expect(err).To(MatchError("A256KW requires a 256-bit key, got 128 bits"))
+		_, err = NewSpec(randomBytes(16)).WrapKey("", cek, rand.Reader)
+		Expect(err).To(MatchError("unsupported key wrapping algorithm: "))
+		_, err = NewSpec(randomBytes(32)).WrapKey("A256GCM", cek, rand.Reader)
+		Expect(err).To(MatchError("unsupported key wrapping algorithm: A256GCM"))
+		_, err = (&KeySpec{Key: randomBytes(16), Algorithm: "A128KW"}).WrapKey("A128GCMKW", cek, rand.Reader)
+		Expect(err).To(MatchError("key algorithm A128KW cannot be used for A128GCMKW"))
+		_, err = (&KeySpec{Key: randomBytes(32), Use: "sig"}).WrapKey("A256KW", cek, rand.Reader)
+		Expect(err).To(MatchError("key with use 'sig' cannot be used for key wrapping"))
+		_, err = NewSpec(testutils.Must(GenerateOctetKey("A128GCM", rand.Reader))).UnwrapKey(&WrappedKey{Algorithm: "RSA-OAEP"})
+		Expect(err).To(MatchError("unsupported key wrapping algorithm: RSA-OAEP"))
+	})
+})
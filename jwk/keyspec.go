@@ -18,6 +18,12 @@ type KeySpec struct {
 	KeyID     string
 	Algorithm string
 	Use       string
+
+	// NotBefore is the time before which the key must not be used ('nbf').
+	NotBefore time.Time
+	// IssuedAt is the time at which the key was created ('iat').
+	IssuedAt time.Time
+	// ExpiresAt is the time at which the key expires ('exp').
 	ExpiresAt time.Time
 }
 
@@ -30,7 +36,7 @@ type KeySpecSet struct {
 //
 // Key object types supported:
 // rsa.PrivateKey, rsa.PublicKey, ecdsa.PrivateKey, ecdsa.PublicKey,
-// okp.OctetKeyPair, []byte
+// okp.OctetKeyPair, OctetKey, []byte
 func NewSpec(key interface{}) *KeySpec {
 	return &KeySpec{Key: key}
 }
@@ -39,7 +45,7 @@ func NewSpec(key interface{}) *KeySpec {
 //
 // Key object types supported:
 // rsa.PrivateKey, rsa.PublicKey, ecdsa.PrivateKey, ecdsa.PublicKey,
-// okp.OctetKeyPair, []byte
+// okp.OctetKeyPair, OctetKey, []byte
 func NewSpecWithID(kid string, key interface{}) *KeySpec {
 	return &KeySpec{Key: key, KeyID: kid}
 }
@@ -109,17 +115,36 @@ func (k *KeySpec) PublicOnly() (*KeySpec, error) {
 	default:
 		return nil, errors.New("key type does not support extracting the public key")
 	}
-	return &KeySpec{
-		Key:       pubKey,
-		Algorithm: k.Algorithm,
-		KeyID:     k.KeyID,
-		Use:       k.Use,
-	}, nil
+	publicKey := k.Clone()
+	publicKey.Key = pubKey
+	return publicKey, nil
 }
 
-// IsValid returns true if the key is valid, i.e. it is not expired yet or has no expiry set
+// IsValid returns true if the key is valid at the current time.
+//
+// See KeySpec.IsValidAt for more information.
 func (k *KeySpec) IsValid() bool {
-	return k.ExpiresAt.IsZero() || k.ExpiresAt.Before(time.Now())
+	return k.IsValidAt(time.Now())
+}
+
+// IsValidAt returns true if the key is valid at time t, i.e. t is inside the
+// validity window defined by NotBefore (inclusive) and ExpiresAt (exclusive).
+// A zero NotBefore or ExpiresAt leaves the respective side of the window open.
+//
+// IssuedAt is informational only and does not affect validity.
+func (k *KeySpec) IsValidAt(t time.Time) bool {
+	return !k.IsNotYetValidAt(t) && !k.IsExpiredAt(t)
+}
+
+// IsExpiredAt returns true if the key has an expiry set and it has expired at time t.
+func (k *KeySpec) IsExpiredAt(t time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !t.Before(k.ExpiresAt)
+}
+
+// IsNotYetValidAt returns true if the key has a not-before time set and time t
+// is before it.
+func (k *KeySpec) IsNotYetValidAt(t time.Time) bool {
+	return !k.NotBefore.IsZero() && t.Before(k.NotBefore)
 }
 
 // Clone creates a copy of a KeySpec
@@ -129,5 +154,8 @@ func (k *KeySpec) Clone() *KeySpec {
 		Algorithm: k.Algorithm,
 		KeyID:     k.KeyID,
 		Use:       k.Use,
+		NotBefore: k.NotBefore,
+		IssuedAt:  k.IssuedAt,
+		ExpiresAt: k.ExpiresAt,
 	}
 }
@@ -0,0 +1,109 @@
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rakutentech/jwk-go/internal/testutils"
+	"github.com/rakutentech/jwk-go/okp"
+)
+
+var _ = Describe("Equal", func() {
+	rsaKey := testutils.Must(rsa.GenerateKey(rand.Reader, 2048))
+	ecKey := testutils.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader))
+	edKey := testutils.Must(okp.GenerateEd25519(rand.Reader))
+	symmetricKey := randomBytes(32)
+
+	It("Should compare key material", func() {
+		for _, key := range []interface{}{rsaKey, ecKey, edKey, symmetricKey} {
+			k := NewSpecWithID("kid", key)
+			// Round-trip the key, so it is a different object
+			parsed := MustParseBytes(testutils.Must(k.MarshalJSON()))
+			Expect(k.Equal(parsed)).To(BeTrue())
+			Expect(k.SamePublicKey(parsed)).To(BeTrue())
+		}
+
+		Expect(NewSpec(symmetricKey).Equal(NewSpec(OctetKey(symmetricKey)))).To(BeTrue())
+		Expect(NewSpec(symmetricKey).Equal(NewSpec(randomBytes(32)))).To(BeFalse())
+		Expect(NewSpec(rsaKey).Equal(NewSpec(ecKey))).To(BeFalse())
+		Expect(NewSpec(ecKey).Equal(NewSpec(
+			testutils.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader))))).To(BeFalse())
+	})
+
+	It("Should distinguish private keys from their public keys", func() {
+		for _, key := range []interface{}{rsaKey, ecKey, edKey} {
+			private := NewSpecWithID("kid", key)
+			public := testutils.Must(private.PublicOnly())
+			Expect(private.Equal(public)).To(BeFalse())
+			Expect(public.Equal(private)).To(BeFalse())
+			Expect(private.SamePublicKey(public)).To(BeTrue())
+			Expect(public.SamePublicKey(private)).To(BeTrue())
+			Expect(public.Equal(public.Clone())).To(BeTrue())
+		}
+	})
+
+	It("Should compare metadata", func() {
+		k := &KeySpec{Key: ecKey, KeyID: "kid", Use: "sig", ExpiresAt: time.Unix(1700000000, 0)}
+		other := k.Clone()
+		other.ExpiresAt = time.Unix(1700000000, 0).UTC()
+		Expect(k.Equal(other)).To(BeTrue())
+
+		other.Algorithm = "ES256"
+		Expect(k.Equal(other)).To(BeFalse())
+		Expect(k.SamePublicKey(other)).To(BeTrue())
+	})
+})
+
+var _ = Describe("Diff", func() {
+	newKey := func(kid string) KeySpec {
+		return KeySpec{Key: testutils.Must(okp.GenerateEd25519(rand.Reader)), KeyID: kid}
+	}
+
+	It("Should report added, removed and changed keys", func() {
+		kept, removed, rotated, extended := newKey("kept"), newKey("removed"), newKey("rotated"), newKey("extended")
+		anonymous := newKey("")
+		old := KeySpecSet{Keys: []KeySpec{kept, removed, rotated, extended, anonymous}}
+
+		newRotated := newKey("rotated")
+		newExtended := *extended.Clone()
+		newExtended.ExpiresAt = time.Now().Add(time.Hour)
+		added := newKey("added")
+		current := KeySpecSet{Keys: []KeySpec{anonymous, added, newExtended, kept, newRotated}}
+
+		diff := old.Diff(current)
+		Expect(diff.IsEmpty()).To(BeFalse())
+		Expect(diff.Added).To(HaveLen(1))
+		Expect(diff.Added[0].KeyID).To(Equal("added"))
+		Expect(diff.Removed).To(HaveLen(1))
+		Expect(diff.Removed[0].KeyID).To(Equal("removed"))
+		Expect(diff.Changed).To(HaveLen(2))
+		Expect(diff.Changed[0].Old.KeyID).To(Equal("rotated"))
+		Expect(diff.Changed[0].New.Equal(&newRotated)).To(BeTrue())
+		Expect(diff.Changed[1].New.ExpiresAt).To(Equal(newExtended.ExpiresAt))
+	})
+
+	It("Should match keys without Key ID by thumbprint", func() {
+		a, b := newKey(""), newKey("")
+		diff := KeySpecSet{Keys: []KeySpec{a}}.Diff(KeySpecSet{Keys: []KeySpec{b}})
+		Expect(diff.Added).To(HaveLen(1))
+		Expect(diff.Removed).To(HaveLen(1))
+		Expect(diff.Changed).To(BeEmpty())
+
+		withAlg := *a.Clone()
+		withAlg.Algorithm = "EdDSA"
+		diff = KeySpecSet{Keys: []KeySpec{a}}.Diff(KeySpecSet{Keys: []KeySpec{withAlg}})
+		Expect(diff.Changed).To(HaveLen(1))
+	})
+
+	It("Should report no differences for identical sets", func() {
+		set := KeySpecSet{Keys: []KeySpec{newKey("a"), newKey("b")}}
+		Expect(set.Diff(set).IsEmpty()).To(BeTrue())
+		Expect(KeySpecSet{}.Diff(KeySpecSet{}).IsEmpty()).To(BeTrue())
+	})
+})
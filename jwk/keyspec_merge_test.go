@@ -0,0 +1,157 @@
+package jwk
+
+import (
+	"crypto/rand"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rakutentech/jwk-go/internal/testutils"
+	"github.com/rakutentech/jwk-go/okp"
+)
+
+func newTestKey(kid string) KeySpec {
+	return KeySpec{Key: testutils.Must(okp.GenerateEd25519(rand.Reader)), KeyID: kid}
+}
+
+func keyIDs(ks KeySpecSet) []string {
+	var ids []string
+	for _, k := range ks.Keys {
+		ids = append(ids, k.KeyID)
+	}
+	return ids
+}
+
+var _ = Describe("Merge", func() {
+	a, b, c := newTestKey("a"), newTestKey("b"), newTestKey("c")
+	otherA := newTestKey("a")
+
+	It("Should merge sets and drop identical keys", func() {
+		merged, err := KeySpecSet{Keys: []KeySpec{a, b}}.Merge(KeySpecSet{Keys: []KeySpec{b, c, a}}, MergeSettings{})
+		Expect(err).To(Succeed())
+		Expect(keyIDs(merged)).To(Equal([]string{"a", "b", "c"}))
+
+		anonymous := newTestKey("")
+		merged, err = KeySpecSet{Keys: []KeySpec{anonymous}}.Merge(KeySpecSet{Keys: []KeySpec{anonymous}}, MergeSettings{})
+		Expect(err).To(Succeed())
+		Expect(merged.Keys).To(HaveLen(1))
+	})
+
+	It("Should resolve conflicts", func() {
+		old := KeySpecSet{Keys: []KeySpec{a, b}}
+		current := KeySpecSet{Keys: []KeySpec{otherA}}
+
+		_, err := old.Merge(current, MergeSettings{})
+		Expect(errors.Is(err, ErrKeyConflict)).To(BeTrue())
+
+		merged, err := old.Merge(current, MergeSettings{OnConflict: MergeKeepExisting})
+		Expect(err).To(Succeed())
+		Expect(merged.Keys[0].Equal(&a)).To(BeTrue())
+
+		merged, err = old.Merge(current, MergeSettings{OnConflict: MergeReplace})
+		Expect(err).To(Succeed())
+		Expect(keyIDs(merged)).To(Equal([]string{"a", "b"}))
+		Expect(merged.Keys[0].Equal(&otherA)).To(BeTrue())
+
+		merged, err = old.Merge(current, MergeSettings{
+			OnConflict: MergeFail,
+			Resolve: func(existing, incoming *KeySpec) (*KeySpec, error) {
+				resolved := incoming.Clone()
+				resolved.KeyID = "resolved"
+				return resolved, nil
+			},
+		})
+		Expect(err).To(Succeed())
+		Expect(keyIDs(merged)).To(Equal([]string{"resolved", "b"}))
+	})
+
+	It("Should keep the existing key when Resolve returns nil", func() {
+		merged, err := KeySpecSet{Keys: []KeySpec{a, b}}.Merge(KeySpecSet{Keys: []KeySpec{otherA}}, MergeSettings{
+			Resolve: func(existing, incoming *KeySpec) (*KeySpec, error) { return nil, nil },
+		})
+		Expect(err).To(Succeed())
+		Expect(keyIDs(merged)).To(Equal([]string{"a", "b"}))
+		Expect(merged.Keys[0].Equal(&a)).To(BeTrue())
+	})
+
+	It("Should treat metadata changes as conflicts", func() {
+		extended := *a.Clone()
+		extended.ExpiresAt = time.Now().Add(time.Hour)
+		_, err := KeySpecSet{Keys: []KeySpec{a}}.Merge(KeySpecSet{Keys: []KeySpec{extended}}, MergeSettings{})
+		Expect(errors.Is(err, ErrKeyConflict)).To(BeTrue())
+	})
+})
+
+var _ = Describe("Dedupe", func() {
+	It("Should keep the first occurrence of each key", func() {
+		a, b := newTestKey("a"), newTestKey("b")
+		renamed := *a.Clone()
+		renamed.KeyID = "renamed"
+		public := *testutils.Must(b.PublicOnly())
+		deduped := KeySpecSet{Keys: []KeySpec{public, a, renamed, b, {Key: "unsupported"}}}.Dedupe()
+		Expect(deduped.Keys).To(HaveLen(3))
+		Expect(deduped.Keys[0].IsPublic()).To(BeTrue())
+		Expect(deduped.Keys[1].KeyID).To(Equal("a"))
+	})
+})
+
+var _ = Describe("Sort", func() {
+	now := time.Now()
+	newTimedKey := func(kid string, iat, exp time.Time) KeySpec {
+		k := newTestKey(kid)
+		k.IssuedAt, k.ExpiresAt = iat, exp
+		return k
+	}
+	ks := KeySpecSet{Keys: []KeySpec{
+		newTimedKey("b", now, time.Time{}),
+		newTimedKey("c", now.Add(-time.Hour), now.Add(time.Hour)),
+		newTimedKey("a", time.Time{}, now.Add(2*time.Hour)),
+		newTimedKey("d", now, now.Add(time.Hour)),
+	}}
+
+	It("Should sort by Key ID", func() {
+		Expect(keyIDs(ks.Sort(SortByKeyID))).To(Equal([]string{"a", "b", "c", "d"}))
+	})
+
+	It("Should sort by expiry", func() {
+		Expect(keyIDs(ks.Sort(SortByExpiry))).To(Equal([]string{"c", "d", "a", "b"}))
+	})
+
+	It("Should sort by creation time", func() {
+		Expect(keyIDs(ks.Sort(SortByIssuedAt))).To(Equal([]string{"a", "c", "b", "d"}))
+	})
+
+	It("Should not modify the original set", func() {
+		ks.Sort(SortByKeyID)
+		Expect(keyIDs(ks)).To(Equal([]string{"b", "c", "a", "d"}))
+	})
+
+	It("Should sort keys with the same Key ID deterministically", func() {
+		x, y := newTestKey("x"), newTestKey("x")
+		Expect(KeySpecSet{Keys: []KeySpec{x, y}}.Sort(SortByKeyID)).
+			To(Equal(KeySpecSet{Keys: []KeySpec{y, x}}.Sort(SortByKeyID)))
+	})
+})
+
+var _ = Describe("Validate", func() {
+	It("Should accept consistent sets", func() {
+		Expect(KeySpecSet{Keys: []KeySpec{newTestKey("a"), newTestKey("b"), newTestKey("")}}.Validate()).To(Succeed())
+		Expect(KeySpecSet{}.Validate()).To(Succeed())
+	})
+
+	It("Should report duplicate Key IDs and keys", func() {
+		a := newTestKey("a")
+		public := *testutils.Must(a.PublicOnly())
+		public.KeyID = "public"
+
+		err := KeySpecSet{Keys: []KeySpec{a, newTestKey("a")}}.Validate()
+		Expect(errors.Is(err, ErrDuplicateKeyID)).To(BeTrue())
+		Expect(errors.Is(err, ErrDuplicateKey)).To(BeFalse())
+
+		err = KeySpecSet{Keys: []KeySpec{a, public}}.Validate()
+		Expect(errors.Is(err, ErrDuplicateKeyID)).To(BeFalse())
+		Expect(errors.Is(err, ErrDuplicateKey)).To(BeTrue())
+	})
+})
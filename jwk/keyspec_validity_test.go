@@ -0,0 +1,109 @@
+package jwk
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Key validity", func() {
+	now := time.Unix(1700000000, 0)
+	hour := time.Hour
+
+	It("Should treat keys without a validity window as valid", func() {
+		k := KeySpec{Key: X25519Example}
+		Expect(k.IsValidAt(now)).To(BeTrue())
+		Expect(k.IsValid()).To(BeTrue())
+	})
+
+	It("Should not treat expired keys as valid", func() {
+		k := KeySpec{Key: X25519Example, ExpiresAt: time.Now().Add(-hour)}
+		Expect(k.IsValid()).To(BeFalse())
+		k.ExpiresAt = time.Now().Add(hour)
+		Expect(k.IsValid()).To(BeTrue())
+	})
+
+	It("Should respect the validity window boundaries", func() {
+		k := KeySpec{
+			Key:       X25519Example,
+			NotBefore: now,
+			ExpiresAt: now.Add(hour),
+		}
+		Expect(k.IsValidAt(now.Add(-time.Second))).To(BeFalse())
+		Expect(k.IsNotYetValidAt(now.Add(-time.Second))).To(BeTrue())
+		Expect(k.IsValidAt(now)).To(BeTrue())
+		Expect(k.IsValidAt(now.Add(hour - time.Second))).To(BeTrue())
+		Expect(k.IsValidAt(now.Add(hour))).To(BeFalse())
+		Expect(k.IsExpiredAt(now.Add(hour))).To(BeTrue())
+	})
+
+	It("Should not use IssuedAt for validity", func() {
+		k := KeySpec{Key: X25519Example, IssuedAt: now.Add(hour)}
+		Expect(k.IsValidAt(now)).To(BeTrue())
+	})
+
+	It("Should round-trip nbf, iat and exp", func() {
+		k := KeySpec{
+			Key:       X25519Example,
+			KeyID:     "foo",
+			NotBefore: now,
+			IssuedAt:  now.Add(-hour),
+			ExpiresAt: now.Add(hour),
+		}
+		b, err := json.Marshal(&k)
+		Expect(err).To(Succeed())
+		m := make(map[string]interface{})
+		Expect(json.Unmarshal(b, &m)).To(Succeed())
+		Expect(m).To(HaveKeyWithValue("nbf", float64(now.Unix())))
+		Expect(m).To(HaveKeyWithValue("iat", float64(now.Add(-hour).Unix())))
+		Expect(m).To(HaveKeyWithValue("exp", float64(now.Add(hour).Unix())))
+
+		var k2 KeySpec
+		Expect(json.Unmarshal(b, &k2)).To(Succeed())
+		Expect(k2).To(Equal(k))
+
+		var j JWK
+		Expect(json.Unmarshal(b, &j)).To(Succeed())
+		k3, err := j.ParseKeySpec()
+		Expect(err).To(Succeed())
+		Expect(*k3).To(Equal(k))
+	})
+
+	It("Should keep the validity window on Clone and PublicOnly", func() {
+		k := KeySpec{
+			Key:       Ed25519Example,
+			NotBefore: now,
+			IssuedAt:  now,
+			ExpiresAt: now.Add(hour),
+		}
+		Expect(*k.Clone()).To(Equal(k))
+		pub, err := k.PublicOnly()
+		Expect(err).To(Succeed())
+		Expect(pub.NotBefore).To(Equal(k.NotBefore))
+		Expect(pub.IssuedAt).To(Equal(k.IssuedAt))
+		Expect(pub.ExpiresAt).To(Equal(k.ExpiresAt))
+	})
+
+	It("Should filter and prune key sets", func() {
+		ks := KeySpecSet{Keys: []KeySpec{
+			{Key: X25519Example, KeyID: "expired", ExpiresAt: now.Add(-hour)},
+			{Key: X25519Example, KeyID: "current", ExpiresAt: now.Add(hour)},
+			{Key: X25519Example, KeyID: "upcoming", NotBefore: now.Add(hour)},
+			{Key: X25519Example, KeyID: "forever"},
+		}}
+
+		var kids []string
+		for k := range ks.ValidAt(now).All() {
+			kids = append(kids, k.KeyID)
+		}
+		Expect(kids).To(Equal([]string{"current", "forever"}))
+
+		kids = nil
+		for k := range ks.PruneExpired(now).All() {
+			kids = append(kids, k.KeyID)
+		}
+		Expect(kids).To(Equal([]string{"current", "upcoming", "forever"}))
+	})
+})
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/rakutentech/jwk-go/internal/numericdate"
 	"github.com/rakutentech/jwk-go/jwktypes"
 	"github.com/rakutentech/jwk-go/okp"
 )
@@ -39,12 +40,19 @@ func (ks *KeySpecSet) MarshalPublicJSON() ([]byte, error) {
 }
 
 // MarshalJSON serializes the KeySpec to JSON.
+//
+// When marshaling private keys, prefer calling MarshalJSON directly over
+// json.Marshal: encoding/json copies the output into internal buffers which
+// are reused without being wiped.
 func (k *KeySpec) MarshalJSON() ([]byte, error) {
 	jwk, err := k.ToJWK()
 	if err != nil {
 		return nil, err
 	}
-	return json.Marshal(jwk)
+	// Private fields which were copied from the key are not needed after
+	// marshaling
+	defer jwk.destroyPrivateCopies(k.Key)
+	return jwk.MarshalJSON()
 }
 
 // ToJWK converts a KeySpec into a JWK struct.
@@ -56,11 +64,9 @@ func (k *KeySpec) ToJWK() (*JWK, error) {
 	jwk.Kid = k.KeyID
 	jwk.Alg = k.Algorithm
 	jwk.Use = k.Use
-	jwk.Exp = k.ExpiresAt.Unix()
-	// Do not set invalid negative expiration times (especially for the zero time value)
-	if jwk.Exp < 0 {
-		jwk.Exp = 0
-	}
+	jwk.Exp = numericdate.FromTime(k.ExpiresAt)
+	jwk.Nbf = numericdate.FromTime(k.NotBefore)
+	jwk.Iat = numericdate.FromTime(k.IssuedAt)
 
 	return jwk, nil
 }
@@ -72,6 +78,11 @@ func convertToJWK(keyInterface interface{}) (*JWK, error) {
 			Kty: "oct",
 			K:   keyBytesFrom(key),
 		}, nil
+	case OctetKey:
+		return &JWK{
+			Kty: "oct",
+			K:   keyBytesFrom(key),
+		}, nil
 	case *rsa.PublicKey:
 		return fromRSAPublic(key)
 	case *rsa.PrivateKey:
@@ -182,6 +193,7 @@ func fromECPrivate(private *ecdsa.PrivateKey) (*JWK, error) {
 	}
 
 	d := private.D.Bytes()
+	defer clear(d) // Only the padded copy is kept
 
 	if len(d) > byteSize {
 		return nil, fmt.Errorf("invalid field d byte size for curve %s", params.Name)
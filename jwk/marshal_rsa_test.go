@@ -135,6 +135,10 @@ func verifyRSAKeySpecWith(k *KeySpec, publicOnly bool) {
 	Expect(k.Use).To(Equal("sig"))
 	Expect(k.KeyID).To(Equal("my-rsa"))
 	Expect(k.IsPublic()).To(Equal(publicOnly))
+	kty, size, private := k.KeyType()
+	Expect(kty).To(Equal("RSA"))
+	Expect(size).To(Equal("2048"))
+	Expect(private).To(Equal(!publicOnly))
 	pub, priv, ok := rsaPubPriv(k.Key)
 	Expect(ok).To(BeTrue())
 	Expect(pub.N.String()).To(Equal(rsaN))
@@ -0,0 +1,113 @@
+package jwk
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// MarshalSettings contains settings for KeySpec.MarshalJSONWithSettings() and
+// KeySpecSet.MarshalJSONWithSettings()
+type MarshalSettings struct {
+	// PublicOnly serializes only the public fields of the keys, like
+	// MarshalPublicJSON()
+	PublicOnly bool
+
+	// Canonical serializes the keys with the JSON Canonicalization Scheme
+	// (RFC 8785): members are sorted by name, and strings and numbers have a
+	// unique representation. The output can be signed or hashed reproducibly.
+	Canonical bool
+}
+
+// MarshalJSONWithSettings serializes the KeySpec to JSON as specified by
+// settings. Without settings, the result is the same as MarshalJSON().
+func (k *KeySpec) MarshalJSONWithSettings(settings MarshalSettings) ([]byte, error) {
+	return k.appendJSONWithSettings(nil, settings)
+}
+
+// MarshalJSONWithSettings serializes the KeySpecSet to JSON (as JWKS) as
+// specified by settings.
+func (ks KeySpecSet) MarshalJSONWithSettings(settings MarshalSettings) ([]byte, error) {
+	if !settings.Canonical {
+		return ks.appendJSON(nil, settings.PublicOnly)
+	}
+	size, err := ks.maxJSONLen(settings.PublicOnly)
+	if err != nil {
+		return nil, err
+	}
+	dst := append(make([]byte, 0, size), `{"keys":[`...)
+	for i := range ks.Keys {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst, err = ks.Keys[i].appendJSONWithSettings(dst, settings)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return append(dst, "]}"...), nil
+}
+
+func (k *KeySpec) appendJSONWithSettings(dst []byte, settings MarshalSettings) ([]byte, error) {
+	if !settings.Canonical {
+		return k.appendJSON(dst, settings.PublicOnly)
+	}
+	if settings.PublicOnly {
+		publicKey, err := k.PublicOnly()
+		if err != nil {
+			return nil, err
+		}
+		k = publicKey
+	}
+	jwk, err := k.ToJWK()
+	if err != nil {
+		return nil, err
+	}
+	// Private fields which were copied from the key are not needed after
+	// marshaling
+	defer jwk.destroyPrivateCopies(k.Key)
+	return jwk.appendCanonicalJSON(dst)
+}
+
+// appendCanonicalJSON appends the JWK serialized with the JSON
+// Canonicalization Scheme (RFC 8785) to dst.
+func (jwk *JWK) appendCanonicalJSON(dst []byte) ([]byte, error) {
+	for _, member := range []struct{ name, value string }{
+		{"kid", jwk.Kid}, {"kty", jwk.Kty}, {"use", jwk.Use}, {"alg", jwk.Alg}, {"crv", jwk.Crv},
+	} {
+		// See RFC 8785 section 3.2.2.2
+		if !utf8.ValidString(member.value) {
+			return nil, fmt.Errorf("cannot canonicalize member %q: invalid UTF-8", member.name)
+		}
+	}
+
+	m := orderedJsonMarshaller{buffer: append(dst, '{'), canonical: true}
+
+	// Make room for all the members at once, so private key material is never
+	// left behind in discarded buffers when the buffer grows.
+	m.grow(bytesFieldsLen(jwk.X, jwk.Y, jwk.N, jwk.E, jwk.D, jwk.P, jwk.Q, jwk.K, jwk.Dp, jwk.Dq, jwk.Qi) +
+		stringFieldLen(jwk.Kid) + stringFieldLen(jwk.Kty) + stringFieldLen(jwk.Use) +
+		stringFieldLen(jwk.Alg) + stringFieldLen(jwk.Crv) + 3*intFieldLen)
+
+	// Members are sorted by their names, see RFC 8785 section 3.2.3
+	m.marshalString("alg", jwk.Alg)
+	m.marshalString("crv", jwk.Crv)
+	m.marshalBytes("d", jwk.D)
+	m.marshalBytes("dp", jwk.Dp)
+	m.marshalBytes("dq", jwk.Dq)
+	m.marshalBytes("e", jwk.E)
+	m.marshalInt("exp", jwk.Exp)
+	m.marshalInt("iat", jwk.Iat)
+	m.marshalBytes("k", jwk.K)
+	m.marshalString("kid", jwk.Kid)
+	m.marshalString("kty", jwk.Kty)
+	m.marshalBytes("n", jwk.N)
+	m.marshalInt("nbf", jwk.Nbf)
+	m.marshalBytes("p", jwk.P)
+	m.marshalBytes("q", jwk.Q)
+	m.marshalBytes("qi", jwk.Qi)
+	m.marshalString("use", jwk.Use)
+	m.marshalBytes("x", jwk.X)
+	m.marshalBytes("y", jwk.Y)
+
+	return m.finalize(), nil
+}
@@ -0,0 +1,88 @@
+package jwk
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rakutentech/jwk-go/internal/testutils"
+	"github.com/rakutentech/jwk-go/okp"
+)
+
+// sortMembers re-encodes a JSON object with its members sorted by name
+func sortMembers(data []byte) string {
+	var members map[string]json.RawMessage
+	Expect(json.Unmarshal(data, &members)).To(Succeed())
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	Expect(enc.Encode(members)).To(Succeed())
+	return string(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
+
+var _ = Describe("MarshalJSONWithSettings", func() {
+	now := time.Unix(1700000000, 0)
+	keys := []KeySpec{
+		{Key: testutils.Must(rsa.GenerateKey(rand.Reader, 2048)), KeyID: "rsa", Use: "sig", ExpiresAt: now},
+		{Key: testutils.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader)), Algorithm: "ES256", IssuedAt: now},
+		{Key: testutils.Must(okp.GenerateEd25519(rand.Reader)), KeyID: "ed25519", NotBefore: now},
+		{Key: randomBytes(32), KeyID: "oct", Algorithm: "HS256"},
+	}
+
+	It("Should give the same output as MarshalJSON without canonicalization", func() {
+		for _, k := range keys {
+			Expect(testutils.Must(k.MarshalJSONWithSettings(MarshalSettings{}))).To(Equal(testutils.Must(k.MarshalJSON())))
+		}
+		ks := KeySpecSet{Keys: keys[:3]}
+		Expect(testutils.Must(ks.MarshalJSONWithSettings(MarshalSettings{PublicOnly: true}))).
+			To(Equal(testutils.Must(ks.MarshalPublicJSON())))
+	})
+
+	It("Should sort the members", func() {
+		for _, k := range keys {
+			canonical := testutils.Must(k.MarshalJSONWithSettings(MarshalSettings{Canonical: true}))
+			Expect(string(canonical)).To(Equal(sortMembers(testutils.Must(k.MarshalJSON()))))
+			Expect(MustParseBytes(canonical).Equal(&k)).To(BeTrue())
+		}
+
+		k := keys[0]
+		canonical := testutils.Must(k.MarshalJSONWithSettings(MarshalSettings{Canonical: true, PublicOnly: true}))
+		Expect(string(canonical)).To(Equal(sortMembers(testutils.Must(k.MarshalPublicJSON()))))
+		Expect(string(canonical)).To(HavePrefix(`{"e":"AQAB","exp":1700000000,"kid":"rsa","kty":"RSA","n":"`))
+	})
+
+	It("Should serialize strings and numbers canonically", func() {
+		k := KeySpec{
+			Key:       OctetKey{1, 2, 3},
+			KeyID:     "<a&b>/ é\x01\x1f\"\\\n",
+			Algorithm: "HS256",
+			ExpiresAt: time.Unix(1<<53+1, 0),
+		}
+		Expect(string(testutils.Must(k.MarshalJSONWithSettings(MarshalSettings{Canonical: true})))).To(Equal(
+			`{"alg":"HS256","exp":9007199254740992,"k":"AQID","kid":"<a&b>/` + " é" + `\u0001\u001f\"\\\n","kty":"oct"}`))
+
+		k.KeyID = "\xff"
+		_, err := k.MarshalJSONWithSettings(MarshalSettings{Canonical: true})
+		Expect(err).To(MatchError(`cannot canonicalize member "kid": invalid UTF-8`))
+	})
+
+	It("Should serialize key sets canonically", func() {
+		ks := KeySpecSet{Keys: keys[1:3]}
+		canonical := testutils.Must(ks.MarshalJSONWithSettings(MarshalSettings{Canonical: true, PublicOnly: true}))
+		Expect(string(canonical)).To(Equal(`{"keys":[` +
+			string(testutils.Must(ks.Keys[0].MarshalJSONWithSettings(MarshalSettings{Canonical: true, PublicOnly: true}))) + "," +
+			string(testutils.Must(ks.Keys[1].MarshalJSONWithSettings(MarshalSettings{Canonical: true, PublicOnly: true}))) + "]}"))
+
+		Expect(string(testutils.Must(KeySpecSet{}.MarshalJSONWithSettings(MarshalSettings{Canonical: true})))).
+			To(Equal(`{"keys":[]}`))
+		_, err := KeySpecSet{Keys: keys}.MarshalJSONWithSettings(MarshalSettings{Canonical: true, PublicOnly: true})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,145 @@
+package jwk
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+)
+
+// OctetKey is a symmetric key ('oct'), used with algorithms such as HMAC,
+// AES Key Wrap or AES-GCM.
+//
+// KeySpec.Key may contain either an OctetKey or a plain []byte. Parsed JWKs
+// always contain a []byte, which can be converted with KeySpec.OctetKey().
+type OctetKey []byte
+
+// octetKeySizes contains the required key size (in bytes) for all supported
+// symmetric algorithms. HMAC keys may be longer, but never shorter.
+var octetKeySizes = map[string]int{
+	"HS256":         32,
+	"HS384":         48,
+	"HS512":         64,
+	"A128KW":        16,
+	"A192KW":        24,
+	"A256KW":        32,
+	"A128GCMKW":     16,
+	"A192GCMKW":     24,
+	"A256GCMKW":     32,
+	"A128GCM":       16,
+	"A192GCM":       24,
+	"A256GCM":       32,
+	"A128CBC-HS256": 32,
+	"A192CBC-HS384": 48,
+	"A256CBC-HS512": 64,
+}
+
+// OctetKeySize returns the size in bytes of a symmetric key for the
+// specified algorithm.
+func OctetKeySize(alg string) (int, error) {
+	size, ok := octetKeySizes[alg]
+	if !ok {
+		return 0, fmt.Errorf("unsupported symmetric algorithm: %s", alg)
+	}
+	return size, nil
+}
+
+// GenerateOctetKey generates a new random symmetric key with the right size
+// for the specified algorithm.
+func GenerateOctetKey(alg string, rand io.Reader) (OctetKey, error) {
+	size, err := OctetKeySize(alg)
+	if err != nil {
+		return nil, err
+	}
+	key := make(OctetKey, size)
+	if _, err := io.ReadFull(rand, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// GenerateOctetKeySpec generates a new random symmetric key for the
+// specified algorithm and returns it as a KeySpec with 'alg' and 'use' set.
+// The Key ID is left empty, and can be generated with KeySpec.Normalize().
+func GenerateOctetKeySpec(alg string, rand io.Reader) (*KeySpec, error) {
+	key, err := GenerateOctetKey(alg, rand)
+	if err != nil {
+		return nil, err
+	}
+	use := "enc"
+	if isHMACAlgorithm(alg) {
+		use = "sig"
+	}
+	return &KeySpec{Key: key, Algorithm: alg, Use: use}, nil
+}
+
+func isHMACAlgorithm(alg string) bool {
+	return alg == "HS256" || alg == "HS384" || alg == "HS512"
+}
+
+// SignatureAlgorithm returns the strongest HMAC algorithm that can be used
+// with the key, based on its length, or an empty string if the key is too
+// short for HMAC (RFC 7518 requires keys at least as long as the hash).
+func (k OctetKey) SignatureAlgorithm() string {
+	switch {
+	case len(k) >= 64:
+		return "HS512"
+	case len(k) >= 48:
+		return "HS384"
+	case len(k) >= 32:
+		return "HS256"
+	default:
+		return ""
+	}
+}
+
+// KeyWrapAlgorithm returns the AES Key Wrap algorithm matching the key
+// length, or an empty string if the key is not a valid AES key.
+func (k OctetKey) KeyWrapAlgorithm() string {
+	return k.aesAlgorithm("KW")
+}
+
+// ContentEncryptionAlgorithm returns the AES-GCM content encryption
+// algorithm matching the key length, or an empty string if the key is not a
+// valid AES key.
+func (k OctetKey) ContentEncryptionAlgorithm() string {
+	return k.aesAlgorithm("GCM")
+}
+
+func (k OctetKey) aesAlgorithm(mode string) string {
+	switch len(k) {
+	case 16:
+		return "A128" + mode
+	case 24:
+		return "A192" + mode
+	case 32:
+		return "A256" + mode
+	default:
+		return ""
+	}
+}
+
+// Equal compares two keys in constant time.
+// The time taken only depends on the length of the keys.
+func (k OctetKey) Equal(other OctetKey) bool {
+	return subtle.ConstantTimeCompare(k, other) == 1
+}
+
+// Destroy overwrites the key material with zeros.
+// The key must not be used afterwards.
+func (k OctetKey) Destroy() {
+	clear(k)
+}
+
+// OctetKey returns the symmetric key inside the KeySpec (either an OctetKey
+// or a []byte), and false if the KeySpec does not contain a symmetric key.
+// The returned key shares its memory with the KeySpec.
+func (k *KeySpec) OctetKey() (OctetKey, bool) {
+	switch key := k.Key.(type) {
+	case OctetKey:
+		return key, true
+	case []byte:
+		return OctetKey(key), true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,109 @@
+package jwk
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OctetKey", func() {
+	DescribeTable("Should generate keys with the right size",
+		func(alg string, size int, use string) {
+			key, err := GenerateOctetKey(alg, rand.Reader)
+			Expect(err).To(Succeed())
+			Expect(key).To(HaveLen(size))
+
+			k, err := GenerateOctetKeySpec(alg, rand.Reader)
+			Expect(err).To(Succeed())
+			Expect(k.Algorithm).To(Equal(alg))
+			Expect(k.Use).To(Equal(use))
+			Expect(k.IsKeyType("oct")).To(BeTrue())
+		},
+		Entry("HS256", "HS256", 32, "sig"),
+		Entry("HS384", "HS384", 48, "sig"),
+		Entry("HS512", "HS512", 64, "sig"),
+		Entry("A128KW", "A128KW", 16, "enc"),
+		Entry("A192KW", "A192KW", 24, "enc"),
+		Entry("A256KW", "A256KW", 32, "enc"),
+		Entry("A256GCMKW", "A256GCMKW", 32, "enc"),
+		Entry("A128GCM", "A128GCM", 16, "enc"),
+		Entry("A256CBC-HS512", "A256CBC-HS512", 64, "enc"),
+	)
+
+	It("Should reject unknown algorithms", func() {
+		_, err := GenerateOctetKey("ES256", rand.Reader)
+		Expect(err).To(HaveOccurred())
+	})
+
+	DescribeTable("Should derive algorithms from the key length",
+		func(size int, sigAlg, kwAlg, encAlg string) {
+			key := OctetKey(make([]byte, size))
+			Expect(key.SignatureAlgorithm()).To(Equal(sigAlg))
+			Expect(key.KeyWrapAlgorithm()).To(Equal(kwAlg))
+			Expect(key.ContentEncryptionAlgorithm()).To(Equal(encAlg))
+		},
+		Entry("16 bytes", 16, "", "A128KW", "A128GCM"),
+		Entry("24 bytes", 24, "", "A192KW", "A192GCM"),
+		Entry("32 bytes", 32, "HS256", "A256KW", "A256GCM"),
+		Entry("48 bytes", 48, "HS384", "", ""),
+		Entry("64 bytes", 64, "HS512", "", ""),
+		Entry("100 bytes", 100, "HS512", "", ""),
+	)
+
+	It("Should normalize algorithms based on the key length", func() {
+		for _, key := range []interface{}{make([]byte, 48), OctetKey(make([]byte, 48))} {
+			k := NewSpec(key)
+			Expect(k.Normalize(NormalizationSettings{Use: "sig"})).To(Succeed())
+			Expect(k.Algorithm).To(Equal("HS384"))
+		}
+		k := NewSpec(make([]byte, 24))
+		Expect(k.Normalize(NormalizationSettings{Use: "enc"})).To(Succeed())
+		Expect(k.Algorithm).To(Equal("A192KW"))
+
+		k = NewSpec(make([]byte, 16))
+		Expect(k.Normalize(NormalizationSettings{Use: "sig", RequireAlgorithm: true})).ToNot(Succeed())
+	})
+
+	It("Should marshal like a []byte key", func() {
+		key, err := GenerateOctetKey("HS256", rand.Reader)
+		Expect(err).To(Succeed())
+		b1, err := json.Marshal(NewSpecWithID("k", key))
+		Expect(err).To(Succeed())
+		b2, err := json.Marshal(NewSpecWithID("k", []byte(key)))
+		Expect(err).To(Succeed())
+		Expect(b1).To(Equal(b2))
+
+		t1, err := NewSpec(key).Thumbprint()
+		Expect(err).To(Succeed())
+		t2, err := NewSpec([]byte(key)).Thumbprint()
+		Expect(err).To(Succeed())
+		Expect(t1).To(Equal(t2))
+
+		var k KeySpec
+		Expect(json.Unmarshal(b1, &k)).To(Succeed())
+		parsed, ok := k.OctetKey()
+		Expect(ok).To(BeTrue())
+		Expect(parsed.Equal(key)).To(BeTrue())
+	})
+
+	It("Should compare and destroy keys", func() {
+		key, err := GenerateOctetKey("A256KW", rand.Reader)
+		Expect(err).To(Succeed())
+		other := OctetKey(bytes.Clone(key))
+		Expect(key.Equal(other)).To(BeTrue())
+		other[0] ^= 1
+		Expect(key.Equal(other)).To(BeFalse())
+		Expect(key.Equal(key[:16])).To(BeFalse())
+
+		key.Destroy()
+		Expect(key).To(Equal(OctetKey(make([]byte, 32))))
+	})
+
+	It("Should not convert asymmetric keys", func() {
+		_, ok := NewSpec(Ed25519Example).OctetKey()
+		Expect(ok).To(BeFalse())
+	})
+})
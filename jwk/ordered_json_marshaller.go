@@ -2,13 +2,20 @@ package jwk
 
 import (
 	"encoding/base64"
-	"encoding/json"
+	"math/big"
+	"slices"
 	"strconv"
+	"unicode/utf8"
 )
 
 type orderedJsonMarshaller struct {
 	started bool
 	buffer  []byte
+
+	// canonical serializes strings and numbers as specified by the JSON
+	// Canonicalization Scheme (RFC 8785). Members must be written in
+	// canonical order by the caller.
+	canonical bool
 }
 
 func newOrderedJsonMarshaller(initialCapacity int) orderedJsonMarshaller {
@@ -20,17 +27,16 @@ func newOrderedJsonMarshaller(initialCapacity int) orderedJsonMarshaller {
 	}
 }
 
-func (m *orderedJsonMarshaller) marshalString(name string, value string) error {
+func (m *orderedJsonMarshaller) marshalString(name string, value string) {
 	if value == "" {
-		return nil // Do not write empty values
-	}
-	quotedValue, err := json.Marshal(value)
-	if err != nil {
-		return err
+		return // Do not write empty values
 	}
 	m.marshalKeyName(name)
-	m.buffer = append(m.buffer, quotedValue...)
-	return nil
+	if m.canonical {
+		m.buffer = appendCanonicalJSONString(m.buffer, value)
+	} else {
+		m.buffer = appendJSONString(m.buffer, value)
+	}
 }
 
 func (m *orderedJsonMarshaller) marshalInt(name string, value int64) {
@@ -38,29 +44,85 @@ func (m *orderedJsonMarshaller) marshalInt(name string, value int64) {
 		return
 	}
 	m.marshalKeyName(name)
-	m.buffer = strconv.AppendInt(m.buffer, value, 10)
+	if m.canonical {
+		// JCS serializes numbers like ECMAScript, as IEEE 754 doubles
+		m.buffer = strconv.AppendFloat(m.buffer, float64(value), 'f', -1, 64)
+	} else {
+		m.buffer = strconv.AppendInt(m.buffer, value, 10)
+	}
 }
 
 func (m *orderedJsonMarshaller) marshalBytes(name string, value *keyBytes) {
 	if value == nil {
 		return
 	}
+	m.marshalRawBytes(name, value.data)
+}
 
-	byteData := value.data
-
-	if len(byteData) == 0 {
+func (m *orderedJsonMarshaller) marshalRawBytes(name string, value []byte) {
+	if len(value) == 0 {
 		return
 	}
 
 	m.marshalKeyName(name)
 	m.buffer = append(m.buffer, '"')
-	encodedValueLen := base64.RawURLEncoding.EncodedLen(len(byteData))
-	valueIndex := len(m.buffer)
-	m.buffer = append(m.buffer, make([]byte, encodedValueLen)...)
-	base64.RawURLEncoding.Encode(m.buffer[valueIndex:], byteData)
+	m.buffer = base64.RawURLEncoding.AppendEncode(m.buffer, value)
 	m.buffer = append(m.buffer, '"')
 }
 
+// marshalBigInt writes a big integer as a big-endian byte string, padded to
+// size bytes (or with the minimal length if size is 0). The integer is
+// converted on the stack when possible, and the temporary copy is cleared.
+func (m *orderedJsonMarshaller) marshalBigInt(name string, value *big.Int, size int) {
+	if value == nil {
+		return
+	}
+	if size == 0 {
+		size = bigIntSize(value)
+	}
+	var scratch [1024]byte
+	var b []byte
+	if size <= len(scratch) {
+		b = scratch[:size]
+	} else {
+		b = make([]byte, size)
+	}
+	value.FillBytes(b)
+	m.marshalRawBytes(name, b)
+	clear(b)
+}
+
+// grow ensures the buffer has room for another n bytes (and the closing brace)
+// without being reallocated.
+func (m *orderedJsonMarshaller) grow(n int) {
+	m.buffer = slices.Grow(m.buffer, n+1)
+}
+
+// bytesFieldsLen returns the maximum length of the specified byte fields
+// when marshaled with marshalBytes, excluding the length of their names.
+func bytesFieldsLen(values ...*keyBytes) int {
+	n := 0
+	for _, value := range values {
+		if value != nil {
+			n += bytesFieldLen(len(value.data))
+		}
+	}
+	return n
+}
+
+// bytesFieldLen returns the maximum length of a byte field of size bytes
+// when marshaled with marshalRawBytes.
+func bytesFieldLen(size int) int {
+	const maxNameLen = 2
+	// ,"name":"value"
+	return len(`,"":""`) + maxNameLen + base64.RawURLEncoding.EncodedLen(size)
+}
+
+// bigIntSize returns the minimal number of bytes needed to encode value
+func bigIntSize(value *big.Int) int {
+	return (value.BitLen() + 7) / 8
+}
+
 func (m *orderedJsonMarshaller) marshalKeyName(name string) {
 	if m.started {
 		m.buffer = append(m.buffer, ',') // Add comma
@@ -77,3 +139,101 @@ func (m *orderedJsonMarshaller) finalize() []byte {
 	m.buffer = append(m.buffer, '}')
 	return m.buffer
 }
+
+// appendJSONString appends s as a JSON string, escaped exactly like
+// encoding/json does (including HTML characters).
+func appendJSONString(dst []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				dst = append(dst, '\\', b)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, `\ufffd`...)
+		case r == '\u2028' || r == '\u2029':
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hex[r&0xF])
+		default:
+			i += size
+			continue
+		}
+		i += size
+		start = i
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
+
+// appendCanonicalJSONString appends s as a JSON string, escaped as specified
+// by RFC 8785 section 3.2.2.2. s must be valid UTF-8.
+func appendCanonicalJSONString(dst []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		if b >= 0x20 && b != '"' && b != '\\' {
+			continue
+		}
+		dst = append(dst, s[start:i]...)
+		switch b {
+		case '"', '\\':
+			dst = append(dst, '\\', b)
+		case '\b':
+			dst = append(dst, '\\', 'b')
+		case '\f':
+			dst = append(dst, '\\', 'f')
+		case '\n':
+			dst = append(dst, '\\', 'n')
+		case '\r':
+			dst = append(dst, '\\', 'r')
+		case '\t':
+			dst = append(dst, '\\', 't')
+		default:
+			dst = append(dst, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xF])
+		}
+		start = i + 1
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
+
+// stringFieldLen returns the maximum length of a string field when marshaled
+// with marshalString.
+func stringFieldLen(value string) int {
+	const maxNameLen = 3
+	// ,"name":"value" where each byte takes at most 6 bytes (\u00XX)
+	return len(`,"":""`) + maxNameLen + 6*len(value)
+}
+
+// intFieldLen is the maximum length of an integer field when marshaled with
+// marshalInt
+const intFieldLen = len(`,"":`) + 3 + len("-9223372036854775808")
@@ -0,0 +1,93 @@
+package jwk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"github.com/rakutentech/jwk-go/okp"
+)
+
+// ParseSettings specifies which keys ParseKeySet skips instead of failing.
+// The zero value fails on all invalid keys, like json.Unmarshal.
+type ParseSettings struct {
+	// SkipUnsupported skips keys with an unknown key type or curve, as
+	// recommended by RFC 7517 section 5.
+	SkipUnsupported bool
+
+	// SkipMalformed skips keys which are supported but invalid, e.g. keys
+	// with missing members or points which are not on their curve.
+	SkipMalformed bool
+}
+
+// ParseKeySet parses a JWKS, skipping the keys which cannot be parsed as
+// specified by settings. The usable keys are returned together with the
+// errors of the skipped keys, which contain their index, Key ID and reason.
+//
+// An error is returned if the JWKS itself is malformed, or if a key cannot be
+// parsed and is not skipped.
+func ParseKeySet(data []byte, settings ParseSettings) (KeySpecSet, []*KeyError, error) {
+	var ks KeySpecSet
+	var skipped []*KeyError
+	for k, err := range NewDecoder(bytes.NewReader(data)).Keys() {
+		if err != nil {
+			var keyErr *KeyError
+			if errors.As(err, &keyErr) && settings.skips(keyErr) {
+				skipped = append(skipped, keyErr)
+				continue
+			}
+			return KeySpecSet{}, skipped, err
+		}
+		ks.Keys = append(ks.Keys, k)
+	}
+	return ks, skipped, nil
+}
+
+// KeyFileFormat is the format of the data parsed by ParseKeyFile
+type KeyFileFormat int
+
+// Formats detected by ParseKeyFile
+const (
+	KeyFileJWKS KeyFileFormat = iota // A JWK Set
+	KeyFileJWK                       // A single JWK
+	KeyFilePEM                       // PEM blocks (see ParsePEM)
+)
+
+// ParseKeyFile parses the content of a key file, which may contain a JWKS, a
+// single JWK or PEM blocks, and returns the keys together with the detected
+// format. A JWKS is parsed with ParseKeySet and the zero ParseSettings.
+func ParseKeyFile(data []byte) (KeySpecSet, KeyFileFormat, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN ")) {
+		ks, err := ParsePEM(data)
+		return ks, KeyFilePEM, err
+	}
+	var probe struct {
+		Keys json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return KeySpecSet{}, KeyFileJWKS, err
+	}
+	if probe.Keys != nil {
+		ks, _, err := ParseKeySet(data, ParseSettings{})
+		return ks, KeyFileJWKS, err
+	}
+	k, err := ParseBytes(data)
+	if err != nil {
+		return KeySpecSet{}, KeyFileJWK, err
+	}
+	return KeySpecSet{Keys: []KeySpec{*k}}, KeyFileJWK, nil
+}
+
+func (settings ParseSettings) skips(err *KeyError) bool {
+	if isUnsupportedKey(err) {
+		return settings.SkipUnsupported
+	}
+	return settings.SkipMalformed
+}
+
+// isUnsupportedKey returns true for errors caused by unknown key types or
+// curves
+func isUnsupportedKey(err error) bool {
+	return errors.Is(err, ErrUnknownKeyType) || errors.Is(err, ErrUnknownCurve) ||
+		errors.Is(err, okp.ErrUnknownCurve)
+}
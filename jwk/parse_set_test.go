@@ -0,0 +1,103 @@
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rakutentech/jwk-go/internal/testutils"
+	"github.com/rakutentech/jwk-go/okp"
+)
+
+var _ = Describe("ParseKeySet", func() {
+	const exoticKeys = `{"keys": [
+		{"kty": "oct", "kid": "a", "k": "AQID"},
+		{"kty": "AKP", "kid": "pq", "alg": "ML-DSA-44", "pub": "AQID"},
+		{"kty": "EC", "kid": "brainpool", "crv": "BP-256", "x": "AQID", "y": "AQID"},
+		{"kty": "OKP", "kid": "x9", "crv": "X9", "x": "AQID"},
+		{"kty": "oct", "kid": "c", "k": "BAUG"}
+	]}`
+	const malformedKeys = `{"keys": [
+		{"kty": "oct", "kid": "a", "k": "AQID"},
+		{"kty": "RSA", "kid": "broken", "e": "AQAB"},
+		{"kty": "oct", "kid": "c", "k": "BAUG"}
+	]}`
+
+	It("Should fail on invalid keys by default", func() {
+		var ks KeySpecSet
+		err := json.Unmarshal([]byte(exoticKeys), &ks)
+		Expect(errors.Is(err, ErrUnknownKeyType)).To(BeTrue())
+
+		_, _, err = ParseKeySet([]byte(exoticKeys), ParseSettings{})
+		Expect(errors.Is(err, ErrUnknownKeyType)).To(BeTrue())
+		_, _, err = ParseKeySet([]byte(malformedKeys), ParseSettings{SkipUnsupported: true})
+		Expect(err).To(MatchError(ContainSubstring("missing field n/e")))
+	})
+
+	It("Should skip unsupported keys", func() {
+		ks, skipped, err := ParseKeySet([]byte(exoticKeys), ParseSettings{SkipUnsupported: true})
+		Expect(err).To(Succeed())
+		Expect(keyIDs(ks)).To(Equal([]string{"a", "c"}))
+		Expect(skipped).To(HaveLen(3))
+
+		Expect(skipped[0].Index).To(Equal(1))
+		Expect(skipped[0].KeyID).To(Equal("pq"))
+		Expect(errors.Is(skipped[0], ErrUnknownKeyType)).To(BeTrue())
+		Expect(skipped[1].KeyID).To(Equal("brainpool"))
+		Expect(errors.Is(skipped[1], ErrUnknownCurve)).To(BeTrue())
+		Expect(skipped[2].KeyID).To(Equal("x9"))
+		Expect(errors.Is(skipped[2], okp.ErrUnknownCurve)).To(BeTrue())
+	})
+
+	It("Should skip malformed keys", func() {
+		_, _, err := ParseKeySet([]byte(exoticKeys), ParseSettings{SkipMalformed: true})
+		Expect(errors.Is(err, ErrUnknownKeyType)).To(BeTrue())
+
+		ks, skipped, err := ParseKeySet([]byte(malformedKeys), ParseSettings{SkipMalformed: true})
+		Expect(err).To(Succeed())
+		Expect(keyIDs(ks)).To(Equal([]string{"a", "c"}))
+		Expect(skipped).To(HaveLen(1))
+		Expect(skipped[0].Error()).To(Equal(`key 1 (kid "broken"): missing field n/e for RSA key`))
+	})
+
+	It("Should fail on malformed sets", func() {
+		_, _, err := ParseKeySet([]byte(`{"keys": [}`), ParseSettings{SkipUnsupported: true, SkipMalformed: true})
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("ParseKeyFile", func() {
+	key := NewSpecWithID("ec", testutils.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader)))
+
+	It("Should detect the format", func() {
+		ks, format, err := ParseKeyFile(testutils.Must(json.Marshal(KeySpecSet{Keys: []KeySpec{*key}})))
+		Expect(err).To(Succeed())
+		Expect(format).To(Equal(KeyFileJWKS))
+		Expect(keyIDs(ks)).To(Equal([]string{"ec"}))
+
+		ks, format, err = ParseKeyFile(testutils.Must(key.MarshalJSON()))
+		Expect(err).To(Succeed())
+		Expect(format).To(Equal(KeyFileJWK))
+		Expect(keyIDs(ks)).To(Equal([]string{"ec"}))
+
+		ks, format, err = ParseKeyFile(append([]byte("\n"), testutils.Must(key.MarshalPEM())...))
+		Expect(err).To(Succeed())
+		Expect(format).To(Equal(KeyFilePEM))
+		Expect(ks.Keys).To(HaveLen(1))
+		Expect(ks.Keys[0].Equal(NewSpec(key.Key))).To(BeTrue())
+	})
+
+	It("Should fail on invalid files", func() {
+		_, _, err := ParseKeyFile([]byte(`{"keys": [{"kty": "AKP"}]}`))
+		Expect(errors.Is(err, ErrUnknownKeyType)).To(BeTrue())
+		_, _, err = ParseKeyFile([]byte(`{"kty": "RSA"}`))
+		Expect(err).To(HaveOccurred())
+		_, _, err = ParseKeyFile([]byte("not a key"))
+		Expect(err).To(HaveOccurred())
+	})
+})
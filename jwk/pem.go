@@ -0,0 +1,156 @@
+package jwk
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/rakutentech/jwk-go/okp"
+)
+
+// PEM block types used when encoding keys
+const (
+	pemPrivateKey  = "PRIVATE KEY"
+	pemPublicKey   = "PUBLIC KEY"
+	pemCertificate = "CERTIFICATE"
+)
+
+// MarshalPEM serializes the key inside the KeySpec to PEM.
+// Private keys are encoded as PKCS #8 ("PRIVATE KEY") and public keys are
+// encoded as PKIX ("PUBLIC KEY").
+//
+// PEM has no place for JWK metadata, so only the key itself is encoded.
+// Symmetric keys, Ed448 and X448 keys are not supported.
+func (k *KeySpec) MarshalPEM() ([]byte, error) {
+	stdKey, err := toStdKey(k.Key)
+	if err != nil {
+		return nil, err
+	}
+	if k.IsPublic() {
+		der, err := x509.MarshalPKIXPublicKey(stdKey)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: pemPublicKey, Bytes: der}), nil
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(stdKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemPrivateKey, Bytes: der}), nil
+}
+
+// MarshalPublicPEM serializes only the public key inside the KeySpec to PEM.
+// For public keys this gives exactly the same result as MarshalPEM()
+func (k *KeySpec) MarshalPublicPEM() ([]byte, error) {
+	publicKey, err := k.PublicOnly()
+	if err != nil {
+		return nil, err
+	}
+	return publicKey.MarshalPEM()
+}
+
+// ParsePEM parses all the PEM blocks in data into a KeySpecSet.
+//
+// The following PEM block types are supported:
+// "PRIVATE KEY" (PKCS #8), "RSA PRIVATE KEY" (PKCS #1),
+// "EC PRIVATE KEY" (SEC 1), "PUBLIC KEY" (PKIX), "RSA PUBLIC KEY" (PKCS #1)
+// and "CERTIFICATE" (only the public key is extracted).
+//
+// The resulting KeySpecs contain no metadata, so it is usually a good idea
+// to call KeySpec.Normalize() on them.
+func ParsePEM(data []byte) (KeySpecSet, error) {
+	var keys []KeySpec
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		stdKey, err := parsePEMBlock(block)
+		if err != nil {
+			return KeySpecSet{}, err
+		}
+		key, err := fromStdKey(stdKey)
+		if err != nil {
+			return KeySpecSet{}, err
+		}
+		keys = append(keys, KeySpec{Key: key})
+	}
+	if len(keys) == 0 {
+		return KeySpecSet{}, errors.New("no PEM encoded keys found")
+	}
+	return KeySpecSet{Keys: keys}, nil
+}
+
+func parsePEMBlock(block *pem.Block) (interface{}, error) {
+	switch block.Type {
+	case pemPrivateKey:
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case pemPublicKey:
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case pemCertificate:
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type: %s", block.Type)
+	}
+}
+
+// toStdKey converts a KeySpec key into a key type supported by crypto/x509.
+func toStdKey(keyInterface interface{}) (interface{}, error) {
+	switch key := keyInterface.(type) {
+	case *rsa.PublicKey, *rsa.PrivateKey, *ecdsa.PublicKey, *ecdsa.PrivateKey:
+		return key, nil
+	case okp.Ed25519:
+		if key.PrivateKey() != nil {
+			return ed25519.NewKeyFromSeed(key.PrivateKey()), nil
+		}
+		return ed25519.PublicKey(key.PublicKey()), nil
+	case okp.Curve25519:
+		if key.PrivateKey() != nil {
+			return ecdh.X25519().NewPrivateKey(key.PrivateKey())
+		}
+		return ecdh.X25519().NewPublicKey(key.PublicKey())
+	default:
+		return nil, errors.New("unsupported key type (cannot convert to PEM)")
+	}
+}
+
+// fromStdKey converts a key parsed by crypto/x509 into a KeySpec key.
+func fromStdKey(keyInterface interface{}) (interface{}, error) {
+	switch key := keyInterface.(type) {
+	case *rsa.PublicKey, *rsa.PrivateKey, *ecdsa.PublicKey, *ecdsa.PrivateKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return okp.NewEd25519(key.Public().(ed25519.PublicKey), key.Seed()), nil
+	case ed25519.PublicKey:
+		return okp.NewEd25519(key, nil), nil
+	case *ecdh.PrivateKey:
+		if key.Curve() != ecdh.X25519() {
+			return nil, errors.New("unsupported ECDH curve")
+		}
+		return okp.NewCurve25519(key.PublicKey().Bytes(), key.Bytes()), nil
+	case *ecdh.PublicKey:
+		if key.Curve() != ecdh.X25519() {
+			return nil, errors.New("unsupported ECDH curve")
+		}
+		return okp.NewCurve25519(key.Bytes(), nil), nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM key type: %T", keyInterface)
+	}
+}
@@ -0,0 +1,69 @@
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rakutentech/jwk-go/internal/testutils"
+)
+
+var _ = Describe("PEM", func() {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	testutils.PanicOnError(err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	testutils.PanicOnError(err)
+
+	DescribeTable("Should round-trip keys through PEM",
+		func(key interface{}) {
+			k := NewSpec(key)
+			b, err := k.MarshalPEM()
+			Expect(err).To(Succeed())
+			ks, err := ParsePEM(b)
+			Expect(err).To(Succeed())
+			Expect(ks.Keys).To(HaveLen(1))
+			Expect(ks.Keys[0].IsPublic()).To(Equal(k.IsPublic()))
+			Expect(ks.Keys[0].Thumbprint()).To(Equal(testutils.Must(k.Thumbprint())))
+
+			b, err = k.MarshalPublicPEM()
+			Expect(err).To(Succeed())
+			ks, err = ParsePEM(b)
+			Expect(err).To(Succeed())
+			Expect(ks.Keys[0].IsPublic()).To(BeTrue())
+			Expect(ks.Keys[0].Thumbprint()).To(Equal(testutils.Must(k.Thumbprint())))
+		},
+		Entry("RSA", rsaKey),
+		Entry("RSA public", &rsaKey.PublicKey),
+		Entry("EC", ecKey),
+		Entry("EC public", &ecKey.PublicKey),
+		Entry("Ed25519", Ed25519Example),
+		Entry("X25519", X25519Example),
+	)
+
+	It("Should parse PEM bundles", func() {
+		var bundle []byte
+		for _, key := range []interface{}{rsaKey, &ecKey.PublicKey, Ed25519Example} {
+			b, err := NewSpec(key).MarshalPEM()
+			Expect(err).To(Succeed())
+			bundle = append(bundle, b...)
+		}
+		ks, err := ParsePEM(bundle)
+		Expect(err).To(Succeed())
+		Expect(ks.Keys).To(HaveLen(3))
+		Expect(ks.Keys[0].IsKeyType("RSA")).To(BeTrue())
+		Expect(ks.Keys[1].IsKeyType("EC/P-384")).To(BeTrue())
+		Expect(ks.Keys[2].IsKeyType("OKP/Ed25519")).To(BeTrue())
+		Expect(ks.Keys[2].Key).To(Equal(Ed25519Example))
+	})
+
+	It("Should fail on symmetric keys and empty input", func() {
+		_, err := NewSpec([]byte("secret")).MarshalPEM()
+		Expect(err).To(HaveOccurred())
+		_, err = ParsePEM([]byte("not a PEM"))
+		Expect(err).To(HaveOccurred())
+	})
+})
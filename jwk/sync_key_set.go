@@ -0,0 +1,146 @@
+package jwk
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// SyncKeySet is a KeySpecSet which can be shared between goroutines and
+// updated while it is being used, e.g. during key rotation.
+//
+// Readers get a snapshot of the current KeySpecSet, which is never modified by
+// later updates: Load returns a private copy, and View returns the shared
+// snapshot itself for read-only use. Writers replace the whole set atomically.
+// The zero value is an empty SyncKeySet ready to use.
+type SyncKeySet struct {
+	current atomic.Pointer[KeySpecSet]
+
+	// mu serializes writers and subscriptions
+	mu          sync.Mutex
+	subscribers []subscriber
+	nextID      uint64
+}
+
+type subscriber struct {
+	id       uint64
+	callback func(KeySpecSet)
+}
+
+// NewSyncKeySet creates a SyncKeySet containing the specified keys.
+func NewSyncKeySet(ks KeySpecSet) *SyncKeySet {
+	s := &SyncKeySet{}
+	s.current.Store(cloneKeySet(ks))
+	return s
+}
+
+// Load returns a snapshot of the current KeySpecSet. The returned set may be
+// modified without affecting the SyncKeySet, but the keys inside it (e.g.
+// *rsa.PrivateKey) are shared and must not be modified.
+func (s *SyncKeySet) Load() KeySpecSet {
+	ks := s.current.Load()
+	if ks == nil {
+		return KeySpecSet{}
+	}
+	return *cloneKeySet(*ks)
+}
+
+// View returns the current KeySpecSet without copying it, which is cheaper
+// than Load for read-only callers. The returned set is shared with all other
+// callers of View and must not be modified.
+func (s *SyncKeySet) View() KeySpecSet {
+	ks := s.current.Load()
+	if ks == nil {
+		return KeySpecSet{}
+	}
+	return *ks
+}
+
+// Store replaces the current KeySpecSet and notifies all subscribers.
+// The SyncKeySet keeps its own copy of ks.
+func (s *SyncKeySet) Store(ks KeySpecSet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store(ks)
+}
+
+// Update atomically replaces the current KeySpecSet with the result of
+// update, which is called with a snapshot of the current set. Concurrent
+// updates are serialized, so no update is lost. If update returns an error,
+// the set is left unchanged and the error is returned.
+func (s *SyncKeySet) Update(update func(ks KeySpecSet) (KeySpecSet, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ks, err := update(s.Load())
+	if err != nil {
+		return err
+	}
+	s.store(ks)
+	return nil
+}
+
+func (s *SyncKeySet) store(ks KeySpecSet) {
+	s.current.Store(cloneKeySet(ks))
+	for _, sub := range s.subscribers {
+		sub.callback(s.Load())
+	}
+}
+
+// Subscribe registers a callback which is called with the new KeySpecSet
+// every time the set is replaced, until the returned cancel function is
+// called.
+//
+// Callbacks are called synchronously by the writer, in order of subscription,
+// so they should return quickly and must not modify the SyncKeySet.
+func (s *SyncKeySet) Subscribe(callback func(ks KeySpecSet)) (cancel func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextID
+	s.nextID++
+	s.subscribers = append(s.subscribers, subscriber{id, callback})
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.subscribers = slices.DeleteFunc(s.subscribers, func(sub subscriber) bool {
+			return sub.id == id
+		})
+	}
+}
+
+// Watch returns a channel which receives the new KeySpecSet every time the
+// set is replaced. Slow readers only receive the latest set: intermediate sets
+// are dropped. The channel is closed when ctx is done.
+func (s *SyncKeySet) Watch(ctx context.Context) <-chan KeySpecSet {
+	ch := make(chan KeySpecSet, 1)
+	var mu sync.Mutex
+	closed := false
+	cancel := s.Subscribe(func(ks KeySpecSet) {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+		// Replace the pending set, if any, so the writer never blocks
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- ks
+	})
+	go func() {
+		<-ctx.Done()
+		cancel()
+		mu.Lock()
+		defer mu.Unlock()
+		closed = true
+		close(ch)
+	}()
+	return ch
+}
+
+func cloneKeySet(ks KeySpecSet) *KeySpecSet {
+	// Clip the copy, so appending to a viewed set never writes to the shared
+	// backing array
+	return &KeySpecSet{Keys: slices.Clip(slices.Clone(ks.Keys))}
+}
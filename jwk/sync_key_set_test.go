@@ -0,0 +1,128 @@
+package jwk
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SyncKeySet", func() {
+	It("Should be usable as a zero value", func() {
+		var s SyncKeySet
+		Expect(s.Load().Keys).To(BeEmpty())
+		s.Store(KeySpecSet{Keys: []KeySpec{newTestKey("a")}})
+		Expect(keyIDs(s.Load())).To(Equal([]string{"a"}))
+	})
+
+	It("Should isolate snapshots from updates", func() {
+		ks := KeySpecSet{Keys: []KeySpec{newTestKey("a")}}
+		s := NewSyncKeySet(ks)
+		ks.Keys[0].KeyID = "modified"
+
+		snapshot := s.Load()
+		snapshot.Keys[0].KeyID = "modified"
+		Expect(keyIDs(s.Load())).To(Equal([]string{"a"}))
+
+		s.Store(KeySpecSet{Keys: []KeySpec{newTestKey("b")}})
+		Expect(keyIDs(snapshot)).To(Equal([]string{"modified"}))
+		Expect(keyIDs(s.Load())).To(Equal([]string{"b"}))
+	})
+
+	It("Should share views of the current set", func() {
+		s := NewSyncKeySet(KeySpecSet{Keys: []KeySpec{newTestKey("a")}})
+		view := s.View()
+		Expect(&view.Keys[0]).To(BeIdenticalTo(&s.View().Keys[0]))
+		Expect(&view.Keys[0]).NotTo(BeIdenticalTo(&s.Load().Keys[0]))
+
+		// Views are clipped, so appending to one never writes to the shared array
+		Expect(cap(view.Keys)).To(Equal(len(view.Keys)))
+
+		s.Store(KeySpecSet{Keys: []KeySpec{newTestKey("b")}})
+		Expect(keyIDs(view)).To(Equal([]string{"a"}))
+		Expect(keyIDs(s.View())).To(Equal([]string{"b"}))
+		Expect(KeySpecSet{}).To(Equal(new(SyncKeySet).View()))
+	})
+
+	It("Should not apply failed updates", func() {
+		s := NewSyncKeySet(KeySpecSet{Keys: []KeySpec{newTestKey("a")}})
+		errFailed := errors.New("failed")
+		err := s.Update(func(ks KeySpecSet) (KeySpecSet, error) {
+			return KeySpecSet{}, errFailed
+		})
+		Expect(err).To(MatchError(errFailed))
+		Expect(keyIDs(s.Load())).To(Equal([]string{"a"}))
+	})
+
+	It("Should notify subscribers until they cancel", func() {
+		s := NewSyncKeySet(KeySpecSet{})
+		var received []string
+		cancel := s.Subscribe(func(ks KeySpecSet) {
+			received = append(received, keyIDs(ks)...)
+		})
+		s.Store(KeySpecSet{Keys: []KeySpec{newTestKey("a")}})
+		Expect(s.Update(func(ks KeySpecSet) (KeySpecSet, error) {
+			ks.Keys = append(ks.Keys, newTestKey("b"))
+			return ks, nil
+		})).To(Succeed())
+		cancel()
+		s.Store(KeySpecSet{})
+		Expect(received).To(Equal([]string{"a", "a", "b"}))
+	})
+
+	It("Should send the latest set to watchers", func() {
+		s := NewSyncKeySet(KeySpecSet{})
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := s.Watch(ctx)
+		s.Store(KeySpecSet{Keys: []KeySpec{newTestKey("a")}})
+		s.Store(KeySpecSet{Keys: []KeySpec{newTestKey("b")}})
+		Expect(keyIDs(<-ch)).To(Equal([]string{"b"}))
+
+		cancel()
+		Eventually(ch).Should(BeClosed())
+		s.Store(KeySpecSet{})
+	})
+
+	It("Should support concurrent readers and writers", func() {
+		s := NewSyncKeySet(KeySpecSet{})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ch := s.Watch(ctx)
+
+		const writers, updates = 4, 50
+		var wg sync.WaitGroup
+		for w := 0; w < writers; w++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < updates; i++ {
+					Expect(s.Update(func(ks KeySpecSet) (KeySpecSet, error) {
+						ks.Keys = append(ks.Keys, KeySpec{Key: randomBytes(32), KeyID: strconv.Itoa(w*updates + i)})
+						return ks, nil
+					})).To(Succeed())
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for i := 0; i < updates; i++ {
+					ks := s.Load()
+					for j := range ks.Keys {
+						ks.Keys[j].KeyID = "modified"
+					}
+					select {
+					case watched := <-ch:
+						Expect(watched.Validate()).To(Succeed())
+					default:
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		ks := s.Load()
+		Expect(ks.Keys).To(HaveLen(writers * updates))
+		Expect(ks.Validate()).To(Succeed())
+	})
+})
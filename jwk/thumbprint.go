@@ -1,13 +1,17 @@
 package jwk
 
 import (
+	"bytes"
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/rsa"
 	"crypto/sha256"
+	_ "crypto/sha512" // Register SHA-384 and SHA-512 for thumbprint URIs
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 
 	"hash"
 
@@ -25,6 +29,93 @@ func (k *KeySpec) ThumbprintUsing(h hash.Hash) ([]byte, error) {
 	return getKeyThumbprint(k.Key, h)
 }
 
+// ThumbprintURIPrefix is the prefix of all RFC 9278 JWK Thumbprint URIs.
+const ThumbprintURIPrefix = "urn:ietf:params:oauth:jwk-thumbprint:"
+
+// thumbprintHashNames maps hash functions to their names in the IANA
+// "Named Information Hash Algorithm" registry, as required by RFC 9278.
+var thumbprintHashNames = map[crypto.Hash]string{
+	crypto.SHA256: "sha-256",
+	crypto.SHA384: "sha-384",
+	crypto.SHA512: "sha-512",
+}
+
+// ThumbprintURI returns the KeySpec's RFC 9278 JWK Thumbprint URI using the
+// specified hash function, e.g.:
+// urn:ietf:params:oauth:jwk-thumbprint:sha-256:NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs
+func (k *KeySpec) ThumbprintURI(h crypto.Hash) (string, error) {
+	hashName, ok := thumbprintHashNames[h]
+	if !ok || !h.Available() {
+		return "", fmt.Errorf("unsupported hash function for thumbprint URIs: %v", h)
+	}
+	thumbprint, err := k.ThumbprintUsing(h.New())
+	if err != nil {
+		return "", err
+	}
+	return ThumbprintURIPrefix + hashName + ":" + base64.RawURLEncoding.EncodeToString(thumbprint), nil
+}
+
+// ParseThumbprintURI parses an RFC 9278 JWK Thumbprint URI and returns the
+// hash function and the thumbprint.
+func ParseThumbprintURI(uri string) (crypto.Hash, []byte, error) {
+	rest, ok := strings.CutPrefix(uri, ThumbprintURIPrefix)
+	if !ok {
+		return 0, nil, errors.New("not a JWK thumbprint URI")
+	}
+	hashName, encoded, ok := strings.Cut(rest, ":")
+	if !ok {
+		return 0, nil, errors.New("missing hash algorithm in JWK thumbprint URI")
+	}
+	var h crypto.Hash
+	for candidate, name := range thumbprintHashNames {
+		if name == hashName {
+			h = candidate
+		}
+	}
+	if h == 0 {
+		return 0, nil, fmt.Errorf("unsupported hash algorithm in JWK thumbprint URI: %s", hashName)
+	}
+	thumbprint, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid JWK thumbprint URI: %w", err)
+	}
+	if len(thumbprint) != h.Size() {
+		return 0, nil, fmt.Errorf("invalid thumbprint size for %s", hashName)
+	}
+	return h, thumbprint, nil
+}
+
+// LookupThumbprint returns the first KeySpec in the KeySpecSet whose RFC 7638
+// thumbprint using the hash function h matches the specified thumbprint, or
+// nil if no such key exists.
+//
+// This can be used to find the key referenced by a DPoP 'jkt' or a 'cnf' claim
+// (using crypto.SHA256).
+func (ks KeySpecSet) LookupThumbprint(h crypto.Hash, thumbprint []byte) *KeySpec {
+	if !h.Available() {
+		return nil
+	}
+	for i := range ks.Keys {
+		k := &ks.Keys[i]
+		t, err := k.ThumbprintUsing(h.New())
+		if err == nil && bytes.Equal(t, thumbprint) {
+			return k
+		}
+	}
+	return nil
+}
+
+// LookupThumbprintURI returns the first KeySpec in the KeySpecSet which
+// matches the specified RFC 9278 JWK Thumbprint URI, or nil if no such key
+// exists. An error is returned only if the URI is invalid.
+func (ks KeySpecSet) LookupThumbprintURI(uri string) (*KeySpec, error) {
+	h, thumbprint, err := ParseThumbprintURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	return ks.LookupThumbprint(h, thumbprint), nil
+}
+
 const rsaThumb = `{"e":"%s","kty":"RSA","n":"%s"}`
 const ecThumb = `{"crv":"%s","kty":"EC","x":"%s","y":"%s"}`
 const octThumb = `{"k":"%s","kty":"oct"}`
@@ -49,7 +140,14 @@ func writeECThumbprint(w io.Writer, key *ecdsa.PublicKey) error {
 }
 
 func writeOctThumbprint(w io.Writer, key []byte) {
-	fmt.Fprintf(w, octThumb, base64.RawURLEncoding.EncodeToString(key))
+	// Do not use fmt here: it would leave copies of the encoded symmetric key
+	// behind in strings and internal buffers.
+	buf := make([]byte, 0, len(octThumb)+base64.RawURLEncoding.EncodedLen(len(key)))
+	buf = append(buf, `{"k":"`...)
+	buf = base64.RawURLEncoding.AppendEncode(buf, key)
+	buf = append(buf, `","kty":"oct"}`...)
+	w.Write(buf)
+	clear(buf)
 }
 
 func writeCurveOKPThumbprint(w io.Writer, key okp.CurveOctetKeyPair) {
@@ -70,6 +168,8 @@ func writeAnyThumbprint(w io.Writer, key interface{}) error {
 		writeCurveOKPThumbprint(w, k)
 	case []byte:
 		writeOctThumbprint(w, k)
+	case OctetKey:
+		writeOctThumbprint(w, k)
 	default:
 		return errors.New("unsupported key type for thumbprints")
 	}
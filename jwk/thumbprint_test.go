@@ -0,0 +1,85 @@
+package jwk
+
+import (
+	"crypto"
+	"encoding/base64"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// Example key from RFC 7638 section 3.1
+const rfc7638Jwk = `{
+	"kty": "RSA",
+	"n": "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+	"e": "AQAB",
+	"alg": "RS256",
+	"kid": "2011-04-29"
+}`
+
+const rfc7638Thumbprint = "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"
+
+var _ = Describe("Thumbprint", func() {
+	k := MustParse(rfc7638Jwk)
+
+	It("Should compute the RFC 7638 thumbprint", func() {
+		thumbprint, err := k.Thumbprint()
+		Expect(err).To(Succeed())
+		Expect(base64.RawURLEncoding.EncodeToString(thumbprint)).To(Equal(rfc7638Thumbprint))
+	})
+
+	It("Should compute the RFC 9278 thumbprint URI", func() {
+		uri, err := k.ThumbprintURI(crypto.SHA256)
+		Expect(err).To(Succeed())
+		Expect(uri).To(Equal("urn:ietf:params:oauth:jwk-thumbprint:sha-256:" + rfc7638Thumbprint))
+
+		_, err = k.ThumbprintURI(crypto.MD5)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should parse thumbprint URIs", func() {
+		for _, h := range []crypto.Hash{crypto.SHA256, crypto.SHA384, crypto.SHA512} {
+			uri, err := k.ThumbprintURI(h)
+			Expect(err).To(Succeed())
+			parsedHash, thumbprint, err := ParseThumbprintURI(uri)
+			Expect(err).To(Succeed())
+			Expect(parsedHash).To(Equal(h))
+			Expect(k.ThumbprintUsing(h.New())).To(Equal(thumbprint))
+		}
+	})
+
+	DescribeTable("Should reject invalid thumbprint URIs",
+		func(uri string) {
+			_, _, err := ParseThumbprintURI(uri)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("wrong prefix", "urn:ietf:params:oauth:jwk:sha-256:"+rfc7638Thumbprint),
+		Entry("missing hash", "urn:ietf:params:oauth:jwk-thumbprint:"+rfc7638Thumbprint),
+		Entry("unknown hash", "urn:ietf:params:oauth:jwk-thumbprint:md5:"+rfc7638Thumbprint),
+		Entry("bad encoding", "urn:ietf:params:oauth:jwk-thumbprint:sha-256:"+rfc7638Thumbprint+"="),
+		Entry("wrong size", "urn:ietf:params:oauth:jwk-thumbprint:sha-512:"+rfc7638Thumbprint),
+	)
+
+	It("Should look up keys by thumbprint", func() {
+		ks := KeySpecSet{Keys: []KeySpec{
+			{Key: X25519Example, KeyID: "x25519"},
+			*k,
+			{Key: Ed25519Example, KeyID: "ed25519"},
+		}}
+		thumbprint, err := base64.RawURLEncoding.DecodeString(rfc7638Thumbprint)
+		Expect(err).To(Succeed())
+		found := ks.LookupThumbprint(crypto.SHA256, thumbprint)
+		Expect(found).To(BeIdenticalTo(&ks.Keys[1]))
+		Expect(ks.LookupThumbprint(crypto.SHA384, thumbprint)).To(BeNil())
+
+		uri, err := NewSpec(Ed25519Example).ThumbprintURI(crypto.SHA512)
+		Expect(err).To(Succeed())
+		found, err = ks.LookupThumbprintURI(uri)
+		Expect(err).To(Succeed())
+		Expect(found.KeyID).To(Equal("ed25519"))
+
+		found, err = ks.LookupThumbprintURI(ThumbprintURIPrefix + "sha-256:" + base64.RawURLEncoding.EncodeToString(make([]byte, 32)))
+		Expect(err).To(Succeed())
+		Expect(found).To(BeNil())
+	})
+})
@@ -4,42 +4,42 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rsa"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
 	"math/big"
-	"time"
 
+	"github.com/rakutentech/jwk-go/internal/numericdate"
 	"github.com/rakutentech/jwk-go/jwktypes"
 	"github.com/rakutentech/jwk-go/okp"
 )
 
+var (
+	// ErrUnknownKeyType is returned (wrapped) when parsing a JWK with an
+	// unsupported key type ('kty')
+	ErrUnknownKeyType = errors.New("unknown key type")
+
+	// ErrUnknownCurve is returned (wrapped) when parsing an EC JWK with an
+	// unsupported curve ('crv'). OKPs with an unsupported curve return
+	// okp.ErrUnknownCurve.
+	ErrUnknownCurve = errors.New("unknown elliptic curve")
+)
+
 // UnmarshalJSON deserializes a KeySpec from the given JSON.
 func (k *KeySpec) UnmarshalJSON(data []byte) error {
 	jwk := &JWK{}
-	err := json.Unmarshal(data, jwk)
-	if err != nil {
-		return err
+	err := jwk.UnmarshalJSON(data)
+	if err == nil {
+		err = k.fromJWK(jwk)
 	}
-
-	key, err := convertFromJwk(jwk)
 	if err != nil {
+		// Nothing references the decoded private fields
+		destroyBytes(jwk.D, jwk.P, jwk.Q, jwk.Dp, jwk.Dq, jwk.Qi, jwk.K)
 		return err
 	}
 
-	k.Key = key
-	k.KeyID = jwk.Kid
-	k.Algorithm = jwk.Alg
-	k.Use = jwk.Use
-	if jwk.Exp > 0 {
-		// Expiry is set to a valid (positive) value
-		k.ExpiresAt = time.Unix(jwk.Exp, 0)
-	} else {
-		// Zero value for time.Time means no expiration
-		k.ExpiresAt = time.Time{}
-	}
-
+	// Private fields which were copied into the key are not needed anymore
+	jwk.destroyPrivateCopies(k.Key)
 	return nil
 }
 
@@ -52,17 +52,28 @@ func (k *KeySpec) UnmarshalJSON(data []byte) error {
 // kty = "EC"  -> ecdsa.PrivateKey / ecdsa.PublicKey
 func (jwk *JWK) ParseKeySpec() (*KeySpec, error) {
 	k := KeySpec{}
-	key, err := convertFromJwk(jwk)
+	err := k.fromJWK(jwk)
 	if err != nil {
 		return nil, err
 	}
+	return &k, nil
+}
+
+func (k *KeySpec) fromJWK(jwk *JWK) error {
+	key, err := convertFromJwk(jwk)
+	if err != nil {
+		return err
+	}
 
 	k.Key = key
 	k.KeyID = jwk.Kid
 	k.Algorithm = jwk.Alg
 	k.Use = jwk.Use
+	k.ExpiresAt = numericdate.ToTime(jwk.Exp)
+	k.NotBefore = numericdate.ToTime(jwk.Nbf)
+	k.IssuedAt = numericdate.ToTime(jwk.Iat)
 
-	return &k, nil
+	return nil
 }
 
 // UnmarshalJSON reads a key from its JSON representation.
@@ -77,7 +88,7 @@ func convertFromJwk(jwk *JWK) (interface{}, error) {
 	case jwktypes.OKP:
 		return jwk.unmarshalOKP()
 	default:
-		return nil, fmt.Errorf("unknown key type: %s", jwk.Kty)
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKeyType, jwk.Kty)
 	}
 }
 
@@ -131,7 +142,7 @@ func (jwk *JWK) unmarshalEC() (interface{}, error) {
 
 	curve, ok := ecdsaCurves[jwk.Crv]
 	if !ok {
-		return nil, fmt.Errorf("unknown elliptic curve %s", jwk.Crv)
+		return nil, fmt.Errorf("%w %s", ErrUnknownCurve, jwk.Crv)
 	}
 
 	byteSize := curveByteSize(curve.Params())
@@ -0,0 +1,82 @@
+package jwk
+
+import "encoding/base64"
+
+// KeySetDiff describes the differences between two KeySpecSets.
+type KeySetDiff struct {
+	// Added contains the keys which are only present in the new set
+	Added []KeySpec
+	// Removed contains the keys which are only present in the old set
+	Removed []KeySpec
+	// Changed contains the keys which are present in both sets, but whose
+	// key material or metadata differ
+	Changed []KeyChange
+}
+
+// KeyChange is a key which has been modified between two KeySpecSets.
+type KeyChange struct {
+	Old KeySpec
+	New KeySpec
+}
+
+// IsEmpty returns true if both sets contain the same keys.
+func (d KeySetDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff compares the KeySpecSet (the old set) with other (the new set) and
+// reports the keys which were added, removed and changed.
+//
+// Keys are matched by Key ID, or by their SHA-256 thumbprint when they have
+// no Key ID. A matched key is changed when KeySpec.Equal returns false, e.g.
+// when a Key ID has been reused for a different key, or when its expiration
+// has been updated. The order of the keys within each set does not matter.
+func (ks KeySpecSet) Diff(other KeySpecSet) KeySetDiff {
+	var diff KeySetDiff
+
+	// Queue the new keys by identity, so that duplicate Key IDs are paired in
+	// order of appearance.
+	pending := make(map[string][]int, len(other.Keys))
+	for i := range other.Keys {
+		if id := other.Keys[i].diffIdentity(); id != "" {
+			pending[id] = append(pending[id], i)
+		}
+	}
+
+	matched := make([]bool, len(other.Keys))
+	for i := range ks.Keys {
+		oldKey := &ks.Keys[i]
+		id := oldKey.diffIdentity()
+		queue := pending[id]
+		if id == "" || len(queue) == 0 {
+			diff.Removed = append(diff.Removed, *oldKey)
+			continue
+		}
+		j := queue[0]
+		pending[id] = queue[1:]
+		matched[j] = true
+		if newKey := &other.Keys[j]; !oldKey.Equal(newKey) {
+			diff.Changed = append(diff.Changed, KeyChange{Old: *oldKey, New: *newKey})
+		}
+	}
+
+	for j := range other.Keys {
+		if !matched[j] {
+			diff.Added = append(diff.Added, other.Keys[j])
+		}
+	}
+	return diff
+}
+
+// diffIdentity returns the identity used to match keys in Diff, or an empty
+// string if the key cannot be matched.
+func (k *KeySpec) diffIdentity() string {
+	if k.KeyID != "" {
+		return "kid:" + k.KeyID
+	}
+	thumbprint, err := k.Thumbprint()
+	if err != nil {
+		return ""
+	}
+	return "jkt:" + base64.RawURLEncoding.EncodeToString(thumbprint)
+}
@@ -0,0 +1,134 @@
+package jwk
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/subtle"
+	"math/big"
+
+	"github.com/rakutentech/jwk-go/okp"
+)
+
+// Equal reports whether both KeySpecs contain the same key and the same
+// metadata (kid, alg, use and validity times).
+//
+// Private key material is compared in constant time, so the time taken does
+// not reveal how much of a private key matches. A private key is never equal
+// to its public key; use SamePublicKey to compare keys regardless of their
+// private components.
+func (k *KeySpec) Equal(other *KeySpec) bool {
+	if k == nil || other == nil {
+		return k == other
+	}
+	// Always compare the key material, even when the metadata differs.
+	sameKey := equalKeys(k.Key, other.Key)
+	return k.sameMetadata(other) && sameKey
+}
+
+// SamePublicKey reports whether both KeySpecs contain the same public key,
+// ignoring metadata and private components: an RSA private key and its public
+// key are considered the same. Symmetric keys are compared in constant time.
+func (k *KeySpec) SamePublicKey(other *KeySpec) bool {
+	if k == nil || other == nil {
+		return false
+	}
+	return samePublicKey(k.Key, other.Key)
+}
+
+func (k *KeySpec) sameMetadata(other *KeySpec) bool {
+	return k.KeyID == other.KeyID &&
+		k.Algorithm == other.Algorithm &&
+		k.Use == other.Use &&
+		k.NotBefore.Equal(other.NotBefore) &&
+		k.IssuedAt.Equal(other.IssuedAt) &&
+		k.ExpiresAt.Equal(other.ExpiresAt)
+}
+
+func samePublicKey(a, b interface{}) bool {
+	if keyA, ok := octetKey(a); ok {
+		keyB, ok := octetKey(b)
+		return ok && keyA.Equal(keyB)
+	}
+	switch keyA := publicKey(a).(type) {
+	case *rsa.PublicKey:
+		keyB, ok := publicKey(b).(*rsa.PublicKey)
+		return ok && keyA.Equal(keyB)
+	case *ecdsa.PublicKey:
+		keyB, ok := publicKey(b).(*ecdsa.PublicKey)
+		return ok && keyA.Equal(keyB)
+	case okp.CurveOctetKeyPair:
+		keyB, ok := b.(okp.CurveOctetKeyPair)
+		return ok && keyA.Curve() == keyB.Curve() && bytes.Equal(keyA.PublicKey(), keyB.PublicKey())
+	default:
+		return false
+	}
+}
+
+func equalKeys(a, b interface{}) bool {
+	samePublic := samePublicKey(a, b)
+	switch keyA := a.(type) {
+	case *rsa.PrivateKey:
+		keyB, ok := b.(*rsa.PrivateKey)
+		if !ok {
+			return false
+		}
+		size := (keyA.N.BitLen() + 7) / 8
+		return equalBigInts(keyA.D, keyB.D, size) && samePublic
+	case *ecdsa.PrivateKey:
+		keyB, ok := b.(*ecdsa.PrivateKey)
+		if !ok {
+			return false
+		}
+		size := (keyA.Params().BitSize + 7) / 8
+		return equalBigInts(keyA.D, keyB.D, size) && samePublic
+	case okp.CurveOctetKeyPair:
+		keyB, ok := b.(okp.CurveOctetKeyPair)
+		if !ok || (keyA.PrivateKey() == nil) != (keyB.PrivateKey() == nil) {
+			return false
+		}
+		return subtle.ConstantTimeCompare(keyA.PrivateKey(), keyB.PrivateKey()) == 1 && samePublic
+	case *rsa.PublicKey, *ecdsa.PublicKey, []byte, OctetKey:
+		// Public keys must not be equal to private keys
+		switch b.(type) {
+		case *rsa.PrivateKey, *ecdsa.PrivateKey:
+			return false
+		}
+		return samePublic
+	default:
+		return false
+	}
+}
+
+// equalBigInts compares two private big integers in constant time, after
+// encoding them with a fixed size.
+func equalBigInts(a, b *big.Int, size int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Sign() < 0 || b.Sign() < 0 || a.BitLen() > size*8 || b.BitLen() > size*8 {
+		return a.Cmp(b) == 0
+	}
+	bufA := a.FillBytes(make([]byte, size))
+	bufB := b.FillBytes(make([]byte, size))
+	defer clear(bufA)
+	defer clear(bufB)
+	return subtle.ConstantTimeCompare(bufA, bufB) == 1
+}
+
+// publicKey returns the public key of an RSA or ECDSA private key, or the key
+// itself.
+func publicKey(key interface{}) interface{} {
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return &key.PublicKey
+	case *ecdsa.PrivateKey:
+		return &key.PublicKey
+	default:
+		return key
+	}
+}
+
+func octetKey(key interface{}) (OctetKey, bool) {
+	return (&KeySpec{Key: key}).OctetKey()
+}
@@ -12,7 +12,7 @@ import (
 // KeyType returns the key type based on the key.
 func (k *KeySpec) KeyType() (kty string, curve string, private bool) {
 	switch key := k.Key.(type) {
-	case []byte:
+	case []byte, OctetKey:
 		kty = jwktypes.OctetKey
 		private = true
 	case *rsa.PublicKey:
@@ -23,6 +23,7 @@ func (k *KeySpec) KeyType() (kty string, curve string, private bool) {
 	case *rsa.PrivateKey:
 		kty = jwktypes.RSA
 		curve = strconv.Itoa(key.N.BitLen())
+		private = true
 		return
 	case *ecdsa.PublicKey:
 		kty = jwktypes.EC
@@ -40,6 +41,30 @@ func (k *KeySpec) KeyType() (kty string, curve string, private bool) {
 	return
 }
 
+// KeySize returns the size of the key in bits: the modulus size for RSA keys,
+// the curve size for EC keys, the public key size for OKPs and the key length
+// for symmetric keys. Zero is returned for unsupported keys.
+func (k *KeySpec) KeySize() int {
+	switch key := k.Key.(type) {
+	case []byte:
+		return len(key) * 8
+	case OctetKey:
+		return len(key) * 8
+	case *rsa.PublicKey:
+		return key.N.BitLen()
+	case *rsa.PrivateKey:
+		return key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return key.Params().BitSize
+	case *ecdsa.PrivateKey:
+		return key.Params().BitSize
+	case okp.CurveOctetKeyPair:
+		return len(key.PublicKey()) * 8
+	default:
+		return 0
+	}
+}
+
 func (k *KeySpec) CoerceOkpCurve(curve string) okp.CurveOctetKeyPair {
 	curveOKP, ok := k.Key.(okp.CurveOctetKeyPair)
 	if ok && curveOKP.Curve() == curve {
@@ -0,0 +1,110 @@
+package jwk
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrKeyConflict is returned by KeySpecSet.Merge when two different keys
+// share the same Key ID and MergeSettings asks to fail on conflicts.
+var ErrKeyConflict = errors.New("conflicting keys")
+
+// MergeConflictPolicy specifies how KeySpecSet.Merge resolves conflicts.
+type MergeConflictPolicy int
+
+const (
+	// MergeFail makes Merge fail with ErrKeyConflict
+	MergeFail MergeConflictPolicy = iota
+	// MergeKeepExisting keeps the key which was merged first
+	MergeKeepExisting
+	// MergeReplace replaces the existing key with the key merged last
+	MergeReplace
+)
+
+// MergeSettings specifies how KeySpecSet.Merge resolves conflicts.
+// The zero value fails on all conflicts.
+type MergeSettings struct {
+	// OnConflict is the policy applied to conflicting keys
+	OnConflict MergeConflictPolicy
+
+	// Resolve, when set, is called for every conflict instead of applying
+	// OnConflict. It returns the key to keep in place of existing (nil keeps
+	// existing), or an error to abort the merge.
+	Resolve func(existing, incoming *KeySpec) (*KeySpec, error)
+}
+
+// Merge returns a new KeySpecSet containing the keys of the KeySpecSet followed
+// by the keys of other. Neither set is modified.
+//
+// Keys are identified by their Key ID, or by their SHA-256 thumbprint when
+// they have no Key ID. Keys which are identical (see KeySpec.Equal) to a key
+// which was already merged are dropped. Other keys with the same identity,
+// such as a reused Key ID or the same key with different metadata, are
+// conflicts which are resolved according to settings. Duplicates within the
+// KeySpecSet itself are handled the same way.
+func (ks KeySpecSet) Merge(other KeySpecSet, settings MergeSettings) (KeySpecSet, error) {
+	merged := KeySpecSet{Keys: make([]KeySpec, 0, len(ks.Keys)+len(other.Keys))}
+	positions := make(map[string]int, cap(merged.Keys))
+	for _, keys := range [][]KeySpec{ks.Keys, other.Keys} {
+		for i := range keys {
+			incoming := &keys[i]
+			id := incoming.diffIdentity()
+			pos, ok := positions[id]
+			if id == "" || !ok {
+				if id != "" {
+					positions[id] = len(merged.Keys)
+				}
+				merged.Keys = append(merged.Keys, *incoming)
+				continue
+			}
+			existing := &merged.Keys[pos]
+			if existing.Equal(incoming) {
+				continue
+			}
+			resolved, err := settings.resolve(existing, incoming)
+			if err != nil {
+				return KeySpecSet{}, err
+			}
+			if resolved != nil {
+				merged.Keys[pos] = *resolved
+			}
+		}
+	}
+	return merged, nil
+}
+
+func (settings MergeSettings) resolve(existing, incoming *KeySpec) (*KeySpec, error) {
+	if settings.Resolve != nil {
+		return settings.Resolve(existing, incoming)
+	}
+	switch settings.OnConflict {
+	case MergeKeepExisting:
+		return existing, nil
+	case MergeReplace:
+		return incoming, nil
+	default:
+		if existing.KeyID == "" {
+			return nil, fmt.Errorf("%w: same key with different metadata", ErrKeyConflict)
+		}
+		return nil, fmt.Errorf("%w: key ID %q", ErrKeyConflict, existing.KeyID)
+	}
+}
+
+// Dedupe returns a new KeySpecSet without the keys which have the same SHA-256
+// thumbprint as a previous key, even if their Key ID or metadata differ. Only
+// the first occurrence of each key is kept, so a private key which follows its
+// public key is removed. Keys which have no thumbprint are always kept.
+func (ks KeySpecSet) Dedupe() KeySpecSet {
+	seen := make(map[string]bool, len(ks.Keys))
+	return ks.Filter(func(key *KeySpec) bool {
+		thumbprint, err := key.Thumbprint()
+		if err != nil {
+			return true
+		}
+		if seen[string(thumbprint)] {
+			return false
+		}
+		seen[string(thumbprint)] = true
+		return true
+	})
+}
@@ -30,15 +30,22 @@ func getKeyAlgo(key interface{}, sig bool) string {
 	case okp.CurveOctetKeyPair:
 		return k.Curve()
 	case []byte:
-		if sig {
-			return DefaultHMACSignAlg
-		}
-		return DefaultAESKeyAlg
+		return octetKeyAlgo(k, sig)
+	case OctetKey:
+		return octetKeyAlgo(k, sig)
 	default:
 		return ""
 	}
 }
 
+// octetKeyAlgo derives the algorithm of a symmetric key from its length
+func octetKeyAlgo(key OctetKey, sig bool) string {
+	if sig {
+		return key.SignatureAlgorithm()
+	}
+	return key.KeyWrapAlgorithm()
+}
+
 // NormalizationSettings contains settings for the normalization preformed by
 // KeySpec.Normalize()
 type NormalizationSettings struct {
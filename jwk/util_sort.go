@@ -0,0 +1,79 @@
+package jwk
+
+import (
+	"bytes"
+	"cmp"
+	"slices"
+	"time"
+)
+
+// SortOrder specifies the order used by KeySpecSet.Sort.
+type SortOrder int
+
+const (
+	// SortByKeyID sorts keys by Key ID
+	SortByKeyID SortOrder = iota
+	// SortByExpiry sorts keys by expiration time, keys expiring first
+	// first. Keys which never expire come last.
+	SortByExpiry
+	// SortByIssuedAt sorts keys by creation time, oldest keys first.
+	// Keys without a creation time come first.
+	SortByIssuedAt
+)
+
+// Sort returns a new KeySpecSet with the keys sorted in the specified order.
+// The KeySpecSet itself is not modified.
+//
+// The order is deterministic: keys which are equal according to order are
+// sorted by Key ID, then by SHA-256 thumbprint.
+func (ks KeySpecSet) Sort(order SortOrder) KeySpecSet {
+	type sortKey struct {
+		spec       KeySpec
+		thumbprint []byte
+	}
+	keys := make([]sortKey, len(ks.Keys))
+	for i, k := range ks.Keys {
+		// Keys without thumbprints get nil, which sorts first
+		thumbprint, _ := k.Thumbprint()
+		keys[i] = sortKey{k, thumbprint}
+	}
+
+	slices.SortStableFunc(keys, func(a, b sortKey) int {
+		var c int
+		switch order {
+		case SortByExpiry:
+			c = compareTimes(a.spec.ExpiresAt, b.spec.ExpiresAt, true)
+		case SortByIssuedAt:
+			c = compareTimes(a.spec.IssuedAt, b.spec.IssuedAt, false)
+		}
+		if c != 0 {
+			return c
+		}
+		if c = cmp.Compare(a.spec.KeyID, b.spec.KeyID); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.thumbprint, b.thumbprint)
+	})
+
+	sorted := KeySpecSet{Keys: make([]KeySpec, len(keys))}
+	for i, k := range keys {
+		sorted.Keys[i] = k.spec
+	}
+	return sorted
+}
+
+// compareTimes compares two times, sorting the zero time last when zeroLast is
+// true and first otherwise.
+func compareTimes(a, b time.Time, zeroLast bool) int {
+	switch {
+	case a.IsZero() && b.IsZero():
+		return 0
+	case a.IsZero() != b.IsZero():
+		if a.IsZero() == zeroLast {
+			return 1
+		}
+		return -1
+	default:
+		return a.Compare(b)
+	}
+}
@@ -0,0 +1,52 @@
+package jwk
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrDuplicateKeyID is returned (wrapped) by KeySpecSet.Validate when
+	// several keys share the same Key ID
+	ErrDuplicateKeyID = errors.New("duplicate key ID")
+
+	// ErrDuplicateKey is returned (wrapped) by KeySpecSet.Validate when the
+	// same key is published more than once
+	ErrDuplicateKey = errors.New("duplicate key")
+)
+
+// Validate checks that all the Key IDs in the KeySpecSet are unique, and that
+// no key is published twice (i.e. no keys share the same SHA-256 thumbprint,
+// which includes a private key and its public key). Keys without a Key ID are
+// allowed.
+//
+// All problems are reported, joined with errors.Join, and can be checked with
+// errors.Is(err, ErrDuplicateKeyID) and errors.Is(err, ErrDuplicateKey).
+func (ks KeySpecSet) Validate() error {
+	var errs []error
+	keyIDs := make(map[string]int, len(ks.Keys))
+	thumbprints := make(map[string]int, len(ks.Keys))
+	for i := range ks.Keys {
+		k := &ks.Keys[i]
+		if k.KeyID != "" {
+			if first, ok := keyIDs[k.KeyID]; ok {
+				errs = append(errs, fmt.Errorf("%w %q: keys %d and %d", ErrDuplicateKeyID, k.KeyID, first, i))
+			} else {
+				keyIDs[k.KeyID] = i
+			}
+		}
+		thumbprint, err := k.Thumbprint()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("key %d: %w", i, err))
+			continue
+		}
+		if first, ok := thumbprints[string(thumbprint)]; ok {
+			errs = append(errs, fmt.Errorf("%w %s: keys %d and %d", ErrDuplicateKey,
+				base64.RawURLEncoding.EncodeToString(thumbprint), first, i))
+		} else {
+			thumbprints[string(thumbprint)] = i
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,24 @@
+package jwk
+
+import "time"
+
+// ValidAt filters the KeySpecSet and returns only KeySpecs which are valid at
+// time t.
+//
+// See KeySpec.IsValidAt for more information.
+func (ks KeySpecSet) ValidAt(t time.Time) KeySpecSet {
+	return ks.Filter(func(key *KeySpec) bool {
+		return key.IsValidAt(t)
+	})
+}
+
+// PruneExpired returns a KeySpecSet without the KeySpecs which have expired
+// at time t.
+//
+// Unlike ValidAt, keys which are not valid yet are kept, so a set containing
+// pre-published keys for an upcoming rotation can be pruned safely.
+func (ks KeySpecSet) PruneExpired(t time.Time) KeySpecSet {
+	return ks.Filter(func(key *KeySpec) bool {
+		return !key.IsExpiredAt(t)
+	})
+}
@@ -0,0 +1,332 @@
+package jwk
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rakutentech/jwk-go/okp"
+)
+
+// WrappedContentType is the 'cty' header value of JWEs containing a JWK
+// (RFC 7517 section 7)
+const WrappedContentType = "jwk+json"
+
+// ErrDecryptionFailed is returned by ParseWrapped() when the JWE cannot be
+// decrypted with the recipient key, or has been tampered with.
+var ErrDecryptionFailed = errors.New("JWE decryption failed")
+
+// keyManagementAlgorithms contains the JWE 'alg' values defined by RFC 7518
+// section 4.1. Only RSA-OAEP-256, AES Key Wrap and ECDH-ES with AES Key Wrap
+// are supported for wrapped keys.
+var keyManagementAlgorithms = map[string]bool{
+	"RSA1_5": true, "RSA-OAEP": true, "RSA-OAEP-256": true,
+	"A128KW": true, "A192KW": true, "A256KW": true,
+	"dir": true, "ECDH-ES": true,
+	"ECDH-ES+A128KW": true, "ECDH-ES+A192KW": true, "ECDH-ES+A256KW": true,
+	"A128GCMKW": true, "A192GCMKW": true, "A256GCMKW": true,
+	"PBES2-HS256+A128KW": true, "PBES2-HS384+A192KW": true, "PBES2-HS512+A256KW": true,
+}
+
+// aesKeyWrapAlgorithms contains the AES Key Wrap algorithms (RFC 3394)
+var aesKeyWrapAlgorithms = map[string]bool{"A128KW": true, "A192KW": true, "A256KW": true}
+
+// wrappedHeader is the protected header of the JWEs created by
+// MarshalWrapped()
+type wrappedHeader struct {
+	Alg  string          `json:"alg"`
+	Enc  string          `json:"enc"`
+	Cty  string          `json:"cty,omitempty"`
+	Kid  string          `json:"kid,omitempty"`
+	Epk  json.RawMessage `json:"epk,omitempty"`
+	Apu  string          `json:"apu,omitempty"`
+	Apv  string          `json:"apv,omitempty"`
+	Zip  string          `json:"zip,omitempty"`
+	Crit []string        `json:"crit,omitempty"`
+}
+
+// MarshalWrapped serializes the KeySpec, including its private key, to JSON
+// and encrypts it for recipient as a JWE in compact serialization, with
+// 'cty' set to "jwk+json" (RFC 7517 section 7).
+//
+// The key management algorithm is recipient.Algorithm if it is a JWE key
+// management algorithm, or else RSA-OAEP-256 for RSA keys, ECDH-ES+A128KW for EC and X25519 keys and A*KW
+// (by key length) for symmetric keys. The content is encrypted with
+// DefaultContentEncryptionAlgorithm.
+func (k *KeySpec) MarshalWrapped(recipient *KeySpec) ([]byte, error) {
+	if recipient.Use != "" && recipient.Use != "enc" {
+		return nil, fmt.Errorf("recipient key with use '%s' cannot be used for encryption", recipient.Use)
+	}
+	header := wrappedHeader{
+		Alg: recipient.Algorithm,
+		Enc: DefaultContentEncryptionAlgorithm,
+		Cty: WrappedContentType,
+		Kid: recipient.KeyID,
+	}
+	if !keyManagementAlgorithms[header.Alg] {
+		// Not set, or set to a signature algorithm or a curve name
+		header.Alg = wrappingAlgorithm(recipient.Key)
+	}
+
+	cek, err := GenerateOctetKey(header.Enc, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	defer cek.Destroy()
+	encryptedKey, epk, err := encryptCEK(recipient, header.Alg, header.Enc, cek)
+	if err != nil {
+		return nil, err
+	}
+	header.Epk = epk
+
+	plaintext, err := k.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	defer clear(plaintext)
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+	encodedHeader := base64.RawURLEncoding.EncodeToString(headerJSON)
+	sealed := gcm.Seal(nil, iv, plaintext, []byte(encodedHeader))
+	ciphertext, tag := sealed[:len(plaintext)], sealed[len(plaintext):]
+
+	parts := []string{encodedHeader}
+	for _, part := range [][]byte{encryptedKey, iv, ciphertext, tag} {
+		parts = append(parts, base64.RawURLEncoding.EncodeToString(part))
+	}
+	return []byte(strings.Join(parts, ".")), nil
+}
+
+// ParseWrapped decrypts a JWE created by KeySpec.MarshalWrapped() with the
+// private key of the recipient, and parses the JWK it contains. The 'use' of
+// recipientPrivate must be empty or "enc", and its 'alg' empty or equal to the
+// key management algorithm of the JWE.
+// ErrDecryptionFailed is returned if the JWE cannot be decrypted.
+func ParseWrapped(data []byte, recipientPrivate *KeySpec) (*KeySpec, error) {
+	parts := bytes.Split(data, []byte("."))
+	if len(parts) != 5 {
+		return nil, errors.New("malformed JWE: compact serialization must have 5 parts")
+	}
+	var decoded [5][]byte
+	for i, part := range parts {
+		var err error
+		decoded[i], err = base64.RawURLEncoding.AppendDecode(nil, part)
+		if err != nil {
+			return nil, fmt.Errorf("malformed JWE: %w", err)
+		}
+	}
+	encryptedKey, iv, ciphertext, tag := decoded[1], decoded[2], decoded[3], decoded[4]
+
+	var header wrappedHeader
+	if err := json.Unmarshal(decoded[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed JWE header: %w", err)
+	}
+	switch {
+	case header.Cty != WrappedContentType && header.Cty != "application/"+WrappedContentType:
+		return nil, fmt.Errorf("JWE content type must be %s", WrappedContentType)
+	case header.Zip != "" || len(header.Crit) > 0:
+		return nil, errors.New("unsupported JWE header parameters")
+	case header.Kid != "" && recipientPrivate.KeyID != "" && header.Kid != recipientPrivate.KeyID:
+		return nil, fmt.Errorf("JWE is encrypted for key %q", header.Kid)
+	case header.Enc != "A128GCM" && header.Enc != "A192GCM" && header.Enc != "A256GCM":
+		return nil, fmt.Errorf("unsupported content encryption algorithm: %s", header.Enc)
+	}
+
+	cekSize, err := OctetKeySize(header.Enc)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := decryptCEK(recipientPrivate, &header, encryptedKey)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(cek)
+	if len(cek) != cekSize {
+		return nil, ErrDecryptionFailed
+	}
+
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != gcm.NonceSize() || len(tag) != gcm.Overhead() {
+		return nil, ErrDecryptionFailed
+	}
+	plaintext, err := gcm.Open(nil, iv, append(ciphertext, tag...), parts[0])
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	defer clear(plaintext)
+	return ParseBytes(plaintext)
+}
+
+// wrappingAlgorithm returns the default key management algorithm for a
+// recipient key
+func wrappingAlgorithm(key interface{}) string {
+	switch key := key.(type) {
+	case okp.CurveOctetKeyPair:
+		return DefaultECKeyAlgWithKeyWrap
+	case []byte:
+		return OctetKey(key).KeyWrapAlgorithm()
+	case OctetKey:
+		return key.KeyWrapAlgorithm()
+	default:
+		return getKeyAlgo(key, false)
+	}
+}
+
+// encryptCEK encrypts the content encryption key for recipient, and returns
+// the encrypted key and the ephemeral public key for ECDH-ES
+func encryptCEK(recipient *KeySpec, alg, enc string, cek []byte) ([]byte, json.RawMessage, error) {
+	switch {
+	case alg == "RSA-OAEP-256":
+		var publicKey *rsa.PublicKey
+		switch key := recipient.Key.(type) {
+		case *rsa.PublicKey:
+			publicKey = key
+		case *rsa.PrivateKey:
+			publicKey = &key.PublicKey
+		default:
+			return nil, nil, fmt.Errorf("%s requires an RSA key", alg)
+		}
+		encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, cek, nil)
+		return encryptedKey, nil, err
+
+	case ecdhKeyWrapAlgorithms[alg] != "":
+		ephemeral, err := generateEphemeralKey(recipient.Key)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer ephemeral.Destroy()
+		epk, err := ephemeral.MarshalPublicJSON()
+		if err != nil {
+			return nil, nil, err
+		}
+		kek, err := ephemeral.DeriveECDHESKey(recipient, alg, enc, nil, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer clear(kek)
+		encryptedKey, err := AESKeyWrap(kek, cek)
+		return encryptedKey, epk, err
+
+	case aesKeyWrapAlgorithms[alg]:
+		wrapped, err := recipient.WrapKey(alg, cek, rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		return wrapped.EncryptedKey, nil, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported key management algorithm for wrapped keys: %s", alg)
+	}
+}
+
+// decryptCEK decrypts the content encryption key with the private key of the
+// recipient
+func decryptCEK(recipient *KeySpec, header *wrappedHeader, encryptedKey []byte) ([]byte, error) {
+	if recipient.Use != "" && recipient.Use != "enc" {
+		return nil, fmt.Errorf("recipient key with use '%s' cannot be used for decryption", recipient.Use)
+	}
+	if recipient.Algorithm != "" && recipient.Algorithm != header.Alg {
+		return nil, fmt.Errorf("key algorithm %s cannot be used for %s", recipient.Algorithm, header.Alg)
+	}
+	alg := header.Alg
+	switch {
+	case alg == "RSA-OAEP-256":
+		privateKey, ok := recipient.Key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s requires an RSA private key", alg)
+		}
+		cek, err := rsa.DecryptOAEP(sha256.New(), nil, privateKey, encryptedKey, nil)
+		if err != nil {
+			return nil, ErrDecryptionFailed
+		}
+		return cek, nil
+
+	case ecdhKeyWrapAlgorithms[alg] != "":
+		if len(header.Epk) == 0 {
+			return nil, errors.New("malformed JWE header: missing 'epk'")
+		}
+		epk, err := ParseBytes(header.Epk)
+		if err != nil {
+			return nil, fmt.Errorf("malformed JWE header: epk: %w", err)
+		}
+		if !epk.IsPublic() {
+			return nil, errors.New("malformed JWE header: epk must be a public key")
+		}
+		apu, err := base64.RawURLEncoding.DecodeString(header.Apu)
+		if err != nil {
+			return nil, fmt.Errorf("malformed JWE header: apu: %w", err)
+		}
+		apv, err := base64.RawURLEncoding.DecodeString(header.Apv)
+		if err != nil {
+			return nil, fmt.Errorf("malformed JWE header: apv: %w", err)
+		}
+		kek, err := recipient.DeriveECDHESKey(epk, alg, header.Enc, apu, apv)
+		if err != nil {
+			return nil, err
+		}
+		defer clear(kek)
+		return unwrapCEK(AESKeyUnwrap(kek, encryptedKey))
+
+	case aesKeyWrapAlgorithms[alg]:
+		return unwrapCEK(recipient.UnwrapKey(&WrappedKey{Algorithm: alg, EncryptedKey: encryptedKey}))
+
+	default:
+		return nil, fmt.Errorf("unsupported key management algorithm for wrapped keys: %s", alg)
+	}
+}
+
+// unwrapCEK reports integrity check failures as ErrDecryptionFailed, so
+// callers cannot tell which step of the decryption failed
+func unwrapCEK(cek []byte, err error) ([]byte, error) {
+	if errors.Is(err, ErrKeyUnwrapFailed) {
+		return nil, ErrDecryptionFailed
+	}
+	return cek, err
+}
+
+// generateEphemeralKey generates an ECDH-ES ephemeral key on the curve of the
+// recipient key
+func generateEphemeralKey(recipient interface{}) (*KeySpec, error) {
+	switch key := recipient.(type) {
+	case *ecdsa.PublicKey:
+		return generateEphemeralKey(&ecdsa.PrivateKey{PublicKey: *key})
+	case *ecdsa.PrivateKey:
+		ephemeral, err := ecdsa.GenerateKey(key.Curve, rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewSpec(ephemeral), nil
+	case okp.CurveOctetKeyPair:
+		if key.Curve() != "X25519" {
+			return nil, fmt.Errorf("curve %s does not support ECDH", key.Curve())
+		}
+		ephemeral, err := okp.GenerateCurve25519(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewSpec(ephemeral), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type for ECDH: %T", recipient)
+	}
+}
@@ -0,0 +1,178 @@
+package jwk
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rakutentech/jwk-go/internal/testutils"
+	"github.com/rakutentech/jwk-go/okp"
+)
+
+// wrappedHeaderOf decodes the protected header of a compact JWE
+func wrappedHeaderOf(data []byte) map[string]interface{} {
+	encoded, _, _ := bytes.Cut(data, []byte("."))
+	var header map[string]interface{}
+	Expect(json.Unmarshal(testutils.Must(base64.RawURLEncoding.DecodeString(string(encoded))), &header)).To(Succeed())
+	return header
+}
+
+// normalizedSpec creates a normalized KeySpec for key
+func normalizedSpec(key interface{}) *KeySpec {
+	k := NewSpec(key)
+	testutils.PanicOnError(k.Normalize(NormalizationSettings{}))
+	return k
+}
+
+var _ = Describe("MarshalWrapped", func() {
+	serviceKey := NewSpecWithID("service", testutils.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader)))
+	serviceKey.Use = "sig"
+	rsaRecipient := NewSpecWithID("rsa", testutils.Must(rsa.GenerateKey(rand.Reader, 2048)))
+	ecRecipient := NewSpecWithID("ec", testutils.Must(ecdsa.GenerateKey(elliptic.P384(), rand.Reader)))
+
+	DescribeTable("Should wrap and unwrap keys",
+		func(recipient *KeySpec, alg string) {
+			publicRecipient := recipient
+			if !recipient.IsKeyType("oct") {
+				publicRecipient = testutils.Must(recipient.PublicOnly())
+			}
+			wrapped := testutils.Must(serviceKey.MarshalWrapped(publicRecipient))
+			header := wrappedHeaderOf(wrapped)
+			Expect(header["alg"]).To(Equal(alg))
+			Expect(header["enc"]).To(Equal(DefaultContentEncryptionAlgorithm))
+			Expect(header["cty"]).To(Equal("jwk+json"))
+			ciphertext := testutils.Must(base64.RawURLEncoding.DecodeString(strings.Split(string(wrapped), ".")[3]))
+			Expect(string(ciphertext)).NotTo(ContainSubstring(`"d":`))
+
+			k := testutils.Must(ParseWrapped(wrapped, recipient))
+			Expect(k.Equal(serviceKey)).To(BeTrue())
+			Expect(k.IsPublic()).To(BeFalse())
+		},
+		Entry("RSA-OAEP-256", rsaRecipient, "RSA-OAEP-256"),
+		Entry("ECDH-ES+A128KW with P-384", ecRecipient, "ECDH-ES+A128KW"),
+		Entry("ECDH-ES+A256KW", &KeySpec{
+			Key:       testutils.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader)),
+			Algorithm: "ECDH-ES+A256KW",
+		}, "ECDH-ES+A256KW"),
+		Entry("ECDH-ES+A128KW with X25519", NewSpec(testutils.Must(okp.GenerateCurve25519(rand.Reader))), "ECDH-ES+A128KW"),
+		Entry("ECDH-ES+A128KW with a normalized X25519 key",
+			normalizedSpec(testutils.Must(okp.GenerateCurve25519(rand.Reader))), "ECDH-ES+A128KW"),
+		Entry("A128KW", NewSpec(randomBytes(16)), "A128KW"),
+		Entry("A256KW", NewSpec(testutils.Must(GenerateOctetKey("A256KW", rand.Reader))), "A256KW"),
+	)
+
+	It("Should include the ephemeral public key and the recipient Key ID", func() {
+		header := wrappedHeaderOf(testutils.Must(serviceKey.MarshalWrapped(testutils.Must(ecRecipient.PublicOnly()))))
+		Expect(header["kid"]).To(Equal("ec"))
+		epk := header["epk"].(map[string]interface{})
+		Expect(epk["crv"]).To(Equal("P-384"))
+		Expect(epk).NotTo(HaveKey("d"))
+	})
+
+	It("Should reject unsupported recipients", func() {
+		_, err := serviceKey.MarshalWrapped(&KeySpec{Key: randomBytes(32), Algorithm: "A256GCMKW"})
+		Expect(err).To(MatchError("unsupported key management algorithm for wrapped keys: A256GCMKW"))
+		_, err = serviceKey.MarshalWrapped(&KeySpec{Key: rsaRecipient.Key, Use: "sig"})
+		Expect(err).To(MatchError("recipient key with use 'sig' cannot be used for encryption"))
+		_, err = serviceKey.MarshalWrapped(NewSpec(testutils.Must(okp.GenerateEd25519(rand.Reader))))
+		Expect(err).To(MatchError("curve Ed25519 does not support ECDH"))
+		_, err = serviceKey.MarshalWrapped(&KeySpec{Key: ecRecipient.Key, Algorithm: "RSA-OAEP-256"})
+		Expect(err).To(MatchError("RSA-OAEP-256 requires an RSA key"))
+		_, err = serviceKey.MarshalWrapped(&KeySpec{Key: ecRecipient.Key, Algorithm: "ECDH-ES"})
+		Expect(err).To(MatchError("unsupported key management algorithm for wrapped keys: ECDH-ES"))
+	})
+})
+
+var _ = Describe("ParseWrapped", func() {
+	serviceKey := NewSpec(randomBytes(32))
+	recipient := NewSpecWithID("recipient", testutils.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader)))
+	wrapped := testutils.Must(serviceKey.MarshalWrapped(recipient))
+
+	It("Should reject other recipients", func() {
+		other := NewSpec(testutils.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader)))
+		_, err := ParseWrapped(wrapped, other)
+		Expect(err).To(Equal(ErrDecryptionFailed))
+
+		other.KeyID = "other"
+		_, err = ParseWrapped(wrapped, other)
+		Expect(err).To(MatchError(`JWE is encrypted for key "recipient"`))
+
+		_, err = ParseWrapped(wrapped, testutils.Must(recipient.PublicOnly()))
+		Expect(err).To(MatchError("a private key is required for ECDH"))
+	})
+
+	It("Should reject recipient keys which are not meant for key management", func() {
+		signing := *recipient.Clone()
+		signing.Use = "sig"
+		_, err := ParseWrapped(wrapped, &signing)
+		Expect(err).To(MatchError("recipient key with use 'sig' cannot be used for decryption"))
+
+		signing.Use, signing.Algorithm = "", "ES256"
+		_, err = ParseWrapped(wrapped, &signing)
+		Expect(err).To(MatchError("key algorithm ES256 cannot be used for ECDH-ES+A128KW"))
+
+		rsaRecipient := NewSpec(testutils.Must(rsa.GenerateKey(rand.Reader, 2048)))
+		rsaWrapped := testutils.Must(serviceKey.MarshalWrapped(rsaRecipient))
+		rsaRecipient.Algorithm = "RS256"
+		_, err = ParseWrapped(rsaWrapped, rsaRecipient)
+		Expect(err).To(MatchError("key algorithm RS256 cannot be used for RSA-OAEP-256"))
+		rsaRecipient.Algorithm = "RSA-OAEP-256"
+		Expect(testutils.Must(ParseWrapped(rsaWrapped, rsaRecipient)).Equal(serviceKey)).To(BeTrue())
+	})
+
+	It("Should detect tampering", func() {
+		parts := strings.Split(string(wrapped), ".")
+		for i := 1; i < 5; i++ {
+			tampered := append([]string{}, parts...)
+			decoded := testutils.Must(base64.RawURLEncoding.DecodeString(tampered[i]))
+			decoded[0] ^= 1
+			tampered[i] = base64.RawURLEncoding.EncodeToString(decoded)
+			_, err := ParseWrapped([]byte(strings.Join(tampered, ".")), recipient)
+			Expect(errors.Is(err, ErrDecryptionFailed)).To(BeTrue(), "part %d", i)
+		}
+
+		header := wrappedHeaderOf(wrapped)
+		header["kid"] = "recipient "
+		tampered := append([]string{base64.RawURLEncoding.EncodeToString(testutils.Must(json.Marshal(header)))}, parts[1:]...)
+		_, err := ParseWrapped([]byte(strings.Join(tampered, ".")), NewSpec(recipient.Key))
+		Expect(err).To(Equal(ErrDecryptionFailed))
+	})
+
+	It("Should reject malformed JWEs", func() {
+		parts := strings.Split(string(wrapped), ".")
+		withHeader := func(name string, value interface{}) string {
+			header := wrappedHeaderOf(wrapped)
+			header[name] = value
+			if value == nil {
+				delete(header, name)
+			}
+			encoded := base64.RawURLEncoding.EncodeToString(testutils.Must(json.Marshal(header)))
+			return strings.Join(append([]string{encoded}, parts[1:]...), ".")
+		}
+
+		for data, expected := range map[string]string{
+			"a.b.c":                            "malformed JWE: compact serialization must have 5 parts",
+			"a.b.c.d.!":                        "malformed JWE: illegal base64 data at input byte 0",
+			"e30.AA.AA.AA.AA":                  "JWE content type must be jwk+json",
+			withHeader("zip", "DEF"):           "unsupported JWE header parameters",
+			withHeader("enc", "A128CBC-HS256"): "unsupported content encryption algorithm: A128CBC-HS256",
+			withHeader("enc", "A128KW"):        "unsupported content encryption algorithm: A128KW",
+			withHeader("alg", "A128GCMKW"):     "unsupported key management algorithm for wrapped keys: A128GCMKW",
+			withHeader("alg", "dir"):           "unsupported key management algorithm for wrapped keys: dir",
+			withHeader("epk", nil):             "malformed JWE header: missing 'epk'",
+			withHeader("epk", testutils.Must(recipient.ToJWK())): "malformed JWE header: epk must be a public key",
+		} {
+			_, err := ParseWrapped([]byte(data), recipient)
+			Expect(err).To(MatchError(expected), data)
+		}
+	})
+})
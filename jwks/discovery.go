@@ -0,0 +1,154 @@
+package jwks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// OpenIDConfigurationPath is the well-known path of OpenID Connect
+	// Discovery documents, appended to the issuer URL
+	OpenIDConfigurationPath = "/.well-known/openid-configuration"
+	// OAuthAuthorizationServerPath is the well-known path of OAuth 2.0
+	// Authorization Server Metadata (RFC 8414), inserted between the host and
+	// the path of the issuer URL
+	OAuthAuthorizationServerPath = "/.well-known/oauth-authorization-server"
+)
+
+// ErrNotFound is returned by Discover when the issuer publishes no metadata
+var ErrNotFound = errors.New("metadata not found")
+
+// ProviderMetadata contains the members of OpenID Provider Metadata and
+// OAuth 2.0 Authorization Server Metadata used to resolve keys.
+type ProviderMetadata struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// DiscoveryMode specifies which metadata documents Discover fetches.
+type DiscoveryMode int
+
+const (
+	// DiscoverAny tries OpenID Connect Discovery first, then OAuth 2.0
+	// Authorization Server Metadata
+	DiscoverAny DiscoveryMode = iota
+	// DiscoverOpenID only uses OpenID Connect Discovery
+	DiscoverOpenID
+	// DiscoverOAuth only uses OAuth 2.0 Authorization Server Metadata
+	DiscoverOAuth
+)
+
+// DiscoverySettings controls Discover and NewIssuerSource.
+// The zero value is ready to use.
+type DiscoverySettings struct {
+	// Mode specifies which metadata documents are used
+	Mode DiscoveryMode
+
+	// Client is the HTTP client used for all requests. Defaults to
+	// http.DefaultClient.
+	Client *http.Client
+}
+
+// Discover fetches the metadata of an issuer, and checks that the 'issuer'
+// member is identical to issuer and that a 'jwks_uri' is published.
+// The 'jwks_uri' must use HTTPS, unless the issuer itself does not.
+func Discover(ctx context.Context, issuer string, settings DiscoverySettings) (*ProviderMetadata, error) {
+	issuerURL, err := url.Parse(issuer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid issuer: %w", err)
+	}
+	if (issuerURL.Scheme != "https" && issuerURL.Scheme != "http") || issuerURL.Host == "" ||
+		issuerURL.RawQuery != "" || issuerURL.Fragment != "" {
+		return nil, fmt.Errorf("invalid issuer %q: must be an HTTP(S) URL without query or fragment", issuer)
+	}
+
+	var metadataURLs []string
+	if settings.Mode != DiscoverOAuth {
+		metadataURLs = append(metadataURLs, strings.TrimSuffix(issuer, "/")+OpenIDConfigurationPath)
+	}
+	if settings.Mode != DiscoverOpenID {
+		u := *issuerURL
+		u.Path = OAuthAuthorizationServerPath + strings.TrimSuffix(issuerURL.Path, "/")
+		u.RawPath = ""
+		metadataURLs = append(metadataURLs, u.String())
+	}
+
+	for _, metadataURL := range metadataURLs {
+		metadata, err := fetchMetadata(ctx, settings.Client, metadataURL)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := metadata.validate(issuer, issuerURL); err != nil {
+			return nil, fmt.Errorf("%s: %w", metadataURL, err)
+		}
+		return metadata, nil
+	}
+	return nil, fmt.Errorf("%w for issuer %q", ErrNotFound, issuer)
+}
+
+// NewIssuerSource discovers the 'jwks_uri' of an issuer and returns a
+// RemoteSource for it, after fetching the keys once.
+func NewIssuerSource(ctx context.Context, issuer string, settings DiscoverySettings) (*RemoteSource, error) {
+	metadata, err := Discover(ctx, issuer, settings)
+	if err != nil {
+		return nil, err
+	}
+	source := NewRemoteSource(metadata.JWKSURI)
+	source.Client = settings.Client
+	if _, err := source.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+func fetchMetadata(ctx context.Context, client *http.Client, metadataURL string) (*ProviderMetadata, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient(client).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching metadata: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, fmt.Errorf("fetching metadata from %s: unexpected status %s", metadataURL, resp.Status)
+	}
+	var metadata ProviderMetadata
+	if err := readJSON(resp, &metadata); err != nil {
+		return nil, fmt.Errorf("fetching metadata from %s: %w", metadataURL, err)
+	}
+	return &metadata, nil
+}
+
+func (m *ProviderMetadata) validate(issuer string, issuerURL *url.URL) error {
+	// The issuer must be identical, without any normalization
+	// (OpenID Connect Discovery section 4.3, RFC 8414 section 3.3)
+	if m.Issuer != issuer {
+		return fmt.Errorf("issuer mismatch: expected %q but got %q", issuer, m.Issuer)
+	}
+	if m.JWKSURI == "" {
+		return errors.New("missing jwks_uri")
+	}
+	jwksURL, err := url.Parse(m.JWKSURI)
+	if err != nil || !jwksURL.IsAbs() || jwksURL.Host == "" {
+		return fmt.Errorf("invalid jwks_uri %q", m.JWKSURI)
+	}
+	if jwksURL.Scheme != "https" && (jwksURL.Scheme != "http" || issuerURL.Scheme != "http") {
+		return fmt.Errorf("jwks_uri %q must use HTTPS", m.JWKSURI)
+	}
+	return nil
+}
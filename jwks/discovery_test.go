@@ -0,0 +1,238 @@
+package jwks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rakutentech/jwk-go/internal/testutils"
+	"github.com/rakutentech/jwk-go/jwk"
+	"github.com/rakutentech/jwk-go/okp"
+)
+
+// testProvider is an identity provider serving its metadata and keys
+type testProvider struct {
+	*httptest.Server
+	keys     *jwk.SyncKeySet
+	metadata map[string]interface{}
+	// metadataPath is the path the metadata is served at
+	metadataPath string
+	// jwksRequests counts key set requests which were not conditional hits
+	jwksRequests atomic.Int32
+	fail         atomic.Bool
+}
+
+func newTestProvider(issuerPath, metadataPath string) *testProvider {
+	p := &testProvider{
+		keys: jwk.NewSyncKeySet(jwk.KeySpecSet{Keys: []jwk.KeySpec{newTestKey("a")}}),
+	}
+	handler := NewHandler(SyncSource(p.keys))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == p.metadataPath:
+			_ = json.NewEncoder(w).Encode(p.metadata)
+		case r.URL.Path == "/jwks.json" && p.fail.Load():
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		case r.URL.Path == "/jwks.json":
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+			if rec.Code == http.StatusOK {
+				p.jwksRequests.Add(1)
+			}
+			for name, values := range rec.Header() {
+				w.Header()[name] = values
+			}
+			w.WriteHeader(rec.Code)
+			_, _ = w.Write(rec.Body.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	p.Server = httptest.NewServer(mux)
+	p.metadataPath = metadataPath
+	p.metadata = map[string]interface{}{
+		"issuer":   p.URL + issuerPath,
+		"jwks_uri": p.URL + "/jwks.json",
+	}
+	return p
+}
+
+func newTestKey(kid string) jwk.KeySpec {
+	return jwk.KeySpec{Key: testutils.Must(okp.GenerateEd25519(rand.Reader)), KeyID: kid}
+}
+
+var _ = Describe("Discovery", func() {
+	ctx := context.Background()
+
+	It("Should use OpenID Connect Discovery", func() {
+		p := newTestProvider("/tenant", "/tenant/.well-known/openid-configuration")
+		defer p.Close()
+
+		metadata, err := Discover(ctx, p.URL+"/tenant", DiscoverySettings{})
+		Expect(err).To(Succeed())
+		Expect(metadata.JWKSURI).To(Equal(p.URL + "/jwks.json"))
+
+		_, err = Discover(ctx, p.URL+"/tenant", DiscoverySettings{Mode: DiscoverOAuth})
+		Expect(errors.Is(err, ErrNotFound)).To(BeTrue())
+	})
+
+	It("Should use OAuth Authorization Server Metadata", func() {
+		p := newTestProvider("/tenant", "/.well-known/oauth-authorization-server/tenant")
+		defer p.Close()
+
+		metadata, err := Discover(ctx, p.URL+"/tenant", DiscoverySettings{})
+		Expect(err).To(Succeed())
+		Expect(metadata.Issuer).To(Equal(p.URL + "/tenant"))
+
+		_, err = Discover(ctx, p.URL+"/tenant", DiscoverySettings{Mode: DiscoverOpenID})
+		Expect(errors.Is(err, ErrNotFound)).To(BeTrue())
+	})
+
+	It("Should validate the metadata", func() {
+		p := newTestProvider("", "/.well-known/openid-configuration")
+		defer p.Close()
+
+		// The issuer must be identical
+		_, err := Discover(ctx, p.URL+"/", DiscoverySettings{})
+		Expect(err).To(MatchError(ContainSubstring("issuer mismatch")))
+
+		p.metadata["jwks_uri"] = ""
+		_, err = Discover(ctx, p.URL, DiscoverySettings{})
+		Expect(err).To(MatchError(ContainSubstring("missing jwks_uri")))
+
+		p.metadata["jwks_uri"] = "/jwks.json"
+		_, err = Discover(ctx, p.URL, DiscoverySettings{})
+		Expect(err).To(MatchError(ContainSubstring("invalid jwks_uri")))
+
+		_, err = Discover(ctx, "ftp://example.com", DiscoverySettings{})
+		Expect(err).To(MatchError(ContainSubstring("invalid issuer")))
+	})
+
+	It("Should require HTTPS for the keys of HTTPS issuers", func() {
+		p := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewEncoder(w).Encode(ProviderMetadata{Issuer: "https://" + r.Host, JWKSURI: "http://" + r.Host + "/jwks.json"})
+		}))
+		defer p.Close()
+		_, err := Discover(ctx, p.URL, DiscoverySettings{Client: p.Client()})
+		Expect(err).To(MatchError(ContainSubstring("must use HTTPS")))
+	})
+
+	It("Should return a refreshable key set", func() {
+		p := newTestProvider("", "/.well-known/openid-configuration")
+		defer p.Close()
+
+		source, err := NewIssuerSource(ctx, p.URL, DiscoverySettings{})
+		Expect(err).To(Succeed())
+		now := time.Now()
+		source.Now = func() time.Time { return now }
+		Expect(p.jwksRequests.Load()).To(BeEquivalentTo(1))
+
+		ks := testutils.Must(source.KeySet(ctx))
+		Expect(ks.Keys).To(HaveLen(1))
+		Expect(ks.Keys[0].KeyID).To(Equal("a"))
+
+		// Refreshing is rate limited
+		p.keys.Store(jwk.KeySpecSet{Keys: []jwk.KeySpec{newTestKey("b")}})
+		ks = testutils.Must(source.Refresh(ctx))
+		Expect(ks.Keys[0].KeyID).To(Equal("a"))
+
+		now = now.Add(DefaultMinRefreshInterval)
+		ks = testutils.Must(source.Refresh(ctx))
+		Expect(ks.Keys[0].KeyID).To(Equal("b"))
+		Expect(p.jwksRequests.Load()).To(BeEquivalentTo(2))
+
+		// Unchanged keys are not downloaded again
+		now = now.Add(DefaultRefreshInterval)
+		ks = testutils.Must(source.KeySet(ctx))
+		Expect(ks.Keys[0].KeyID).To(Equal("b"))
+		Expect(p.jwksRequests.Load()).To(BeEquivalentTo(2))
+
+		// Previous keys are used while the server is failing
+		p.fail.Store(true)
+		now = now.Add(DefaultRefreshInterval)
+		ks = testutils.Must(source.KeySet(ctx))
+		Expect(ks.Keys[0].KeyID).To(Equal("b"))
+		_, err = source.Refresh(ctx)
+		Expect(err).To(Succeed()) // rate limited
+		now = now.Add(DefaultMinRefreshInterval)
+		_, err = source.Refresh(ctx)
+		Expect(err).To(MatchError(ContainSubstring("503")))
+	})
+
+	It("Should follow the cache lifetime of the server", func() {
+		p := newTestProvider("", "/.well-known/openid-configuration")
+		defer p.Close()
+		p.keys.Store(jwk.KeySpecSet{Keys: []jwk.KeySpec{newTestKey("a")}})
+
+		now := time.Now()
+		source := NewRemoteSource(p.URL + "/jwks.json")
+		source.Now = func() time.Time { return now }
+		source.MinRefreshInterval = time.Second
+		source.RefreshInterval = 24 * time.Hour
+
+		expiring := newTestKey("expiring")
+		expiring.ExpiresAt = now.Add(10 * time.Second)
+		p.keys.Store(jwk.KeySpecSet{Keys: []jwk.KeySpec{expiring}})
+		Expect(testutils.Must(source.KeySet(ctx)).Keys).To(HaveLen(1))
+
+		p.keys.Store(jwk.KeySpecSet{Keys: []jwk.KeySpec{newTestKey("b")}})
+		now = now.Add(5 * time.Second)
+		Expect(testutils.Must(source.KeySet(ctx)).Keys[0].KeyID).To(Equal("expiring"))
+		now = now.Add(5 * time.Second)
+		Expect(testutils.Must(source.KeySet(ctx)).Keys[0].KeyID).To(Equal("b"))
+	})
+
+	It("Should share concurrent fetches", func() {
+		var requests atomic.Int32
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			<-release
+			_, _ = w.Write([]byte(`{"keys":[{"kty":"oct","kid":"a","k":"AQID"}]}`))
+		}))
+		defer server.Close()
+		source := NewRemoteSource(server.URL)
+
+		results := make(chan error, 10)
+		for i := 0; i < cap(results); i++ {
+			go func() {
+				_, err := source.KeySet(ctx)
+				results <- err
+			}()
+		}
+		Eventually(requests.Load).Should(BeEquivalentTo(1))
+
+		// The lock is not held during the request
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := source.Refresh(canceled)
+		Expect(err).To(Equal(context.Canceled))
+
+		close(release)
+		for i := 0; i < cap(results); i++ {
+			Expect(<-results).To(Succeed())
+		}
+		Expect(requests.Load()).To(BeEquivalentTo(1))
+		Expect(testutils.Must(source.KeySet(ctx)).Keys).To(HaveLen(1))
+	})
+
+	It("Should ignore unsupported keys", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"keys":[{"kty":"AKP","kid":"pq"},{"kty":"oct","kid":"a","k":"AQID"}]}`))
+		}))
+		defer server.Close()
+		ks, err := NewRemoteSource(server.URL).KeySet(ctx)
+		Expect(err).To(Succeed())
+		Expect(ks.Keys).To(HaveLen(1))
+		Expect(ks.Keys[0].KeyID).To(Equal("a"))
+	})
+})
@@ -0,0 +1,165 @@
+package jwks
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rakutentech/jwk-go/jwk"
+)
+
+// DefaultPollInterval is the default delay between two checks of a FileSource
+const DefaultPollInterval = 10 * time.Second
+
+// FileSource is a Source reading keys from a file, which is reloaded when its
+// content changes, e.g. for keys mounted from a Kubernetes secret. The file
+// can contain a JWKS, a single JWK or PEM blocks (see jwk.ParseKeyFile).
+//
+// Changes are detected by polling the file and comparing the hash of its
+// content, which also works when the file is replaced through a symbolic link.
+// Invalid files never replace the current keys: the error is reported to
+// FileSourceSettings.OnError and the previous keys are kept until the file is
+// fixed.
+//
+// A FileSource must be created with NewFileSource.
+type FileSource struct {
+	path     string
+	settings FileSourceSettings
+
+	keys *jwk.SyncKeySet
+
+	// mu serializes reloads
+	mu   sync.Mutex
+	hash [sha256.Size]byte
+	// failedHash is the hash of the last rejected content, and failure the
+	// reason why it was rejected
+	failedHash [sha256.Size]byte
+	failure    error
+}
+
+// FileSourceSettings controls NewFileSource. The zero value is ready to use.
+type FileSourceSettings struct {
+	// PollInterval is the delay between two checks of the file in Run.
+	// Defaults to DefaultPollInterval.
+	PollInterval time.Duration
+
+	// Validate checks every key set read from the file, including the
+	// initial one, before it replaces the current one. Defaults to rejecting
+	// empty sets and calling KeySpecSet.Validate.
+	Validate func(ks jwk.KeySpecSet) error
+
+	// OnError is called with the errors which happen while reloading the
+	// file in Run. An invalid file is only reported once until its content
+	// changes. Optional.
+	OnError func(err error)
+}
+
+// NewFileSource creates a FileSource for the file at path, and loads it. The
+// keys are only reloaded by Reload and Run.
+func NewFileSource(path string, settings FileSourceSettings) (*FileSource, error) {
+	s := &FileSource{path: path, settings: settings, keys: jwk.NewSyncKeySet(jwk.KeySpecSet{})}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Path returns the path of the key file
+func (s *FileSource) Path() string {
+	return s.path
+}
+
+// KeySet returns the current keys, which must not be modified
+func (s *FileSource) KeySet(context.Context) (jwk.KeySpecSet, error) {
+	return s.keys.View(), nil
+}
+
+// Keys returns the SyncKeySet containing the current keys, which can be used
+// to subscribe to reloads.
+func (s *FileSource) Keys() *jwk.SyncKeySet {
+	return s.keys
+}
+
+// Reload reads the file and replaces the current keys if its content has
+// changed and is valid. It returns true if the keys were replaced.
+func (s *FileSource) Reload() (bool, error) {
+	reloaded, _, err := s.reload()
+	return reloaded, err
+}
+
+// reload implements Reload. repeated is true if the content of the file was
+// already rejected with the same error.
+func (s *FileSource) reload() (reloaded, repeated bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return false, false, err
+	}
+	defer clear(data)
+	hash := sha256.Sum256(data)
+	if hash == s.hash {
+		s.failure = nil
+		return false, false, nil
+	}
+	if s.failure != nil && hash == s.failedHash {
+		return false, true, s.failure
+	}
+
+	ks, err := s.parse(data)
+	if err != nil {
+		s.failedHash, s.failure = hash, err
+		return false, false, err
+	}
+	s.keys.Store(ks)
+	s.hash = hash
+	s.failure = nil
+	return true, false, nil
+}
+
+// parse parses and validates the content of the file
+func (s *FileSource) parse(data []byte) (jwk.KeySpecSet, error) {
+	ks, _, err := jwk.ParseKeyFile(data)
+	if err != nil {
+		return jwk.KeySpecSet{}, fmt.Errorf("%s: %w", s.path, err)
+	}
+	validate := s.settings.Validate
+	if validate == nil {
+		validate = validateKeySet
+	}
+	if err := validate(ks); err != nil {
+		return jwk.KeySpecSet{}, fmt.Errorf("%s: %w", s.path, err)
+	}
+	return ks, nil
+}
+
+// Run checks the file for changes every PollInterval, until ctx is done.
+func (s *FileSource) Run(ctx context.Context) {
+	interval := s.settings.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, repeated, err := s.reload(); err != nil && !repeated && s.settings.OnError != nil {
+				s.settings.OnError(err)
+			}
+		}
+	}
+}
+
+func validateKeySet(ks jwk.KeySpecSet) error {
+	if len(ks.Keys) == 0 {
+		return errors.New("no keys found")
+	}
+	return ks.Validate()
+}
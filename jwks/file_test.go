@@ -0,0 +1,162 @@
+package jwks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rakutentech/jwk-go/internal/testutils"
+	"github.com/rakutentech/jwk-go/jwk"
+)
+
+var _ = Describe("FileSource", func() {
+	ctx := context.Background()
+	var path string
+
+	writeKeys := func(keys ...jwk.KeySpec) {
+		ks := jwk.KeySpecSet{Keys: keys}
+		data := testutils.Must(ks.MarshalPublicJSON())
+		Expect(os.WriteFile(path, data, 0o600)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "jwks.json")
+	})
+
+	It("Should load and reload keys", func() {
+		writeKeys(newTestKey("a"))
+		s := testutils.Must(NewFileSource(path, FileSourceSettings{}))
+		Expect(testutils.Must(s.KeySet(ctx)).Keys[0].KeyID).To(Equal("a"))
+
+		Expect(testutils.Must(s.Reload())).To(BeFalse())
+		writeKeys(newTestKey("b"), newTestKey("c"))
+		Expect(testutils.Must(s.Reload())).To(BeTrue())
+		Expect(testutils.Must(s.KeySet(ctx)).Keys).To(HaveLen(2))
+	})
+
+	It("Should load single JWKs and PEM files", func() {
+		key := newTestKey("single")
+		Expect(os.WriteFile(path, testutils.Must(key.MarshalJSON()), 0o600)).To(Succeed())
+		s := testutils.Must(NewFileSource(path, FileSourceSettings{}))
+		Expect(testutils.Must(s.KeySet(ctx)).Keys[0].KeyID).To(Equal("single"))
+
+		ecKey := jwk.NewSpec(testutils.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader)))
+		Expect(os.WriteFile(path, testutils.Must(ecKey.MarshalPEM()), 0o600)).To(Succeed())
+		Expect(testutils.Must(s.Reload())).To(BeTrue())
+		Expect(testutils.Must(s.KeySet(ctx)).Keys[0].SamePublicKey(ecKey)).To(BeTrue())
+	})
+
+	It("Should keep the previous keys when the file is invalid", func() {
+		writeKeys(newTestKey("a"))
+		s := testutils.Must(NewFileSource(path, FileSourceSettings{}))
+
+		a := newTestKey("a")
+		for _, data := range []string{"", "{", `{"keys":[]}`, `{"keys":[{"kty":"unknown"}]}`} {
+			Expect(os.WriteFile(path, []byte(data), 0o600)).To(Succeed())
+			_, err := s.Reload()
+			Expect(err).To(HaveOccurred(), data)
+		}
+		_, err := s.Reload()
+		Expect(err).To(MatchError(ContainSubstring("unknown")))
+
+		writeKeys(a, a)
+		_, err = s.Reload()
+		Expect(errors.Is(err, jwk.ErrDuplicateKeyID)).To(BeTrue())
+		Expect(os.Remove(path)).To(Succeed())
+		_, err = s.Reload()
+		Expect(errors.Is(err, os.ErrNotExist)).To(BeTrue())
+
+		Expect(testutils.Must(s.KeySet(ctx)).Keys[0].KeyID).To(Equal("a"))
+		_, err = NewFileSource(path, FileSourceSettings{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should use custom validation", func() {
+		settings := FileSourceSettings{
+			Validate: func(ks jwk.KeySpecSet) error {
+				if len(ks.Keys) < 2 {
+					return errors.New("at least 2 keys are required")
+				}
+				return nil
+			},
+		}
+		writeKeys(newTestKey("a"))
+		_, err := NewFileSource(path, settings)
+		Expect(err).To(MatchError(ContainSubstring("at least 2 keys")))
+
+		writeKeys(newTestKey("a"), newTestKey("b"))
+		s := testutils.Must(NewFileSource(path, settings))
+		Expect(testutils.Must(s.KeySet(ctx)).Keys).To(HaveLen(2))
+		writeKeys(newTestKey("c"))
+		_, err = s.Reload()
+		Expect(err).To(MatchError(ContainSubstring("at least 2 keys")))
+
+		// The default validation is not applied
+		writeKeys(newTestKey("a"), newTestKey("a"))
+		Expect(testutils.Must(s.Reload())).To(BeTrue())
+	})
+
+	It("Should poll the file and follow symbolic links", func() {
+		// Kubernetes updates secrets by replacing a symbolic link
+		dir := filepath.Dir(path)
+		writeKeys(newTestKey("a"))
+		link := filepath.Join(dir, "current.json")
+		Expect(os.Symlink(path, link)).To(Succeed())
+
+		var mu sync.Mutex
+		var errs []error
+		s := testutils.Must(NewFileSource(link, FileSourceSettings{
+			PollInterval: time.Millisecond,
+			OnError: func(err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				errs = append(errs, err)
+			},
+		}))
+		Expect(s.Path()).To(Equal(link))
+		reloads := s.Keys().Watch(ctx)
+
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			s.Run(ctx)
+		}()
+		defer func() {
+			cancel()
+			<-done
+		}()
+
+		path = filepath.Join(dir, "next.json")
+		writeKeys(newTestKey("b"))
+		tmpLink := filepath.Join(dir, "tmp.json")
+		Expect(os.Symlink(path, tmpLink)).To(Succeed())
+		Expect(os.Rename(tmpLink, link)).To(Succeed())
+		Eventually(reloads).Should(Receive(WithTransform(func(ks jwk.KeySpecSet) string {
+			return ks.Keys[0].KeyID
+		}, Equal("b"))))
+
+		Expect(os.WriteFile(path, []byte("invalid"), 0o600)).To(Succeed())
+		Eventually(func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(errs)
+		}).ShouldNot(BeZero())
+		// Unchanged invalid content is only reported once
+		Consistently(func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(errs)
+		}, 50*time.Millisecond).Should(Equal(1))
+		Expect(testutils.Must(s.KeySet(ctx)).Keys[0].KeyID).To(Equal("b"))
+	})
+})
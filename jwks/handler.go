@@ -0,0 +1,172 @@
+package jwks
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rakutentech/jwk-go/jwk"
+)
+
+// ContentType is the media type of JSON Web Key Sets
+const ContentType = "application/jwk-set+json"
+
+// DefaultMaxAge is the default maximum lifetime of cached key sets
+const DefaultMaxAge = time.Hour
+
+// ErrPrivateKeyLeak is returned when a key set about to be served contains
+// private key members.
+var ErrPrivateKeyLeak = errors.New("key set contains private key members")
+
+// privateMembers are the JWK members which contain private or symmetric key
+// material (RFC 7518 section 6)
+var privateMembers = []string{"d", "p", "q", "dp", "dq", "qi", "oth", "k"}
+
+// Handler is an http.Handler serving the public keys of a key set, e.g. at
+// /.well-known/jwks.json.
+//
+// Expired keys are not served, but keys which are not valid yet are, so
+// clients can fetch them before they are used. Symmetric keys can never be
+// served: the request fails instead.
+type Handler struct {
+	// Source provides the keys to serve
+	Source Source
+
+	// MaxAge is the maximum duration clients may cache the key set. The
+	// actual duration is shortened so caches expire with the first key.
+	// Defaults to DefaultMaxAge.
+	MaxAge time.Duration
+
+	// Logger logs errors. Defaults to slog.Default().
+	Logger *slog.Logger
+
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// NewHandler creates a Handler serving the public keys from source
+func NewHandler(source Source) *Handler {
+	return &Handler{Source: source}
+}
+
+// ServeHTTP serves the key set, supporting GET and HEAD requests and
+// conditional requests with If-None-Match.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := h.now()
+	ks, err := h.Source.KeySet(r.Context())
+	if err != nil {
+		h.fail(w, r, fmt.Errorf("loading key set: %w", err))
+		return
+	}
+	ks = ks.PruneExpired(now)
+	body, err := marshalPublic(ks)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:]) + `"`
+	header := w.Header()
+	header.Set("ETag", etag)
+	header.Set("Cache-Control", "public, max-age="+strconv.Itoa(h.maxAge(ks, now)))
+	if matchETag(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	header.Set("Content-Type", ContentType)
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	header.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(body)
+	}
+}
+
+// marshalPublic serializes the public keys of ks, and checks the result does
+// not contain any private member, whatever the keys are.
+func marshalPublic(ks jwk.KeySpecSet) ([]byte, error) {
+	body, err := ks.MarshalPublicJSON()
+	if err != nil {
+		return nil, fmt.Errorf("marshaling key set: %w", err)
+	}
+	if err := checkPublic(body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// checkPublic fails if a serialized key set contains private members
+func checkPublic(body []byte) error {
+	var published struct {
+		Keys []map[string]json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &published); err != nil {
+		return fmt.Errorf("marshaling key set: %w", err)
+	}
+	for i, key := range published.Keys {
+		for _, member := range privateMembers {
+			if _, ok := key[member]; ok {
+				return fmt.Errorf("%w: key %d has member %q", ErrPrivateKeyLeak, i, member)
+			}
+		}
+	}
+	return nil
+}
+
+// maxAge returns the number of seconds the key set can be cached, which is
+// the configured maximum, limited by the expiration of the first key.
+func (h *Handler) maxAge(ks jwk.KeySpecSet, now time.Time) int {
+	maxAge := h.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+	for _, k := range ks.Keys {
+		if !k.ExpiresAt.IsZero() {
+			maxAge = min(maxAge, k.ExpiresAt.Sub(now))
+		}
+	}
+	return int(max(maxAge, 0) / time.Second)
+}
+
+func (h *Handler) fail(w http.ResponseWriter, r *http.Request, err error) {
+	logger := h.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.ErrorContext(r.Context(), "cannot serve JWKS", "error", err)
+	w.Header().Set("Cache-Control", "no-store")
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+func (h *Handler) now() time.Time {
+	if h.Now != nil {
+		return h.Now()
+	}
+	return time.Now()
+}
+
+// matchETag checks an If-None-Match header against etag, using the weak
+// comparison function (RFC 9110 section 13.1.2).
+func matchETag(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package jwks
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rakutentech/jwk-go/internal/testutils"
+	"github.com/rakutentech/jwk-go/jwk"
+)
+
+var _ = Describe("Handler", func() {
+	now := time.Unix(1700000000, 0)
+	newKey := func(kid string, exp time.Time) jwk.KeySpec {
+		return jwk.KeySpec{
+			Key:       testutils.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader)),
+			KeyID:     kid,
+			ExpiresAt: exp,
+		}
+	}
+	serve := func(h *Handler, method string, header http.Header) *http.Response {
+		req := httptest.NewRequest(method, "/.well-known/jwks.json", nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+	newHandler := func(keys ...jwk.KeySpec) *Handler {
+		h := NewHandler(StaticSource(jwk.KeySpecSet{Keys: keys}))
+		h.Now = func() time.Time { return now }
+		h.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+		return h
+	}
+
+	It("Should serve public keys", func() {
+		h := newHandler(newKey("a", time.Time{}), newKey("expired", now.Add(-time.Second)))
+		resp := serve(h, http.MethodGet, nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("application/jwk-set+json"))
+		Expect(resp.Header.Get("Cache-Control")).To(Equal("public, max-age=3600"))
+		Expect(resp.Header.Get("ETag")).To(MatchRegexp(`^"[A-Za-z0-9_-]{43}"$`))
+
+		body := testutils.Must(io.ReadAll(resp.Body))
+		var ks jwk.KeySpecSet
+		Expect(json.Unmarshal(body, &ks)).To(Succeed())
+		Expect(ks.Keys).To(HaveLen(1))
+		Expect(ks.Keys[0].KeyID).To(Equal("a"))
+		Expect(ks.Keys[0].IsPublic()).To(BeTrue())
+
+		head := serve(h, http.MethodHead, nil)
+		Expect(head.StatusCode).To(Equal(http.StatusOK))
+		Expect(head.Header.Get("ETag")).To(Equal(resp.Header.Get("ETag")))
+		Expect(testutils.Must(io.ReadAll(head.Body))).To(BeEmpty())
+
+		Expect(serve(h, http.MethodPost, nil).StatusCode).To(Equal(http.StatusMethodNotAllowed))
+	})
+
+	It("Should limit caching to the first key expiration", func() {
+		h := newHandler(newKey("a", now.Add(10*time.Minute)), newKey("b", now.Add(90*time.Second)))
+		Expect(serve(h, http.MethodGet, nil).Header.Get("Cache-Control")).To(Equal("public, max-age=90"))
+
+		h = newHandler(newKey("a", time.Time{}))
+		h.MaxAge = time.Minute
+		Expect(serve(h, http.MethodGet, nil).Header.Get("Cache-Control")).To(Equal("public, max-age=60"))
+	})
+
+	It("Should handle conditional requests", func() {
+		keys := jwk.NewSyncKeySet(jwk.KeySpecSet{Keys: []jwk.KeySpec{newKey("a", time.Time{})}})
+		h := newHandler()
+		h.Source = SyncSource(keys)
+		etag := serve(h, http.MethodGet, nil).Header.Get("ETag")
+
+		for _, ifNoneMatch := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+			resp := serve(h, http.MethodGet, http.Header{"If-None-Match": {ifNoneMatch}})
+			Expect(resp.StatusCode).To(Equal(http.StatusNotModified))
+			Expect(resp.Header.Get("ETag")).To(Equal(etag))
+			Expect(testutils.Must(io.ReadAll(resp.Body))).To(BeEmpty())
+		}
+
+		// Rotating the keys changes the ETag
+		keys.Store(jwk.KeySpecSet{Keys: []jwk.KeySpec{newKey("b", time.Time{})}})
+		resp := serve(h, http.MethodGet, http.Header{"If-None-Match": {etag}})
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("ETag")).ToNot(Equal(etag))
+	})
+
+	It("Should refuse to serve symmetric keys", func() {
+		var logs bytes.Buffer
+		h := newHandler(newKey("a", time.Time{}), jwk.KeySpec{Key: jwk.OctetKey(make([]byte, 32))})
+		h.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+		resp := serve(h, http.MethodGet, nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusInternalServerError))
+		Expect(resp.Header.Get("Cache-Control")).To(Equal("no-store"))
+		Expect(testutils.Must(io.ReadAll(resp.Body))).ToNot(ContainSubstring(`"k"`))
+		Expect(logs.String()).To(ContainSubstring("cannot serve JWKS"))
+	})
+
+	It("Should fail when the source fails", func() {
+		h := newHandler()
+		h.Source = SourceFunc(func(context.Context) (jwk.KeySpecSet, error) {
+			return jwk.KeySpecSet{}, errors.New("unavailable")
+		})
+		Expect(serve(h, http.MethodGet, nil).StatusCode).To(Equal(http.StatusInternalServerError))
+	})
+
+	It("Should detect private members", func() {
+		Expect(checkPublic([]byte(`{"keys":[{"kty":"EC","crv":"P-256","x":"AA","y":"AA"}]}`))).To(Succeed())
+		for _, member := range []string{"d", "p", "q", "dp", "dq", "qi", "oth", "k"} {
+			err := checkPublic([]byte(`{"keys":[{"kty":"EC"},{"kty":"EC","` + member + `":"AA"}]}`))
+			Expect(errors.Is(err, ErrPrivateKeyLeak)).To(BeTrue())
+		}
+	})
+})
@@ -0,0 +1,13 @@
+package jwks_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestJwks(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Jwks Suite")
+}
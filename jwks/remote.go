@@ -0,0 +1,276 @@
+package jwks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rakutentech/jwk-go/jwk"
+)
+
+const (
+	// DefaultRefreshInterval is the default maximum age of fetched key sets
+	DefaultRefreshInterval = time.Hour
+	// DefaultMinRefreshInterval is the default minimum delay between two
+	// fetches of the same key set
+	DefaultMinRefreshInterval = time.Minute
+)
+
+// maxResponseSize limits the size of fetched documents
+const maxResponseSize = 1 << 20
+
+// RemoteSource is a Source fetching a key set from a URL, e.g. the 'jwks_uri'
+// of an OpenID Provider. Fetched keys are cached and refreshed when they are
+// older than RefreshInterval, or sooner if the server asks for it with
+// Cache-Control. Conditional requests are used to avoid downloading the same
+// key set twice. Keys with unsupported key types or curves are ignored, as
+// recommended by RFC 7517 section 5.
+//
+// A RemoteSource can be used concurrently, but must not be modified after
+// its first use. Concurrent callers share a single fetch, and callers which do
+// not need to fetch the keys are not blocked by it.
+type RemoteSource struct {
+	// URL is the URL of the key set
+	URL string
+
+	// Client is the HTTP client used to fetch keys. Defaults to
+	// http.DefaultClient.
+	Client *http.Client
+
+	// RefreshInterval is the maximum age of the cached keys.
+	// Defaults to DefaultRefreshInterval.
+	RefreshInterval time.Duration
+
+	// MinRefreshInterval is the minimum delay between two fetches, which
+	// protects the server when Refresh is called repeatedly (e.g. for tokens
+	// with unknown Key IDs). Defaults to DefaultMinRefreshInterval.
+	MinRefreshInterval time.Duration
+
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+
+	mu          sync.Mutex
+	keys        jwk.KeySpecSet
+	etag        string
+	fetched     bool
+	attemptedAt time.Time
+	expiresAt   time.Time
+	// inflight is the fetch in progress, if any
+	inflight *fetchCall
+}
+
+// fetchCall is a fetch shared by all the callers waiting for it
+type fetchCall struct {
+	done chan struct{}
+	err  error
+}
+
+// NewRemoteSource creates a RemoteSource for the key set at url
+func NewRemoteSource(url string) *RemoteSource {
+	return &RemoteSource{URL: url}
+}
+
+// KeySet returns the cached key set, fetching it first if it has never been
+// fetched or is too old. If refreshing fails, the previous keys are returned
+// until a fetch succeeds.
+func (s *RemoteSource) KeySet(ctx context.Context) (jwk.KeySpecSet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fetched && s.now().Before(s.expiresAt) {
+		return s.snapshot(), nil
+	}
+	if err := s.fetch(ctx); err != nil {
+		if !s.fetched {
+			return jwk.KeySpecSet{}, err
+		}
+		// Keep using the previous keys, and retry later
+		s.expiresAt = s.attemptedAt.Add(s.minRefreshInterval())
+	}
+	return s.snapshot(), nil
+}
+
+// Refresh fetches the key set immediately, unless it has been fetched less
+// than MinRefreshInterval ago, and returns the new keys.
+func (s *RemoteSource) Refresh(ctx context.Context) (jwk.KeySpecSet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fetched && s.now().Sub(s.attemptedAt) < s.minRefreshInterval() {
+		return s.snapshot(), nil
+	}
+	if err := s.fetch(ctx); err != nil {
+		return jwk.KeySpecSet{}, err
+	}
+	return s.snapshot(), nil
+}
+
+// snapshot returns a copy of the cached keys, which callers may modify
+func (s *RemoteSource) snapshot() jwk.KeySpecSet {
+	return jwk.KeySpecSet{Keys: slices.Clone(s.keys.Keys)}
+}
+
+// fetch downloads the key set, or waits for the download already in progress.
+// It must be called with s.mu held, which is released during the request.
+func (s *RemoteSource) fetch(ctx context.Context) error {
+	if call := s.inflight; call != nil {
+		s.mu.Unlock()
+		defer s.mu.Lock()
+		select {
+		case <-call.done:
+			return call.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	call := &fetchCall{done: make(chan struct{})}
+	s.inflight = call
+	now := s.now()
+	s.attemptedAt = now
+	etag := ""
+	if s.fetched {
+		etag = s.etag
+	}
+	s.mu.Unlock()
+	result, err := s.download(ctx, etag)
+	s.mu.Lock()
+
+	if err == nil {
+		if result.modified {
+			s.keys = result.keys
+			s.etag = result.etag
+		}
+		s.fetched = true
+		s.expiresAt = now.Add(s.refreshInterval(result.cacheControl))
+	}
+	call.err = err
+	s.inflight = nil
+	close(call.done)
+	return err
+}
+
+// downloadResult is the outcome of a successful download
+type downloadResult struct {
+	// modified is false if the server confirmed that the keys are unchanged
+	modified     bool
+	keys         jwk.KeySpecSet
+	etag         string
+	cacheControl string
+}
+
+// download requests the key set, conditionally if etag is not empty
+func (s *RemoteSource) download(ctx context.Context, etag string) (*downloadResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", ContentType+", application/json")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := httpClient(s.Client).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching key set: %w", err)
+	}
+	defer resp.Body.Close()
+
+	result := &downloadResult{cacheControl: resp.Header.Get("Cache-Control")}
+	switch {
+	case resp.StatusCode == http.StatusNotModified && etag != "":
+		// Keep the current keys
+	case resp.StatusCode == http.StatusOK:
+		body, err := readBody(resp)
+		if err != nil {
+			return nil, fmt.Errorf("fetching key set: %w", err)
+		}
+		keys, _, err := jwk.ParseKeySet(body, jwk.ParseSettings{SkipUnsupported: true})
+		if err != nil {
+			return nil, fmt.Errorf("fetching key set: %w", err)
+		}
+		result.modified = true
+		result.keys = keys
+		result.etag = resp.Header.Get("ETag")
+	default:
+		return nil, fmt.Errorf("fetching key set: unexpected status %s", resp.Status)
+	}
+	return result, nil
+}
+
+// refreshInterval returns the lifetime of a fetched key set: the max-age
+// directive of the response (if any), limited by the configured intervals.
+func (s *RemoteSource) refreshInterval(cacheControl string) time.Duration {
+	interval := s.RefreshInterval
+	if interval <= 0 {
+		interval = DefaultRefreshInterval
+	}
+	if maxAge, ok := parseMaxAge(cacheControl); ok {
+		interval = min(interval, maxAge)
+	}
+	return max(interval, s.minRefreshInterval())
+}
+
+func (s *RemoteSource) minRefreshInterval() time.Duration {
+	if s.MinRefreshInterval <= 0 {
+		return DefaultMinRefreshInterval
+	}
+	return s.MinRefreshInterval
+}
+
+func (s *RemoteSource) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func parseMaxAge(cacheControl string) (time.Duration, bool) {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-cache", "no-store":
+			return 0, true
+		case "max-age":
+			seconds, err := strconv.Atoi(strings.Trim(value, `"`))
+			if err != nil || seconds < 0 {
+				return 0, false
+			}
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	return 0, false
+}
+
+func httpClient(client *http.Client) *http.Client {
+	if client == nil {
+		return http.DefaultClient
+	}
+	return client
+}
+
+// readBody reads a response body of limited size
+func readBody(resp *http.Response) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxResponseSize {
+		return nil, errors.New("response is too large")
+	}
+	return body, nil
+}
+
+// readJSON decodes a JSON response body of limited size
+func readJSON(resp *http.Response, v interface{}) error {
+	body, err := readBody(resp)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,234 @@
+package jwks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rakutentech/jwk-go/jwk"
+)
+
+var (
+	// ErrUnknownIssuer is returned by Resolver.Resolve for issuers which
+	// were not added to the Resolver
+	ErrUnknownIssuer = errors.New("unknown issuer")
+
+	// ErrAlgorithmNotAllowed is returned by Resolver.Resolve when the
+	// algorithm is not allowed for the issuer
+	ErrAlgorithmNotAllowed = errors.New("algorithm not allowed")
+
+	// ErrKeyNotFound is returned by Resolver.Resolve when the issuer has no
+	// matching key
+	ErrKeyNotFound = errors.New("key not found")
+)
+
+// Refresher is implemented by Sources which can fetch their keys again on
+// demand, such as RemoteSource.
+type Refresher interface {
+	Refresh(ctx context.Context) (jwk.KeySpecSet, error)
+}
+
+// IssuerConfig configures the keys and policies of an issuer in a Resolver.
+type IssuerConfig struct {
+	// Source provides the keys of the issuer. When nil, the key set is
+	// discovered from the issuer URL (see Discover) on first use.
+	Source Source
+
+	// RefreshInterval and MinRefreshInterval configure the RemoteSource of
+	// discovered key sets (see RemoteSource). Other sources must be
+	// configured directly.
+	RefreshInterval    time.Duration
+	MinRefreshInterval time.Duration
+
+	// AllowedAlgorithms lists the algorithms the issuer may use. If empty,
+	// all algorithms are allowed.
+	AllowedAlgorithms []string
+
+	// KeyTypes lists the key types the issuer may use, in the format
+	// accepted by KeySpec.IsKeyType ('kty' or 'kty/crv'). If empty, all key
+	// types are allowed.
+	KeyTypes []string
+}
+
+// Resolver finds the keys of tokens issued by several issuers, each with its
+// own key set and policies. A Resolver can be used concurrently.
+type Resolver struct {
+	// Client is the HTTP client used for discovery. Defaults to
+	// http.DefaultClient.
+	Client *http.Client
+
+	// Now returns the current time, used to skip keys which are not valid.
+	// Defaults to time.Now.
+	Now func() time.Time
+
+	mu      sync.RWMutex
+	issuers map[string]*issuerEntry
+}
+
+type issuerEntry struct {
+	config IssuerConfig
+
+	// mu protects source while it is being discovered
+	mu     sync.Mutex
+	source Source
+}
+
+// NewResolver creates an empty Resolver
+func NewResolver() *Resolver {
+	return &Resolver{}
+}
+
+// AddIssuer adds or replaces the configuration of an issuer
+func (r *Resolver) AddIssuer(issuer string, config IssuerConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.issuers == nil {
+		r.issuers = make(map[string]*issuerEntry)
+	}
+	r.issuers[issuer] = &issuerEntry{config: config, source: config.Source}
+}
+
+// RemoveIssuer removes an issuer from the Resolver
+func (r *Resolver) RemoveIssuer(issuer string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.issuers, issuer)
+}
+
+// Resolve returns the key of issuer which must be used to verify a token with
+// the specified Key ID and algorithm.
+//
+// Only keys which are valid now, compatible with alg and allowed by the
+// issuer policies are considered. When kid is empty, the issuer must have a
+// single matching key. If no key matches and the source of the issuer is a
+// Refresher, the keys are refreshed once, so newly rotated keys are found.
+func (r *Resolver) Resolve(ctx context.Context, issuer, kid, alg string) (*jwk.KeySpec, error) {
+	r.mu.RLock()
+	entry, ok := r.issuers[issuer]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownIssuer, issuer)
+	}
+	config := &entry.config
+	if alg == "" || (len(config.AllowedAlgorithms) > 0 && !slices.Contains(config.AllowedAlgorithms, alg)) {
+		return nil, fmt.Errorf("%w: %q for issuer %q", ErrAlgorithmNotAllowed, alg, issuer)
+	}
+
+	source, err := entry.getSource(ctx, issuer, r.Client)
+	if err != nil {
+		return nil, err
+	}
+	ks, err := source.KeySet(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key, err := r.findKey(ks, config, kid, alg)
+	if errors.Is(err, ErrKeyNotFound) {
+		if refresher, ok := source.(Refresher); ok {
+			if ks, err = refresher.Refresh(ctx); err != nil {
+				return nil, err
+			}
+			key, err = r.findKey(ks, config, kid, alg)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w (issuer %q, kid %q, alg %q)", err, issuer, kid, alg)
+	}
+	return key, nil
+}
+
+func (r *Resolver) findKey(ks jwk.KeySpecSet, config *IssuerConfig, kid, alg string) (*jwk.KeySpec, error) {
+	now := r.now()
+	candidates := ks.Filter(func(k *jwk.KeySpec) bool {
+		return (kid == "" || k.KeyID == kid) &&
+			k.IsValidAt(now) &&
+			(k.Use == "" || k.Use == keyUse(alg)) &&
+			supportsAlgorithm(k, alg) &&
+			(len(config.KeyTypes) == 0 || slices.ContainsFunc(config.KeyTypes, k.IsKeyType))
+	})
+	switch len(candidates.Keys) {
+	case 0:
+		return nil, ErrKeyNotFound
+	case 1:
+		return &candidates.Keys[0], nil
+	default:
+		if kid == "" {
+			return nil, errors.New("several keys match a token without key ID")
+		}
+		// Duplicate Key IDs: prefer the first key, as KeySpecSet.PrimaryKey does
+		return &candidates.Keys[0], nil
+	}
+}
+
+func (e *issuerEntry) getSource(ctx context.Context, issuer string, client *http.Client) (Source, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.source != nil {
+		return e.source, nil
+	}
+	metadata, err := Discover(ctx, issuer, DiscoverySettings{Client: client})
+	if err != nil {
+		return nil, err
+	}
+	source := NewRemoteSource(metadata.JWKSURI)
+	source.Client = client
+	source.RefreshInterval = e.config.RefreshInterval
+	source.MinRefreshInterval = e.config.MinRefreshInterval
+	e.source = source
+	return source, nil
+}
+
+func (r *Resolver) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+// supportsAlgorithm checks whether a key can be used with alg: its 'alg' (if
+// any) must be alg, and its type must match the algorithm. Unknown
+// algorithms are only supported by keys which specify them explicitly.
+func supportsAlgorithm(k *jwk.KeySpec, alg string) bool {
+	if k.Algorithm != "" {
+		return k.Algorithm == alg
+	}
+	switch {
+	case strings.HasPrefix(alg, "RS"), strings.HasPrefix(alg, "PS"), strings.HasPrefix(alg, "RSA"):
+		return k.IsKeyType("RSA")
+	case alg == "ES256":
+		return k.IsKeyType("EC/P-256")
+	case alg == "ES384":
+		return k.IsKeyType("EC/P-384")
+	case alg == "ES512":
+		return k.IsKeyType("EC/P-521")
+	case alg == "EdDSA":
+		return k.IsKeyType("OKP/Ed25519") || k.IsKeyType("OKP/Ed448")
+	case alg == "Ed25519", alg == "Ed448":
+		return k.IsKeyType("OKP/" + alg)
+	case strings.HasPrefix(alg, "ECDH-ES"):
+		return k.IsKeyType("EC") || k.IsKeyType("OKP/X25519") || k.IsKeyType("OKP/X448")
+	case strings.HasPrefix(alg, "HS"), strings.HasPrefix(alg, "A"), alg == "dir":
+		return k.IsKeyType("oct")
+	default:
+		return false
+	}
+}
+
+// keyUse returns the 'use' of keys for alg: "sig" for signature algorithms
+// and "enc" for key management algorithms
+func keyUse(alg string) string {
+	switch {
+	case strings.HasPrefix(alg, "RSA"):
+		return "enc"
+	case strings.HasPrefix(alg, "RS"), strings.HasPrefix(alg, "PS"), strings.HasPrefix(alg, "ES"),
+		strings.HasPrefix(alg, "HS"), strings.HasPrefix(alg, "Ed"):
+		return "sig"
+	default:
+		return "enc"
+	}
+}
@@ -0,0 +1,124 @@
+package jwks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rakutentech/jwk-go/internal/testutils"
+	"github.com/rakutentech/jwk-go/jwk"
+)
+
+var _ = Describe("Resolver", func() {
+	ctx := context.Background()
+	rsaKey := jwk.KeySpec{Key: testutils.Must(rsa.GenerateKey(rand.Reader, 2048)), KeyID: "rsa", Use: "sig"}
+	ecKey := jwk.KeySpec{Key: testutils.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader)), KeyID: "ec"}
+	edKey := newTestKey("ed")
+	encKey := jwk.KeySpec{Key: testutils.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader)), KeyID: "enc", Use: "enc"}
+
+	newResolver := func() *Resolver {
+		r := NewResolver()
+		r.AddIssuer("https://static.example.com", IssuerConfig{
+			Source: StaticSource(jwk.KeySpecSet{Keys: []jwk.KeySpec{rsaKey, ecKey, edKey, encKey}}),
+		})
+		r.AddIssuer("https://strict.example.com", IssuerConfig{
+			Source:            StaticSource(jwk.KeySpecSet{Keys: []jwk.KeySpec{rsaKey, ecKey, edKey}}),
+			AllowedAlgorithms: []string{"ES256", "EdDSA", "PS256"},
+			KeyTypes:          []string{"EC/P-256", "OKP"},
+		})
+		return r
+	}
+
+	It("Should resolve keys by issuer, kid and algorithm", func() {
+		r := newResolver()
+		for _, tc := range []struct{ kid, alg string }{
+			{"rsa", "RS256"}, {"rsa", "PS512"}, {"ec", "ES256"}, {"ed", "EdDSA"}, {"enc", "ECDH-ES"},
+		} {
+			key, err := r.Resolve(ctx, "https://static.example.com", tc.kid, tc.alg)
+			Expect(err).To(Succeed(), tc.alg)
+			Expect(key.KeyID).To(Equal(tc.kid))
+		}
+
+		_, err := r.Resolve(ctx, "https://other.example.com", "rsa", "RS256")
+		Expect(errors.Is(err, ErrUnknownIssuer)).To(BeTrue())
+
+		// Key type and use must match the algorithm
+		for _, tc := range []struct{ kid, alg string }{
+			{"rsa", "ES256"}, {"ec", "ES384"}, {"ed", "ES256"}, {"enc", "ES256"}, {"unknown", "RS256"}, {"ec", "XX"},
+		} {
+			_, err = r.Resolve(ctx, "https://static.example.com", tc.kid, tc.alg)
+			Expect(errors.Is(err, ErrKeyNotFound)).To(BeTrue(), tc.alg)
+		}
+	})
+
+	It("Should apply issuer policies", func() {
+		r := newResolver()
+		key, err := r.Resolve(ctx, "https://strict.example.com", "ec", "ES256")
+		Expect(err).To(Succeed())
+		Expect(key.KeyID).To(Equal("ec"))
+
+		_, err = r.Resolve(ctx, "https://strict.example.com", "rsa", "RS256")
+		Expect(errors.Is(err, ErrAlgorithmNotAllowed)).To(BeTrue())
+		_, err = r.Resolve(ctx, "https://strict.example.com", "rsa", "PS256")
+		Expect(errors.Is(err, ErrKeyNotFound)).To(BeTrue())
+		_, err = r.Resolve(ctx, "https://strict.example.com", "rsa", "")
+		Expect(errors.Is(err, ErrAlgorithmNotAllowed)).To(BeTrue())
+
+		r.RemoveIssuer("https://strict.example.com")
+		_, err = r.Resolve(ctx, "https://strict.example.com", "ec", "ES256")
+		Expect(errors.Is(err, ErrUnknownIssuer)).To(BeTrue())
+	})
+
+	It("Should resolve keys without kid only when unambiguous", func() {
+		r := newResolver()
+		key, err := r.Resolve(ctx, "https://static.example.com", "", "EdDSA")
+		Expect(err).To(Succeed())
+		Expect(key.KeyID).To(Equal("ed"))
+
+		r.AddIssuer("https://static.example.com", IssuerConfig{
+			Source: StaticSource(jwk.KeySpecSet{Keys: []jwk.KeySpec{edKey, newTestKey("ed2")}}),
+		})
+		_, err = r.Resolve(ctx, "https://static.example.com", "", "EdDSA")
+		Expect(err).To(MatchError(ContainSubstring("several keys")))
+	})
+
+	It("Should skip keys which are not valid", func() {
+		now := time.Now()
+		expired := newTestKey("expired")
+		expired.ExpiresAt = now.Add(-time.Second)
+		r := NewResolver()
+		r.Now = func() time.Time { return now }
+		r.AddIssuer("https://example.com", IssuerConfig{
+			Source: StaticSource(jwk.KeySpecSet{Keys: []jwk.KeySpec{expired}}),
+		})
+		_, err := r.Resolve(ctx, "https://example.com", "expired", "EdDSA")
+		Expect(errors.Is(err, ErrKeyNotFound)).To(BeTrue())
+	})
+
+	It("Should discover issuers and refresh their keys on unknown kid", func() {
+		p := newTestProvider("", "/.well-known/openid-configuration")
+		defer p.Close()
+
+		r := NewResolver()
+		r.AddIssuer(p.URL, IssuerConfig{MinRefreshInterval: time.Nanosecond})
+		key, err := r.Resolve(ctx, p.URL, "a", "EdDSA")
+		Expect(err).To(Succeed())
+		Expect(key.KeyID).To(Equal("a"))
+
+		p.keys.Store(jwk.KeySpecSet{Keys: []jwk.KeySpec{newTestKey("b")}})
+		key, err = r.Resolve(ctx, p.URL, "b", "EdDSA")
+		Expect(err).To(Succeed())
+		Expect(key.KeyID).To(Equal("b"))
+		Expect(p.jwksRequests.Load()).To(BeEquivalentTo(2))
+
+		_, err = r.Resolve(ctx, p.URL, "c", "EdDSA")
+		Expect(errors.Is(err, ErrKeyNotFound)).To(BeTrue())
+	})
+})
@@ -0,0 +1,237 @@
+package jwks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/rakutentech/jwk-go/internal/jws"
+	"github.com/rakutentech/jwk-go/internal/numericdate"
+	"github.com/rakutentech/jwk-go/jwk"
+)
+
+const (
+	// SignedType is the 'typ' header value of signed JWK Sets, as used by
+	// OpenID Federation 1.0
+	SignedType = "jwk-set+jwt"
+
+	// SignedContentType is the media type of signed JWK Sets
+	SignedContentType = "application/jwk-set+jwt"
+)
+
+// ErrInvalidSignedKeySet is returned (wrapped) for all signed JWK Sets which
+// fail verification
+var ErrInvalidSignedKeySet = errors.New("invalid signed JWKS")
+
+// SignSettings contains optional settings for Sign
+type SignSettings struct {
+	// Algorithm is the signature algorithm. If empty, KeySpec.Algorithm of the
+	// signing key is used, and if that is empty too, the default algorithm for
+	// the key type.
+	Algorithm string
+
+	// Issuer is the 'iss' claim, the entity publishing the key set
+	Issuer string
+
+	// Subject is the 'sub' claim, the entity the keys belong to. Defaults to
+	// Issuer.
+	Subject string
+
+	// IssuedAt is the 'iat' claim. Defaults to the current time.
+	IssuedAt time.Time
+
+	// ExpiresAt is the 'exp' claim. The signed key set does not expire if it
+	// is zero.
+	ExpiresAt time.Time
+}
+
+// VerifySettings contains optional settings for Verify
+type VerifySettings struct {
+	// Issuer is the expected 'iss' claim. Any issuer is accepted if empty.
+	Issuer string
+
+	// AllowedAlgorithms is a list of allowed signature algorithms. If empty,
+	// all supported asymmetric algorithms are allowed.
+	AllowedAlgorithms []string
+
+	// Leeway is the allowed clock skew when checking 'iat' and 'exp'.
+	// Defaults to 5 seconds.
+	Leeway time.Duration
+
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// SignedKeySet is a verified signed JWK Set
+type SignedKeySet struct {
+	// Keys are the keys of the set. Keys with an unsupported type or curve
+	// are skipped.
+	Keys jwk.KeySpecSet
+	// Issuer and Subject are the 'iss' and 'sub' claims
+	Issuer, Subject string
+	// IssuedAt and ExpiresAt are the 'iat' and 'exp' claims (zero if absent)
+	IssuedAt, ExpiresAt time.Time
+	// SigningKey is the trust anchor key which verified the signature
+	SigningKey *jwk.KeySpec
+}
+
+type signedKeySetClaims struct {
+	Issuer    string `json:"iss,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+}
+
+const defaultSignedLeeway = 5 * time.Second
+
+// Sign creates a signed JWK Set: a JWT (with 'typ' jwk-set+jwt) whose 'keys'
+// claim contains the public keys of ks, signed with the private key in key.
+// The Key ID of the signing key is included in the 'kid' header, so
+// verifiers can find it among their trust anchors.
+func Sign(ks jwk.KeySpecSet, key *jwk.KeySpec, settings SignSettings) (string, error) {
+	if key.IsPublic() {
+		return "", errors.New("a private key is required for signing a JWKS")
+	}
+	alg := settings.Algorithm
+	if alg == "" {
+		alg = key.Algorithm
+	}
+	if alg == "" {
+		alg = jws.DefaultAlgorithm(key.Key)
+	}
+	if !jws.IsAsymmetric(alg) {
+		return "", errors.New("signed JWKS require an asymmetric signature algorithm")
+	}
+
+	claims := struct {
+		signedKeySetClaims
+		Keys []jwk.KeySpec `json:"keys"`
+	}{
+		signedKeySetClaims: signedKeySetClaims{
+			Issuer:    settings.Issuer,
+			Subject:   settings.Subject,
+			IssuedAt:  settings.IssuedAt.Unix(),
+			ExpiresAt: numericdate.FromTime(settings.ExpiresAt),
+		},
+		Keys: make([]jwk.KeySpec, len(ks.Keys)),
+	}
+	if claims.Subject == "" {
+		claims.Subject = settings.Issuer
+	}
+	if settings.IssuedAt.IsZero() {
+		claims.IssuedAt = time.Now().Unix()
+	}
+	for i := range ks.Keys {
+		publicKey, err := ks.Keys[i].PublicOnly()
+		if err != nil {
+			return "", err
+		}
+		claims.Keys[i] = *publicKey
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	header := map[string]interface{}{"typ": SignedType}
+	if key.KeyID != "" {
+		header["kid"] = key.KeyID
+	}
+	return jws.Sign(alg, key.Key, header, payload)
+}
+
+// Verify verifies a signed JWK Set created by Sign with one of the keys in
+// trustAnchors and returns its keys. When the signed key set has a 'kid'
+// header, only the trust anchors with that Key ID are tried.
+//
+// The key set must only contain public keys, and must not be expired.
+func Verify(token string, trustAnchors jwk.KeySpecSet, settings VerifySettings) (*SignedKeySet, error) {
+	t, err := jws.Parse(token)
+	if err != nil {
+		return nil, invalidSigned("%v", err)
+	}
+	if t.Header.Typ != SignedType {
+		return nil, invalidSigned("typ must be %s", SignedType)
+	}
+	alg := t.Header.Alg
+	if !jws.IsAsymmetric(alg) {
+		return nil, invalidSigned("unsupported algorithm: %s", alg)
+	}
+	if len(settings.AllowedAlgorithms) > 0 && !slices.Contains(settings.AllowedAlgorithms, alg) {
+		return nil, fmt.Errorf("%w: %w: %s", ErrInvalidSignedKeySet, ErrAlgorithmNotAllowed, alg)
+	}
+
+	now := time.Now()
+	if settings.Now != nil {
+		now = settings.Now()
+	}
+	signingKey, err := verifySignature(t, trustAnchors, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims signedKeySetClaims
+	if err := json.Unmarshal(t.Payload, &claims); err != nil {
+		return nil, invalidSigned("claims: %v", err)
+	}
+	if settings.Issuer != "" && claims.Issuer != settings.Issuer {
+		return nil, invalidSigned("unexpected issuer %q", claims.Issuer)
+	}
+	leeway := settings.Leeway
+	if leeway <= 0 {
+		leeway = defaultSignedLeeway
+	}
+	if claims.IssuedAt != 0 && time.Unix(claims.IssuedAt, 0).After(now.Add(leeway)) {
+		return nil, invalidSigned("issued in the future")
+	}
+	if claims.ExpiresAt != 0 && !now.Add(-leeway).Before(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, invalidSigned("expired")
+	}
+
+	ks, _, err := jwk.ParseKeySet(t.Payload, jwk.ParseSettings{SkipUnsupported: true})
+	if err != nil {
+		return nil, invalidSigned("keys: %v", err)
+	}
+	for _, k := range ks.Keys {
+		if !k.IsPublic() {
+			ks.Destroy()
+			return nil, fmt.Errorf("%w: keys: %w", ErrInvalidSignedKeySet, ErrPrivateKeyLeak)
+		}
+	}
+
+	return &SignedKeySet{
+		Keys:       ks,
+		Issuer:     claims.Issuer,
+		Subject:    claims.Subject,
+		IssuedAt:   numericdate.ToTime(claims.IssuedAt),
+		ExpiresAt:  numericdate.ToTime(claims.ExpiresAt),
+		SigningKey: signingKey,
+	}, nil
+}
+
+// verifySignature verifies the signature of t with the matching trust anchors
+// and returns the key which verified it.
+func verifySignature(t *jws.Token, trustAnchors jwk.KeySpecSet, now time.Time) (*jwk.KeySpec, error) {
+	kid, alg := t.Header.Kid, t.Header.Alg
+	candidates := trustAnchors.Filter(func(k *jwk.KeySpec) bool {
+		return (kid == "" || k.KeyID == kid) &&
+			k.IsValidAt(now) &&
+			(k.Use == "" || k.Use == "sig") &&
+			supportsAlgorithm(k, alg)
+	})
+	if len(candidates.Keys) == 0 {
+		return nil, fmt.Errorf("%w: %w (kid %q, alg %q)", ErrInvalidSignedKeySet, ErrKeyNotFound, kid, alg)
+	}
+	for i := range candidates.Keys {
+		if err := t.Verify(candidates.Keys[i].Key); err == nil {
+			return &candidates.Keys[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %w", ErrInvalidSignedKeySet, jws.ErrInvalidSignature)
+}
+
+func invalidSigned(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidSignedKeySet, fmt.Sprintf(format, args...))
+}
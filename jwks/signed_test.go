@@ -0,0 +1,121 @@
+package jwks
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rakutentech/jwk-go/internal/jws"
+	"github.com/rakutentech/jwk-go/internal/testutils"
+	"github.com/rakutentech/jwk-go/jwk"
+)
+
+var _ = Describe("Signed JWKS", func() {
+	now := time.Unix(1700000000, 0)
+	anchor := newTestKey("anchor")
+	anchors := jwk.KeySpecSet{Keys: []jwk.KeySpec{*testutils.Must(anchor.PublicOnly())}}
+	keys := jwk.KeySpecSet{Keys: []jwk.KeySpec{newTestKey("a"), newTestKey("b")}}
+	verifySettings := VerifySettings{Now: func() time.Time { return now }}
+
+	sign := func(settings SignSettings) string {
+		if settings.IssuedAt.IsZero() {
+			settings.IssuedAt = now
+		}
+		return testutils.Must(Sign(keys, &anchor, settings))
+	}
+
+	It("Should sign and verify key sets", func() {
+		token := sign(SignSettings{Issuer: "https://op.example", ExpiresAt: now.Add(time.Hour)})
+		header := testutils.Must(jws.Parse(token)).Header
+		Expect(header.Alg).To(Equal("EdDSA"))
+		Expect(header.Kid).To(Equal("anchor"))
+		Expect(header.Typ).To(Equal("jwk-set+jwt"))
+
+		signed := testutils.Must(Verify(token, anchors, verifySettings))
+		Expect(signed.Keys.Keys).To(HaveLen(2))
+		Expect(signed.Keys.Keys[0].SamePublicKey(&keys.Keys[0])).To(BeTrue())
+		Expect(signed.Keys.Keys[0].IsPublic()).To(BeTrue())
+		Expect(signed.Issuer).To(Equal("https://op.example"))
+		Expect(signed.Subject).To(Equal("https://op.example"))
+		Expect(signed.IssuedAt).To(Equal(now))
+		Expect(signed.ExpiresAt).To(Equal(now.Add(time.Hour)))
+		Expect(signed.SigningKey.KeyID).To(Equal("anchor"))
+	})
+
+	It("Should sign with EC keys", func() {
+		ecKey := jwk.NewSpecWithID("ec", testutils.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader)))
+		token := testutils.Must(Sign(keys, ecKey, SignSettings{}))
+		Expect(testutils.Must(jws.Parse(token)).Header.Alg).To(Equal("ES256"))
+
+		ecAnchors := jwk.KeySpecSet{Keys: []jwk.KeySpec{anchors.Keys[0], *testutils.Must(ecKey.PublicOnly())}}
+		Expect(testutils.Must(Verify(token, ecAnchors, VerifySettings{})).SigningKey.KeyID).To(Equal("ec"))
+		_, err := Verify(token, ecAnchors, VerifySettings{AllowedAlgorithms: []string{"EdDSA"}})
+		Expect(errors.Is(err, ErrAlgorithmNotAllowed)).To(BeTrue())
+	})
+
+	It("Should reject untrusted signatures", func() {
+		token := sign(SignSettings{})
+		_, err := Verify(token, jwk.KeySpecSet{Keys: []jwk.KeySpec{newTestKey("other")}}, verifySettings)
+		Expect(errors.Is(err, ErrInvalidSignedKeySet)).To(BeTrue())
+		Expect(errors.Is(err, ErrKeyNotFound)).To(BeTrue())
+
+		_, err = Verify(token, jwk.KeySpecSet{Keys: []jwk.KeySpec{newTestKey("anchor")}}, verifySettings)
+		Expect(errors.Is(err, jws.ErrInvalidSignature)).To(BeTrue())
+
+		expiredAnchor := anchors.Keys[0]
+		expiredAnchor.ExpiresAt = now
+		_, err = Verify(token, jwk.KeySpecSet{Keys: []jwk.KeySpec{expiredAnchor}}, verifySettings)
+		Expect(errors.Is(err, ErrKeyNotFound)).To(BeTrue())
+
+		parts := strings.Split(token, ".")
+		parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"keys":[]}`))
+		_, err = Verify(strings.Join(parts, "."), anchors, verifySettings)
+		Expect(errors.Is(err, jws.ErrInvalidSignature)).To(BeTrue())
+	})
+
+	It("Should check the claims", func() {
+		token := sign(SignSettings{Issuer: "https://op.example", ExpiresAt: now.Add(time.Hour)})
+		_, err := Verify(token, anchors, VerifySettings{Issuer: "https://other.example", Now: verifySettings.Now})
+		Expect(err).To(MatchError(`invalid signed JWKS: unexpected issuer "https://op.example"`))
+
+		_, err = Verify(token, anchors, VerifySettings{Now: func() time.Time { return now.Add(time.Hour + time.Minute) }})
+		Expect(err).To(MatchError("invalid signed JWKS: expired"))
+		_, err = Verify(token, anchors, VerifySettings{Now: func() time.Time { return now.Add(-time.Minute) }})
+		Expect(err).To(MatchError("invalid signed JWKS: issued in the future"))
+		Expect(Verify(token, anchors, VerifySettings{Now: func() time.Time { return now.Add(-time.Second) }})).
+			NotTo(BeNil())
+	})
+
+	It("Should reject invalid tokens", func() {
+		payload := []byte(`{"keys":[` + string(testutils.Must(keys.Keys[0].MarshalJSON())) + `]}`)
+		for _, token := range []string{
+			"invalid",
+			testutils.Must(jws.Sign("EdDSA", anchor.Key, map[string]interface{}{"kid": "anchor"}, payload)),
+			testutils.Must(jws.Sign("HS256", []byte("secret"), map[string]interface{}{"typ": SignedType}, payload)),
+		} {
+			_, err := Verify(token, anchors, verifySettings)
+			Expect(errors.Is(err, ErrInvalidSignedKeySet)).To(BeTrue(), token)
+		}
+
+		leaked := testutils.Must(jws.Sign("EdDSA", anchor.Key, map[string]interface{}{"typ": SignedType}, payload))
+		_, err := Verify(leaked, anchors, verifySettings)
+		Expect(errors.Is(err, ErrInvalidSignedKeySet)).To(BeTrue())
+		Expect(errors.Is(err, ErrPrivateKeyLeak)).To(BeTrue())
+	})
+
+	It("Should require a private asymmetric signing key", func() {
+		_, err := Sign(keys, &anchors.Keys[0], SignSettings{})
+		Expect(err).To(MatchError("a private key is required for signing a JWKS"))
+		_, err = Sign(keys, jwk.NewSpec([]byte("secret")), SignSettings{})
+		Expect(err).To(MatchError("signed JWKS require an asymmetric signature algorithm"))
+		_, err = Sign(jwk.KeySpecSet{Keys: []jwk.KeySpec{*jwk.NewSpec([]byte("secret"))}}, &anchor, SignSettings{})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,37 @@
+// Package jwks serves and fetches JSON Web Key Sets (RFC 7517 section 5).
+package jwks
+
+import (
+	"context"
+
+	"github.com/rakutentech/jwk-go/jwk"
+)
+
+// Source provides the current key set, e.g. for serving it. The returned set
+// may be shared with other callers and must not be modified.
+type Source interface {
+	KeySet(ctx context.Context) (jwk.KeySpecSet, error)
+}
+
+// SourceFunc adapts a function to the Source interface
+type SourceFunc func(ctx context.Context) (jwk.KeySpecSet, error)
+
+// KeySet calls f(ctx)
+func (f SourceFunc) KeySet(ctx context.Context) (jwk.KeySpecSet, error) {
+	return f(ctx)
+}
+
+// StaticSource returns a Source which always returns the same key set.
+func StaticSource(ks jwk.KeySpecSet) Source {
+	return SourceFunc(func(context.Context) (jwk.KeySpecSet, error) {
+		return ks, nil
+	})
+}
+
+// SyncSource returns a Source which returns the current key set of a
+// jwk.SyncKeySet, so keys can be rotated while they are served.
+func SyncSource(s *jwk.SyncKeySet) Source {
+	return SourceFunc(func(context.Context) (jwk.KeySpecSet, error) {
+		return s.View(), nil
+	})
+}
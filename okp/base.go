@@ -39,3 +39,7 @@ func (okp OctetKeyPairBase) PrivateKey() []byte { return okp.privateKey }
 
 // PublicKey is the bytes specifying the public key
 func (okp OctetKeyPairBase) PublicKey() []byte { return okp.publicKey }
+
+// Destroy overwrites the private key with zeros.
+// The private key must not be used afterwards.
+func (okp OctetKeyPairBase) Destroy() { clear(okp.privateKey) }
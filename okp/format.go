@@ -0,0 +1,121 @@
+package okp
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Formatting methods are implemented on every key pair type, so that printing
+// or logging a key pair never reveals its private key.
+
+// redacted replaces private keys in formatted output
+const redacted = "REDACTED"
+
+// String describes the key pair, redacting the private key
+func (okp OctetKeyPairBase) String() string { return describe("OctetKeyPair", okp) }
+
+// GoString describes the key pair, redacting the private key
+func (okp OctetKeyPairBase) GoString() string { return goString("okp.OctetKeyPairBase", okp) }
+
+// Format implements fmt.Formatter, redacting the private key
+func (okp OctetKeyPairBase) Format(f fmt.State, verb rune) { format(f, verb, okp) }
+
+// LogValue implements slog.LogValuer, redacting the private key
+func (okp OctetKeyPairBase) LogValue() slog.Value { return logValue("", okp) }
+
+// String describes the key pair, redacting the private key
+func (c Ed25519) String() string { return describe(c.Curve(), c.OctetKeyPairBase) }
+
+// GoString describes the key pair, redacting the private key
+func (c Ed25519) GoString() string { return goString("okp.Ed25519", c.OctetKeyPairBase) }
+
+// Format implements fmt.Formatter, redacting the private key
+func (c Ed25519) Format(f fmt.State, verb rune) { format(f, verb, c) }
+
+// LogValue implements slog.LogValuer, redacting the private key
+func (c Ed25519) LogValue() slog.Value { return logValue(c.Curve(), c.OctetKeyPairBase) }
+
+// String describes the key pair, redacting the private key
+func (c Ed448) String() string { return describe(c.Curve(), c.OctetKeyPairBase) }
+
+// GoString describes the key pair, redacting the private key
+func (c Ed448) GoString() string { return goString("okp.Ed448", c.OctetKeyPairBase) }
+
+// Format implements fmt.Formatter, redacting the private key
+func (c Ed448) Format(f fmt.State, verb rune) { format(f, verb, c) }
+
+// LogValue implements slog.LogValuer, redacting the private key
+func (c Ed448) LogValue() slog.Value { return logValue(c.Curve(), c.OctetKeyPairBase) }
+
+// String describes the key pair, redacting the private key
+func (c Curve25519) String() string { return describe(c.Curve(), c.OctetKeyPairBase) }
+
+// GoString describes the key pair, redacting the private key
+func (c Curve25519) GoString() string { return goString("okp.Curve25519", c.OctetKeyPairBase) }
+
+// Format implements fmt.Formatter, redacting the private key
+func (c Curve25519) Format(f fmt.State, verb rune) { format(f, verb, c) }
+
+// LogValue implements slog.LogValuer, redacting the private key
+func (c Curve25519) LogValue() slog.Value { return logValue(c.Curve(), c.OctetKeyPairBase) }
+
+// String describes the key pair, redacting the private key
+func (c Curve448) String() string { return describe(c.Curve(), c.OctetKeyPairBase) }
+
+// GoString describes the key pair, redacting the private key
+func (c Curve448) GoString() string { return goString("okp.Curve448", c.OctetKeyPairBase) }
+
+// Format implements fmt.Formatter, redacting the private key
+func (c Curve448) Format(f fmt.State, verb rune) { format(f, verb, c) }
+
+// LogValue implements slog.LogValuer, redacting the private key
+func (c Curve448) LogValue() slog.Value { return logValue(c.Curve(), c.OctetKeyPairBase) }
+
+func describe(name string, okp OctetKeyPairBase) string {
+	var sb strings.Builder
+	sb.WriteString(name)
+	sb.WriteString("{x=")
+	sb.WriteString(base64.RawURLEncoding.EncodeToString(okp.publicKey))
+	if okp.privateKey != nil {
+		sb.WriteString(" d=" + redacted)
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+func goString(typeName string, okp OctetKeyPairBase) string {
+	private := "nil"
+	if okp.privateKey != nil {
+		private = redacted
+	}
+	return fmt.Sprintf("%s{publicKey: %#v, privateKey: %s}", typeName, okp.publicKey, private)
+}
+
+func format(f fmt.State, verb rune, v interface {
+	fmt.Stringer
+	fmt.GoStringer
+}) {
+	switch {
+	case verb == 'v' && f.Flag('#'):
+		fmt.Fprint(f, v.GoString())
+	case verb == 'q':
+		fmt.Fprintf(f, "%q", v.String())
+	default:
+		// Never fall back to the default formatting of the key bytes,
+		// whatever the verb is.
+		fmt.Fprint(f, v.String())
+	}
+}
+
+func logValue(curve string, okp OctetKeyPairBase) slog.Value {
+	attrs := make([]slog.Attr, 0, 3)
+	if curve != "" {
+		attrs = append(attrs, slog.String("crv", curve))
+	}
+	attrs = append(attrs,
+		slog.String("x", base64.RawURLEncoding.EncodeToString(okp.publicKey)),
+		slog.Bool("private", okp.privateKey != nil))
+	return slog.GroupValue(attrs...)
+}
@@ -0,0 +1,44 @@
+package okp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Formatting", func() {
+	It("Should never print private keys", func() {
+		ed, err := GenerateEd25519(rand.Reader)
+		Expect(err).To(Succeed())
+		x, err := GenerateCurve25519(rand.Reader)
+		Expect(err).To(Succeed())
+
+		for _, key := range []CurveOctetKeyPair{ed, x, NewEd448(nil, []byte("ed448")), NewCurve448(nil, []byte("x448"))} {
+			private := key.PrivateKey()
+			var buf bytes.Buffer
+			for _, format := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%d"} {
+				fmt.Fprintf(&buf, format, key)
+				fmt.Fprintf(&buf, format, &key)
+			}
+			slog.New(slog.NewJSONHandler(&buf, nil)).Info("key", "key", key)
+			slog.New(slog.NewTextHandler(&buf, nil)).Info("key", "key", key)
+
+			for _, secret := range []string{
+				string(private),
+				base64.RawURLEncoding.EncodeToString(private),
+				base64.StdEncoding.EncodeToString(private),
+				fmt.Sprintf("%x", private),
+				fmt.Sprintf("%v", private),
+			} {
+				Expect(buf.String()).ToNot(ContainSubstring(secret))
+			}
+			Expect(buf.String()).To(ContainSubstring("REDACTED"))
+			Expect(buf.String()).To(ContainSubstring(key.Curve()))
+		}
+	})
+})
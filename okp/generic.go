@@ -8,6 +8,9 @@ import (
 var (
 	// ErrKeyMissing s thrown when a required key (public or private) is missing
 	ErrKeyMissing = errors.New("no public or private key is specified")
+
+	// ErrUnknownCurve is returned (wrapped) for unsupported curves
+	ErrUnknownCurve = errors.New("unknown curve specified")
 )
 
 // NewCurveOKP creates a new CurveOctetKeyPair with the specified curve
@@ -31,7 +34,7 @@ func NewCurveOKP(curve string, pubKey []byte, privKey []byte) (CurveOctetKeyPair
 		// TODO: Curve448 is not validated because we don't really support it yet
 		return Curve448{okpb}, nil
 	default:
-		return nil, fmt.Errorf("unknown curve specified: %s", curve)
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCurve, curve)
 	}
 }
 
@@ -67,6 +67,7 @@ func validateCurve25519(kp OctetKeyPairBase) (err error) {
 		privateKey := new([32]byte)
 		copy(privateKey[:], kp.privateKey)
 		curve25519.ScalarBaseMult(publicKey, privateKey)
+		clear(privateKey[:]) // Do not leave copies of the private key behind
 		kp.publicKey = publicKey[:]
 	}
 	return
@@ -76,6 +77,7 @@ func derivePublicEd25519FromPrivate(privateKey []byte) ([]byte, error) {
 	if len(privateKey) < 32 {
 		return nil, errors.New("Ed25519 Private key must be at least 32 bytes long")
 	}
-	pub, _, err := ed25519.GenerateKey(bytes.NewReader(privateKey))
+	pub, priv, err := ed25519.GenerateKey(bytes.NewReader(privateKey))
+	clear(priv) // Do not leave copies of the private key behind
 	return pub, err
 }